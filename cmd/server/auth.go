@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"github.com/iwanhae/kuview/pkg/config"
+	"github.com/iwanhae/kuview/pkg/server/middleware"
+)
+
+// authenticators holds the authenticators created once. The static tokens are read again on reload.
+type authenticators struct {
+	clientCert  bool
+	oidc        *middleware.OIDC
+	shareLinks  *middleware.ShareLinks
+	tokenReview *middleware.TokenReview
+}
+
+func newAuthenticators(ctx context.Context, cfg *config.Config, home cluster.Cluster) (*authenticators, error) {
+	a := &authenticators{clientCert: cfg.TLS.ClientCAFile != ""}
+	if o := cfg.Auth.OIDC; o.Enabled() {
+		oidcCfg := middleware.OIDCConfig{
+			IssuerURL:     o.IssuerURL,
+			ClientID:      o.ClientID,
+			RedirectURL:   o.RedirectURL,
+			Scopes:        o.Scopes,
+			UsernameClaim: o.UsernameClaim,
+			GroupsClaim:   o.GroupsClaim,
+			SessionTTL:    o.SessionTTL.Duration,
+		}
+		var err error
+		if oidcCfg.ClientSecret, err = readSecret(o.ClientSecretFile); err != nil {
+			return nil, err
+		}
+		if o.CookieSecretFile != "" {
+			secret, err := readSecret(o.CookieSecretFile)
+			if err != nil {
+				return nil, err
+			}
+			oidcCfg.CookieSecret = []byte(secret)
+		}
+		if a.oidc, err = middleware.NewOIDC(ctx, oidcCfg); err != nil {
+			return nil, err
+		}
+	}
+	if s := cfg.Auth.ShareLinks; s.Enabled {
+		var secret []byte
+		if s.SecretFile != "" {
+			value, err := readSecret(s.SecretFile)
+			if err != nil {
+				return nil, err
+			}
+			secret = []byte(value)
+		}
+		var err error
+		if a.shareLinks, err = middleware.NewShareLinks(secret, s.DenyListFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Auth.TokenReview.Enabled {
+		var err error
+		a.tokenReview, err = middleware.NewTokenReview(home.Config, cfg.Auth.TokenReview.Audiences)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// list returns the authenticators in the order they are tried, reading the static tokens of the config.
+func (a *authenticators) list(cfg *config.Config) ([]middleware.Authenticator, error) {
+	var list []middleware.Authenticator
+	if a.clientCert {
+		list = append(list, middleware.ClientCertificate{})
+	}
+	if a.oidc != nil {
+		list = append(list, a.oidc)
+	}
+	// before the token review, not to send the links to the cluster
+	if a.shareLinks != nil {
+		list = append(list, a.shareLinks)
+	}
+
+	var tokens middleware.StaticTokens
+	if cfg.Auth.TokenFile != "" {
+		token, err := readSecret(cfg.Auth.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, middleware.StaticToken{Token: token, User: middleware.User{Name: "token"}})
+	}
+	for _, t := range cfg.Auth.Tokens {
+		token, err := readSecret(t.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, middleware.StaticToken{Token: token, User: middleware.User{Name: t.Name, Groups: t.Groups}})
+	}
+	if len(tokens) > 0 {
+		list = append(list, tokens)
+	}
+
+	if a.tokenReview != nil {
+		list = append(list, a.tokenReview)
+	}
+	return list, nil
+}
+
+// readSecret reads a file holding a secret, which must not be empty.
+func readSecret(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return secret, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/analyzer"
+	"github.com/iwanhae/kuview/pkg/analyzer/diagnostics"
+	"github.com/iwanhae/kuview/pkg/analyzer/images"
+	"github.com/iwanhae/kuview/pkg/analyzer/podsecurity"
+	"github.com/iwanhae/kuview/pkg/analyzer/rbac"
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"github.com/iwanhae/kuview/pkg/config"
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	minClusterBackoff = 5 * time.Second
+	maxClusterBackoff = 5 * time.Minute
+)
+
+// runCluster watches the cluster until the context is done. A cluster failing to start or stopping with an error
+// is reported as its SyncStatus and restarted with a backoff, so it does not stop the other clusters.
+func runCluster(ctx context.Context, cfg *config.Config, c cluster.Cluster, rules []diagnostics.Rule, emitter controller.Emitter) {
+	emitter = controller.WithCluster(c.ID, emitter)
+	backoff := minClusterBackoff
+	failed := false
+	for {
+		start := time.Now()
+		err := startCluster(ctx, cfg, c, rules, emitter, failed)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > maxClusterBackoff {
+			// the cluster was watched for a while, so the error is not the same one repeating
+			backoff = minClusterBackoff
+		}
+		failed = true
+		emitter.Emit(controller.ClusterStatus(err))
+		log.Error().Err(err).
+			Str("cluster", c.ID).
+			Dur("backoff", backoff).
+			Msg("failed to watch cluster")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxClusterBackoff)
+	}
+}
+
+// startCluster runs a controller manager for the cluster until the context is done or it fails.
+// If failed, the failure reported before is cleared once the manager is created.
+func startCluster(ctx context.Context, cfg *config.Config, c cluster.Cluster, rules []diagnostics.Rule, emitter controller.Emitter, failed bool) error {
+	// stops the goroutines of a failed manager before it is replaced
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// analyzers keep state per cluster, so every cluster has its own pipeline
+	imageAnalyzer, err := images.New(cfg.Images.RegistryAllowlist...)
+	if err != nil {
+		return fmt.Errorf("failed to create image analyzer: %w", err)
+	}
+	pipeline := analyzer.NewPipeline(emitter,
+		rbac.New(),
+		diagnostics.New(rules...),
+		podsecurity.New(),
+		imageAnalyzer,
+	)
+
+	opts, err := controllerOptions(ctx, cfg, c)
+	if err != nil {
+		return fmt.Errorf("failed to configure cluster %q: %w", c.ID, err)
+	}
+	mgr, err := controller.New(
+		ctx, *c.Config,
+		cfg.Objects(),
+		pipeline,
+		opts,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create a new controller for cluster %q: %w", c.ID, err)
+	}
+	if err := mgr.Add(pipeline); err != nil {
+		return fmt.Errorf("failed to add analyzer pipeline: %w", err)
+	}
+	if failed {
+		// the cluster is reachable again
+		emitter.Emit(controller.ClusterStatus(nil))
+	}
+
+	if err := mgr.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start controller manager of cluster %q: %w", c.ID, err)
+	}
+	if ctx.Err() == nil {
+		return fmt.Errorf("controller manager of cluster %q stopped", c.ID)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/iwanhae/kuview/pkg/audit"
+	"github.com/iwanhae/kuview/pkg/config"
+	"github.com/iwanhae/kuview/pkg/server"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// dumpConfig prints the effective configuration.
+// Usage: kuview config dump [flags]
+func dumpConfig(args []string) error {
+	cfg, err := config.Load("kuview config dump", args)
+	if err != nil {
+		return err
+	}
+	b, err := cfg.Dump()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+func configureLogger(c config.LogConfig) {
+	if c.Format == config.LogFormatJSON {
+		log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+	} else {
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	}
+	setLogLevel(c)
+}
+
+// setLogLevel sets the level globally, so it also applies to the loggers derived from log.Logger.
+func setLogLevel(c config.LogConfig) {
+	level, err := zerolog.ParseLevel(c.Level)
+	if err != nil {
+		// the config is validated
+		return
+	}
+	zerolog.SetGlobalLevel(level)
+}
+
+func serverOptions(cfg *config.Config, auth *authenticators) (server.Options, error) {
+	list, err := auth.list(cfg)
+	if err != nil {
+		return server.Options{}, err
+	}
+	trustedProxies := make([]*net.IPNet, 0, len(cfg.HTTP.TrustedProxies))
+	for _, cidr := range cfg.HTTP.TrustedProxies {
+		_, proxy, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return server.Options{}, fmt.Errorf("failed to parse trusted proxy %q: %w", cidr, err)
+		}
+		trustedProxies = append(trustedProxies, proxy)
+	}
+	opts := server.Options{
+		GzipLevel:       cfg.HTTP.GzipLevel,
+		AllowOrigins:    cfg.HTTP.AllowOrigins,
+		H2C:             cfg.HTTP.H2C,
+		TrustedProxies:  trustedProxies,
+		Authenticators:  list,
+		OIDC:            auth.oidc,
+		ShareLinks:      auth.shareLinks,
+		ShareLinkMaxTTL: cfg.Auth.ShareLinks.MaxTTL.Duration,
+		ClusterMetrics:  cfg.Metrics.Cluster,
+		Limits: server.Limits{
+			MaxSubscribers:          cfg.Limits.MaxSubscribers,
+			MaxSubscribersPerClient: cfg.Limits.MaxSubscribersPerClient,
+			MaxLogStreams:           cfg.Limits.MaxLogStreams,
+			MaxLogStreamsPerClient:  cfg.Limits.MaxLogStreamsPerClient,
+			ProxyRate:               cfg.Limits.ProxyRate,
+			ProxyBurst:              cfg.Limits.ProxyBurst,
+		},
+	}
+	if a := cfg.Auth.Authorization; a.Enabled {
+		opts.Authorization = &server.AuthorizationOptions{
+			ExemptUsers:  a.ExemptUsers,
+			ExemptGroups: a.ExemptGroups,
+		}
+	}
+	return opts, nil
+}
+
+// serverTLSConfig returns the TLS config to serve with, nil to serve plain HTTP.
+func serverTLSConfig(c config.TLSConfig) (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	return server.TLSOptions{
+		CertFile:          c.CertFile,
+		KeyFile:           c.KeyFile,
+		SelfSigned:        c.SelfSigned,
+		ClientCAFile:      c.ClientCAFile,
+		RequireClientCert: c.ClientAuth != config.ClientAuthOptional,
+	}.TLSConfig()
+}
+
+// notifyReload relays SIGHUP to the returned channel. It is called on startup, as SIGHUP terminates the process
+// until it is relayed.
+func notifyReload() chan os.Signal {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	return hup
+}
+
+// watchReload reloads the configuration on SIGHUP, from the same file, environment and flags.
+// The log level, the allowed origins, the static tokens and the limits are applied; the other settings need a restart.
+func watchReload(ctx context.Context, hup chan os.Signal, name string, cfg *config.Config, args []string, s *server.Server, auth *authenticators) {
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		next, err := config.Load(name, args)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to reload config, keeping the current one")
+			continue
+		}
+		opts, err := serverOptions(next, auth)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to reload config, keeping the current one")
+			continue
+		}
+		if restart := cfg.RestartRequired(next); len(restart) > 0 {
+			log.Warn().Strs("settings", restart).Msg("ignoring changed settings that are only applied on restart")
+		}
+		setLogLevel(next.Log)
+		s.Reload(opts)
+		log.Info().Str("level", next.Log.Level).Strs("allowOrigins", next.HTTP.AllowOrigins).Msg("config reloaded")
+	}
+}
+
+// newAuditor returns the audit logger writing to the configured sinks, nil if there is none.
+func newAuditor(c config.AuditConfig) (*audit.Logger, error) {
+	var sinks []audit.Sink
+	if c.File != "" {
+		sink, err := audit.NewFileSink(c.File)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if c.Webhook.URL != "" {
+		token, err := readSecret(c.Webhook.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, audit.NewWebhookSink(c.Webhook.URL, token))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return audit.New(sinks...), nil
+}
@@ -2,54 +2,199 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
 	"os"
+	"slices"
+	"strings"
 
+	"github.com/iwanhae/kuview/pkg/alert"
+	"github.com/iwanhae/kuview/pkg/analyzer/diagnostics"
+	"github.com/iwanhae/kuview/pkg/analyzer/images"
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"github.com/iwanhae/kuview/pkg/config"
 	"github.com/iwanhae/kuview/pkg/controller"
 	"github.com/iwanhae/kuview/pkg/server"
-	"github.com/iwanhae/kuview/pkg/types"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"golang.org/x/sync/errgroup"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 )
 
 func main() {
-	log.Logger = log.
-		Output(zerolog.ConsoleWriter{Out: os.Stderr}).
-		Level(zerolog.InfoLevel)
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	if len(os.Args) > 1 && os.Args[1] == "relay" {
+		args := os.Args[2:]
+		cfg, err := config.Load("kuview relay", args)
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load config")
+		}
+		configureLogger(cfg.Log)
+
+		log.Info().
+			Msg("Starting kuview relay")
+
+		if err := runRelay(signals.SetupSignalHandler(), cfg, args); err != nil {
+			log.Fatal().Err(err).Msg("Failed to run kuview relay")
+		}
+		return
+	}
+
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "dump" {
+		if err := dumpConfig(os.Args[3:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+			log.Fatal().Err(err).Msg("Failed to dump config")
+		}
+		return
+	}
+
+	args := os.Args[1:]
+	cfg, err := config.Load("kuview", args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config")
+	}
+	configureLogger(cfg.Log)
 
 	log.Info().
 		Msg("Starting kuview server")
 
-	if err := run(signals.SetupSignalHandler()); err != nil {
+	if err := run(signals.SetupSignalHandler(), cfg, args); err != nil {
 		log.Fatal().Err(err).Msg("Failed to run kuview")
 	}
 }
 
-func run(ctx context.Context) error {
-	cfg := ctrl.GetConfigOrDie()
+func run(ctx context.Context, cfg *config.Config, args []string) error {
+	hup := notifyReload()
+	clusters, err := cluster.Parse(strings.Join(cfg.Clusters, ","))
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		// a single cluster, whose events are not tagged
+		c, err := cluster.Current()
+		if err != nil {
+			return err
+		}
+		clusters = []cluster.Cluster{c}
+	}
 
-	s, err := server.New(cfg)
+	// the first cluster reviews the tokens
+	auth, err := newAuthenticators(ctx, cfg, clusters[0])
+	if err != nil {
+		return fmt.Errorf("failed to configure authentication: %w", err)
+	}
+	opts, err := serverOptions(cfg, auth)
+	if err != nil {
+		return err
+	}
+	auditor, err := newAuditor(cfg.Audit)
+	if err != nil {
+		return err
+	}
+	// closed once the server has stopped, writing the last events
+	defer auditor.Close()
+	opts.Audit = auditor
+	if len(opts.Authenticators) == 0 {
+		log.Warn().Msg("authentication is disabled, anyone reaching the server can read every object")
+	}
+	s, err := server.New(opts, clusters...)
 	if err != nil {
 		return fmt.Errorf("failed to create a new server: %w", err)
 	}
+	tlsConfig, err := serverTLSConfig(cfg.TLS)
+	if err != nil {
+		return err
+	}
 
-	go http.ListenAndServe(":8001", s)
-
-	mgr, err := controller.New(
-		ctx, *cfg,
-		types.ObjectSchemas,
-		s,
-	)
+	var diagCfg *diagnostics.Config
+	if cfg.Diagnostics.ConfigFile != "" {
+		diagCfg, err = diagnostics.LoadConfig(cfg.Diagnostics.ConfigFile)
+		if err != nil {
+			return err
+		}
+	}
+	rules, err := diagCfg.BuildRules(cfg.Objects())
 	if err != nil {
-		return fmt.Errorf("failed to create a new controller: %w", err)
+		return fmt.Errorf("failed to build diagnostics rules: %w", err)
+	}
+
+	var emitter controller.Emitter = s
+	var alerter *alert.Alerter
+	if cfg.Alert.ConfigFile != "" {
+		alertCfg, err := alert.LoadConfig(cfg.Alert.ConfigFile)
+		if err != nil {
+			return err
+		}
+		if cfg.Alert.Test {
+			standIn := alert.StartStandIn(alertCfg)
+			defer standIn.Close()
+		}
+		alerter, err = alert.New(alertCfg, emitter)
+		if err != nil {
+			return fmt.Errorf("failed to create alerter: %w", err)
+		}
+		emitter = alerter
+	}
+
+	// the server shuts down gracefully once the signal context is done, along with the managers
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return s.ListenAndServe(ctx, cfg.Listen.Address, tlsConfig)
+	})
+	go watchReload(ctx, hup, "kuview", cfg, args, s, auth)
+	// fails at startup on an invalid allowlist, rather than on every attempt of every cluster
+	if _, err := images.New(cfg.Images.RegistryAllowlist...); err != nil {
+		return fmt.Errorf("failed to create image analyzer: %w", err)
+	}
+	for _, c := range clusters {
+		g.Go(func() error {
+			runCluster(ctx, cfg, c, rules, emitter)
+			return nil
+		})
+	}
+	if alerter != nil {
+		g.Go(func() error {
+			return alerter.Start(ctx)
+		})
+		if cfg.Alert.Test {
+			go alerter.NotifyTest(ctx)
+		}
 	}
 
-	if err := mgr.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start controller manager: %w", err)
+	return g.Wait()
+}
+
+// controllerOptions resolves the namespaces to watch in the cluster, and the predicates of the config.
+func controllerOptions(ctx context.Context, cfg *config.Config, c cluster.Cluster) (controller.Options, error) {
+	opts := controller.Options{
+		Cluster:              c.ID,
+		Namespaces:           cfg.Namespaces,
+		ExcludeNamespaces:    cfg.Predicates.ExcludeNamespaces,
+		NodeHeartbeats:       cfg.Predicates.NodeHeartbeats,
+		FinishedPodRetention: cfg.Retention.FinishedPods.Duration,
+		MetricsInterval:      cfg.Metrics.Interval.Duration,
+	}
+	if !slices.Equal(cfg.Namespaces, []string{"auto"}) {
+		return opts, nil
 	}
 
-	return nil
+	ns, err := controller.AccessibleNamespaces(ctx, c.Config, c.Namespace)
+	if err != nil {
+		return controller.Options{}, fmt.Errorf("failed to detect namespaces: %w", err)
+	}
+	if ns == nil {
+		log.Info().Str("cluster", c.ID).Msg("watching the whole cluster")
+	} else {
+		log.Info().Str("cluster", c.ID).Strs("namespaces", ns).Msg("watching namespaces")
+	}
+	opts.Namespaces = ns
+	return opts, nil
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"github.com/iwanhae/kuview/pkg/config"
+	"github.com/iwanhae/kuview/pkg/relay"
+	"github.com/iwanhae/kuview/pkg/server"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// runRelay serves the merged streams of the upstream kuview servers, with the settings of the server.
+// Usage: kuview relay --relay-config relay.yaml [flags]
+func runRelay(ctx context.Context, cfg *config.Config, args []string) error {
+	hup := notifyReload()
+	if cfg.Relay.ConfigFile == "" {
+		return fmt.Errorf("--relay-config is required")
+	}
+
+	relayCfg, err := relay.LoadConfig(cfg.Relay.ConfigFile)
+	if err != nil {
+		return err
+	}
+	clusters, err := relayCfg.Clusters()
+	if err != nil {
+		return fmt.Errorf("invalid relay config: %w", err)
+	}
+
+	// the upstreams are kuview servers, so the tokens are reviewed by the cluster the relay runs in
+	var home cluster.Cluster
+	if cfg.Auth.TokenReview.Enabled {
+		if home, err = cluster.Current(); err != nil {
+			return err
+		}
+	}
+	auth, err := newAuthenticators(ctx, cfg, home)
+	if err != nil {
+		return fmt.Errorf("failed to configure authentication: %w", err)
+	}
+	opts, err := serverOptions(cfg, auth)
+	if err != nil {
+		return err
+	}
+	auditor, err := newAuditor(cfg.Audit)
+	if err != nil {
+		return err
+	}
+	// closed once the server has stopped, writing the last events
+	defer auditor.Close()
+	opts.Audit = auditor
+	if len(opts.Authenticators) == 0 {
+		log.Warn().Msg("authentication is disabled, anyone reaching the relay can read every object")
+	}
+	s, err := server.New(opts, clusters...)
+	if err != nil {
+		return fmt.Errorf("failed to create a new server: %w", err)
+	}
+	tlsConfig, err := serverTLSConfig(cfg.TLS)
+	if err != nil {
+		return err
+	}
+
+	r, err := relay.New(clusters, s)
+	if err != nil {
+		return fmt.Errorf("failed to create relay: %w", err)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return s.ListenAndServe(ctx, cfg.Listen.Address, tlsConfig)
+	})
+	go watchReload(ctx, hup, "kuview relay", cfg, args, s, auth)
+	g.Go(func() error {
+		return r.Start(ctx)
+	})
+	return g.Wait()
+}
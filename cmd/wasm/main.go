@@ -12,6 +12,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 )
@@ -44,6 +45,7 @@ func run(ctx context.Context) error {
 		ctx, cfg,
 		types.ObjectSchemas,
 		emitter,
+		controller.Options{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create a new controller: %w", err)
@@ -69,7 +71,14 @@ func NewJSEventEmitter() (*EventEmitter, error) {
 	return &res, nil
 }
 
+// Emit sends the event to the page, which parses it again, so the managed fields the UI does not use are left out.
 func (j *EventEmitter) Emit(v *controller.Event) {
+	if len(v.Object.GetManagedFields()) > 0 {
+		// the object may be shared with the cache of the controllers
+		copied := v.Object.DeepCopyObject().(client.Object)
+		copied.SetManagedFields(nil)
+		v = &controller.Event{Type: v.Type, Object: copied, Cluster: v.Cluster}
+	}
 	b, err := json.Marshal(v)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to marshal event")
@@ -0,0 +1,482 @@
+// Package alert notifies webhooks when objects match alerting rules for long enough.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Labels every alert has.
+const (
+	LabelAlertName = "alertname"
+	LabelKind      = "kind"
+	LabelNamespace = "namespace"
+	LabelName      = "name"
+	LabelSeverity  = "severity"
+	// LabelCluster is set when kuview watches several clusters.
+	LabelCluster = "cluster"
+)
+
+// evaluationInterval is the period at which pending alerts are checked and groups are flushed.
+const evaluationInterval = time.Second
+
+// Alert is a single alert as sent to the receivers.
+type Alert struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	StartsAt    time.Time
+	// EndsAt is zero while the alert is firing.
+	EndsAt      time.Time
+	Fingerprint string
+}
+
+func (a *Alert) Resolved() bool {
+	return !a.EndsAt.IsZero()
+}
+
+type alertState struct {
+	Alert
+	rule *rule
+	// activeSince is when the object started to match the rule.
+	activeSince time.Time
+	firing      bool
+	// notified is set once the alert has been sent as firing.
+	notified bool
+}
+
+// group batches the alerts with the same values of the grouped labels for a receiver.
+type group struct {
+	key       string
+	receiver  *receiver
+	labels    map[string]string
+	alerts    map[string]*alertState
+	createdAt time.Time
+	lastFlush time.Time
+	dirty     bool
+}
+
+// Alerter is a controller.Emitter that forwards every event to the next emitter
+// and raises alerts for the objects matching its rules.
+type Alerter struct {
+	next      controller.Emitter
+	receivers []*receiver
+	route     route
+	rules     []*rule
+	client    *http.Client
+
+	mu     sync.Mutex
+	active map[string]*alertState // keyed by rule name and controller.Event.Key
+	groups map[string]*group
+}
+
+var _ controller.Emitter = (*Alerter)(nil)
+var _ manager.Runnable = (*Alerter)(nil)
+
+func New(cfg *Config, next controller.Emitter) (*Alerter, error) {
+	receivers, route, rules, err := cfg.build()
+	if err != nil {
+		return nil, err
+	}
+	return &Alerter{
+		next:      next,
+		receivers: receivers,
+		route:     route,
+		rules:     rules,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		active:    make(map[string]*alertState),
+		groups:    make(map[string]*group),
+	}, nil
+}
+
+// Emit implements controller.Emitter.
+func (a *Alerter) Emit(v *controller.Event) {
+	a.next.Emit(v)
+
+	gvk := v.Object.GetObjectKind().GroupVersionKind()
+	var content map[string]any
+	for _, r := range a.rules {
+		if _, ok := r.kinds[gvk]; !ok {
+			continue
+		}
+		if r.namespaces != nil {
+			if _, ok := r.namespaces[v.Object.GetNamespace()]; !ok {
+				continue
+			}
+		}
+
+		matched := false
+		if v.Type == controller.EventTypeCreate {
+			if content == nil {
+				var err error
+				content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(v.Object)
+				if err != nil {
+					log.Error().Err(err).Str("rule", r.name).Msg("failed to convert object")
+					return
+				}
+			}
+			matched = r.matches(content)
+		}
+
+		key := r.name + "|" + v.Key()
+		now := time.Now()
+		a.mu.Lock()
+		st, exists := a.active[key]
+		switch {
+		case matched && !exists:
+			labels, annotations := r.describe(v, content)
+			a.active[key] = &alertState{
+				Alert: Alert{
+					Labels:      labels,
+					Annotations: annotations,
+					Fingerprint: fingerprint(labels),
+				},
+				rule:        r,
+				activeSince: now,
+			}
+		case matched && exists:
+			labels, annotations := r.describe(v, content)
+			a.update(key, st, labels, annotations, now)
+		case !matched && exists:
+			delete(a.active, key)
+			if st.firing {
+				a.resolve(st, now)
+			}
+		}
+		a.mu.Unlock()
+	}
+}
+
+// update applies the labels and annotations the alert has now. A firing alert whose labels changed,
+// e.g. escalated to another severity, is resolved and fired again as another alert,
+// and one whose annotations changed is sent again, so the receivers hear of the change.
+// It must be called with the lock held.
+func (a *Alerter) update(key string, st *alertState, labels, annotations map[string]string, now time.Time) {
+	fp := fingerprint(labels)
+	switch {
+	case !st.firing:
+		st.Labels, st.Annotations, st.Fingerprint = labels, annotations, fp
+	case fp != st.Fingerprint:
+		a.resolve(st, now)
+		next := &alertState{
+			Alert: Alert{
+				Labels:      labels,
+				Annotations: annotations,
+				StartsAt:    now,
+				Fingerprint: fp,
+			},
+			rule:        st.rule,
+			activeSince: st.activeSince,
+			firing:      true,
+		}
+		a.active[key] = next
+		a.fire(next, now)
+	case !maps.Equal(st.Annotations, annotations):
+		st.Annotations = annotations
+		for _, g := range a.groups {
+			if _, ok := g.alerts[st.Fingerprint]; ok {
+				g.dirty = true
+			}
+		}
+	}
+}
+
+// resolve marks the alert as resolved in its groups. It must be called with the lock held.
+func (a *Alerter) resolve(st *alertState, now time.Time) {
+	st.EndsAt = now
+	for _, g := range a.groups {
+		if _, ok := g.alerts[st.Fingerprint]; !ok {
+			continue
+		}
+		if !st.notified || !g.receiver.sendResolved {
+			// nobody has heard of it
+			delete(g.alerts, st.Fingerprint)
+			continue
+		}
+		g.dirty = true
+	}
+}
+
+// Start implements manager.Runnable.
+func (a *Alerter) Start(ctx context.Context) error {
+	log.Info().
+		Int("rules", len(a.rules)).
+		Int("receivers", len(a.receivers)).
+		Msg("starting alerter")
+
+	ticker := time.NewTicker(evaluationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for _, n := range a.evaluate(now) {
+				a.flush(ctx, n)
+			}
+		}
+	}
+}
+
+// notification is a snapshot of a group at the time it is flushed.
+type notification struct {
+	group    *group
+	receiver *receiver
+	labels   map[string]string
+	alerts   []Alert
+}
+
+// evaluate fires the pending alerts that have been active long enough and returns the groups due to be notified.
+func (a *Alerter) evaluate(now time.Time) []notification {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, st := range a.active {
+		if st.firing || now.Sub(st.activeSince) < st.rule.forDuration {
+			continue
+		}
+		st.firing = true
+		st.StartsAt = now
+		a.fire(st, now)
+	}
+
+	res := []notification{}
+	for key, g := range a.groups {
+		if len(g.alerts) == 0 {
+			delete(a.groups, key)
+			continue
+		}
+
+		due := false
+		switch {
+		case g.dirty && g.lastFlush.IsZero():
+			due = now.Sub(g.createdAt) >= a.route.groupWait
+		case g.dirty:
+			due = now.Sub(g.lastFlush) >= a.route.groupInterval
+		default:
+			due = !g.lastFlush.IsZero() && now.Sub(g.lastFlush) >= a.route.repeatInterval
+		}
+		if !due {
+			continue
+		}
+
+		n := notification{group: g, receiver: g.receiver, labels: g.labels}
+		for _, st := range g.alerts {
+			n.alerts = append(n.alerts, st.Alert)
+		}
+		sort.Slice(n.alerts, func(i, j int) bool {
+			return n.alerts[i].Fingerprint < n.alerts[j].Fingerprint
+		})
+		g.lastFlush = now
+		g.dirty = false
+		res = append(res, n)
+	}
+	return res
+}
+
+func (a *Alerter) flush(ctx context.Context, n notification) {
+	err := a.send(ctx, n)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		log.Error().Err(err).
+			Str("receiver", n.receiver.name).
+			Str("group", n.group.key).
+			Msg("failed to send notification, retrying at the next group interval")
+		n.group.dirty = true
+		return
+	}
+	for _, sent := range n.alerts {
+		st, ok := n.group.alerts[sent.Fingerprint]
+		if !ok {
+			continue
+		}
+		if sent.Resolved() && st.Resolved() {
+			delete(n.group.alerts, sent.Fingerprint)
+		} else {
+			st.notified = true
+		}
+	}
+}
+
+// fire adds the firing alert to the groups of its receivers. It must be called with the lock held.
+func (a *Alerter) fire(st *alertState, now time.Time) {
+	for _, recv := range a.receivers {
+		if !st.rule.notifies(recv) {
+			continue
+		}
+		g := a.groupFor(recv, st.Labels, now)
+		g.alerts[st.Fingerprint] = st
+		g.dirty = true
+	}
+}
+
+// groupFor returns the group of the receiver the labels belong to, creating it if needed.
+// It must be called with the lock held.
+func (a *Alerter) groupFor(recv *receiver, labels map[string]string, now time.Time) *group {
+	groupLabels := make(map[string]string, len(a.route.groupBy))
+	values := make([]string, 0, len(a.route.groupBy)+1)
+	values = append(values, recv.name)
+	for _, name := range a.route.groupBy {
+		groupLabels[name] = labels[name]
+		values = append(values, name+"="+labels[name])
+	}
+	key := strings.Join(values, ",")
+
+	g, ok := a.groups[key]
+	if !ok {
+		g = &group{
+			key:       key,
+			receiver:  recv,
+			labels:    groupLabels,
+			alerts:    make(map[string]*alertState),
+			createdAt: now,
+		}
+		a.groups[key] = g
+	}
+	return g
+}
+
+func (r *rule) notifies(recv *receiver) bool {
+	if len(r.receivers) == 0 {
+		return true
+	}
+	for _, name := range r.receivers {
+		if name == recv.name {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the object satisfies every condition of the rule.
+func (r *rule) matches(content map[string]any) bool {
+	if r.condition != nil && !matchCondition(content, r.condition) {
+		return false
+	}
+	if r.expression != nil {
+		out, _, err := r.expression.Eval(map[string]any{
+			"object": content,
+			"now":    time.Now(),
+		})
+		if err != nil {
+			log.Debug().Err(err).Str("rule", r.name).Msg("failed to evaluate expression")
+			return false
+		}
+		if matched, ok := out.Value().(bool); !ok || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func matchCondition(content map[string]any, m *ConditionMatch) bool {
+	conditions, _, _ := unstructured.NestedSlice(content, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]any)
+		if !ok || cond["type"] != m.Type {
+			continue
+		}
+		if m.Status != "" && cond["status"] != m.Status {
+			return false
+		}
+		if m.Reason != "" && cond["reason"] != m.Reason {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// describe returns the labels and annotations of the alert raised for the object of the event.
+func (r *rule) describe(v *controller.Event, content map[string]any) (map[string]string, map[string]string) {
+	obj := v.Object
+	labels := map[string]string{
+		LabelAlertName: r.name,
+		LabelKind:      obj.GetObjectKind().GroupVersionKind().Kind,
+		LabelName:      obj.GetName(),
+	}
+	if ns := obj.GetNamespace(); ns != "" {
+		labels[LabelNamespace] = ns
+	}
+	if v.Cluster != "" {
+		labels[LabelCluster] = v.Cluster
+	}
+	annotations := map[string]string{}
+
+	summary := r.summary
+	if f, ok := obj.(*types.Finding); ok {
+		// describe the affected object rather than the finding itself
+		labels[LabelSeverity] = string(f.Spec.Severity)
+		labels[LabelKind] = f.Spec.Object.Kind
+		labels[LabelName] = f.Spec.Object.Name
+		labels["rule"] = f.Spec.Rule
+		if summary == "" {
+			summary = f.Spec.Message
+		}
+		if f.Spec.Remediation != "" {
+			annotations["remediation"] = f.Spec.Remediation
+		}
+	}
+	for k, v := range r.labels {
+		labels[k] = v
+	}
+
+	if r.summaryExpr != nil {
+		out, _, err := r.summaryExpr.Eval(map[string]any{
+			"object": content,
+			"now":    time.Now(),
+		})
+		if err == nil {
+			if s, ok := out.Value().(string); ok {
+				summary = s
+			}
+		}
+	}
+	if summary == "" {
+		summary = fmt.Sprintf("%s %s matches %s", labels[LabelKind], objectName(obj), r.name)
+	}
+	annotations["summary"] = summary
+
+	return labels, annotations
+}
+
+func objectName(obj client.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// fingerprint identifies an alert by its labels.
+func fingerprint(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0xff})
+		h.Write([]byte(labels[k]))
+		h.Write([]byte{0xff})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
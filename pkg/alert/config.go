@@ -0,0 +1,259 @@
+package alert
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/iwanhae/kuview/pkg/expr"
+	"github.com/iwanhae/kuview/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Config defines where notifications are sent and which objects raise alerts.
+//
+//	receivers:
+//	  - name: oncall
+//	    url: https://hooks.slack.com/services/...
+//	    format: slack
+//	route:
+//	  groupBy: [alertname, namespace]
+//	rules:
+//	  - name: NodeNotReady
+//	    kinds: ["v1/Node"]
+//	    condition: {type: Ready, status: "False"}
+//	    for: 5m
+type Config struct {
+	Receivers []ReceiverConfig `json:"receivers"`
+	Route     RouteConfig      `json:"route,omitempty"`
+	Rules     []RuleConfig     `json:"rules"`
+}
+
+// Format is the payload format of a receiver.
+type Format string
+
+const (
+	// FormatGeneric posts the webhook payload of Alertmanager, which many tools accept.
+	FormatGeneric Format = "generic"
+	// FormatSlack posts a message to a Slack incoming webhook.
+	FormatSlack Format = "slack"
+	// FormatAlertmanager posts the alerts to the API of an Alertmanager, e.g. http://alertmanager:9093/api/v2/alerts
+	FormatAlertmanager Format = "alertmanager"
+)
+
+type ReceiverConfig struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Format defaults to generic.
+	Format  Format            `json:"format,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// SendResolved controls whether resolved alerts are notified. Defaults to true.
+	SendResolved *bool `json:"sendResolved,omitempty"`
+}
+
+// RouteConfig controls how alerts are batched into notifications, following the semantics of Alertmanager.
+type RouteConfig struct {
+	// GroupBy lists the labels alerts are grouped by. Defaults to [alertname].
+	GroupBy []string `json:"groupBy,omitempty"`
+	// GroupWait is how long to wait for more alerts before notifying a new group. Defaults to 30s.
+	GroupWait metav1.Duration `json:"groupWait,omitempty"`
+	// GroupInterval is how long to wait before notifying about changes in a group. Defaults to 5m.
+	GroupInterval metav1.Duration `json:"groupInterval,omitempty"`
+	// RepeatInterval is how long to wait before notifying again about a group that did not change. Defaults to 4h.
+	RepeatInterval metav1.Duration `json:"repeatInterval,omitempty"`
+}
+
+// RuleConfig raises an alert for every object matching all of its conditions.
+type RuleConfig struct {
+	// Name is the alertname label of the alerts.
+	Name string `json:"name"`
+	// Kinds are the kinds the rule applies to, in the apiVersion/kind notation. e.g. "kuview.iwanhae.kr/v1/Finding"
+	Kinds []string `json:"kinds"`
+	// Namespaces restricts the rule to the objects in these namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Condition matches an entry of status.conditions of the object.
+	Condition *ConditionMatch `json:"condition,omitempty"`
+	// Expression is a CEL expression that evaluates to true when the alert should fire.
+	Expression string `json:"expression,omitempty"`
+	// For is how long the object has to match before the alert fires.
+	For metav1.Duration `json:"for,omitempty"`
+	// Labels are added to the labels of the alerts.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Summary describes the alert. SummaryExpression takes precedence if it evaluates successfully.
+	Summary           string `json:"summary,omitempty"`
+	SummaryExpression string `json:"summaryExpression,omitempty"`
+	// Receivers are the names of the receivers to notify. Defaults to all receivers.
+	Receivers []string `json:"receivers,omitempty"`
+}
+
+type ConditionMatch struct {
+	Type   string `json:"type"`
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// LoadConfig reads the configuration from a YAML file.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert config: %w", err)
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse alert config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+type receiver struct {
+	name         string
+	url          string
+	format       Format
+	headers      map[string]string
+	sendResolved bool
+}
+
+type route struct {
+	groupBy        []string
+	groupWait      time.Duration
+	groupInterval  time.Duration
+	repeatInterval time.Duration
+}
+
+type rule struct {
+	name        string
+	kinds       map[schema.GroupVersionKind]struct{}
+	namespaces  map[string]struct{}
+	condition   *ConditionMatch
+	expression  cel.Program
+	forDuration time.Duration
+	labels      map[string]string
+	summary     string
+	summaryExpr cel.Program
+	receivers   []string
+}
+
+func (c *Config) build() ([]*receiver, route, []*rule, error) {
+	receivers := make([]*receiver, 0, len(c.Receivers))
+	names := make(map[string]struct{}, len(c.Receivers))
+	for _, rc := range c.Receivers {
+		if rc.Name == "" {
+			return nil, route{}, nil, fmt.Errorf("receiver name is required")
+		}
+		if _, ok := names[rc.Name]; ok {
+			return nil, route{}, nil, fmt.Errorf("duplicated receiver %q", rc.Name)
+		}
+		names[rc.Name] = struct{}{}
+		if u, err := url.Parse(rc.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, route{}, nil, fmt.Errorf("receiver %q: invalid url %q", rc.Name, rc.URL)
+		}
+		format := rc.Format
+		switch format {
+		case "":
+			format = FormatGeneric
+		case FormatGeneric, FormatSlack, FormatAlertmanager:
+		default:
+			return nil, route{}, nil, fmt.Errorf("receiver %q: unknown format %q", rc.Name, format)
+		}
+		receivers = append(receivers, &receiver{
+			name:         rc.Name,
+			url:          rc.URL,
+			format:       format,
+			headers:      rc.Headers,
+			sendResolved: rc.SendResolved == nil || *rc.SendResolved,
+		})
+	}
+
+	r := route{
+		groupBy:        c.Route.GroupBy,
+		groupWait:      c.Route.GroupWait.Duration,
+		groupInterval:  c.Route.GroupInterval.Duration,
+		repeatInterval: c.Route.RepeatInterval.Duration,
+	}
+	if len(r.groupBy) == 0 {
+		r.groupBy = []string{LabelAlertName}
+	}
+	if r.groupWait == 0 {
+		r.groupWait = 30 * time.Second
+	}
+	if r.groupInterval == 0 {
+		r.groupInterval = 5 * time.Minute
+	}
+	if r.repeatInterval == 0 {
+		r.repeatInterval = 4 * time.Hour
+	}
+
+	env, err := expr.NewEnv()
+	if err != nil {
+		return nil, route{}, nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	rules := make([]*rule, 0, len(c.Rules))
+	for _, rc := range c.Rules {
+		ru, err := rc.build(env, names)
+		if err != nil {
+			return nil, route{}, nil, fmt.Errorf("invalid rule %q: %w", rc.Name, err)
+		}
+		rules = append(rules, ru)
+	}
+
+	return receivers, r, rules, nil
+}
+
+func (rc RuleConfig) build(env *cel.Env, receivers map[string]struct{}) (*rule, error) {
+	if rc.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(rc.Kinds) == 0 {
+		return nil, fmt.Errorf("at least one kind is required")
+	}
+	if rc.Condition != nil && rc.Condition.Type == "" {
+		return nil, fmt.Errorf("condition type is required")
+	}
+
+	r := &rule{
+		name:        rc.Name,
+		kinds:       make(map[schema.GroupVersionKind]struct{}, len(rc.Kinds)),
+		condition:   rc.Condition,
+		forDuration: rc.For.Duration,
+		labels:      rc.Labels,
+		summary:     rc.Summary,
+		receivers:   rc.Receivers,
+	}
+	for _, k := range rc.Kinds {
+		gvk, err := types.ParseGVK(k)
+		if err != nil {
+			return nil, err
+		}
+		r.kinds[gvk] = struct{}{}
+	}
+	if len(rc.Namespaces) > 0 {
+		r.namespaces = make(map[string]struct{}, len(rc.Namespaces))
+		for _, ns := range rc.Namespaces {
+			r.namespaces[ns] = struct{}{}
+		}
+	}
+	for _, name := range rc.Receivers {
+		if _, ok := receivers[name]; !ok {
+			return nil, fmt.Errorf("unknown receiver %q", name)
+		}
+	}
+
+	var err error
+	if rc.Expression != "" {
+		r.expression, err = expr.Compile(env, rc.Expression, cel.BoolType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile expression: %w", err)
+		}
+	}
+	if rc.SummaryExpression != "" {
+		r.summaryExpr, err = expr.Compile(env, rc.SummaryExpression, cel.StringType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile summaryExpression: %w", err)
+		}
+	}
+	return r, nil
+}
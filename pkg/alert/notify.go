@@ -0,0 +1,211 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (a *Alerter) send(ctx context.Context, n notification) error {
+	var payload any
+	switch n.receiver.format {
+	case FormatSlack:
+		payload = slackPayload(n)
+	case FormatAlertmanager:
+		payload = alertmanagerPayload(n, a.route.repeatInterval)
+	default:
+		payload = webhookPayload(n)
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.receiver.url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kuview")
+	for k, v := range n.receiver.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+func status(alerts []Alert) string {
+	for _, alert := range alerts {
+		if !alert.Resolved() {
+			return "firing"
+		}
+	}
+	return "resolved"
+}
+
+// webhookMessage is the payload of the webhook receiver of Alertmanager.
+// https://prometheus.io/docs/alerting/latest/configuration/#webhook_config
+type webhookMessage struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []webhookAlert    `json:"alerts"`
+}
+
+type webhookAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+func webhookPayload(n notification) webhookMessage {
+	msg := webhookMessage{
+		Version:           "4",
+		GroupKey:          n.group.key,
+		Status:            status(n.alerts),
+		Receiver:          n.receiver.name,
+		GroupLabels:       n.labels,
+		CommonLabels:      common(n.alerts, func(a Alert) map[string]string { return a.Labels }),
+		CommonAnnotations: common(n.alerts, func(a Alert) map[string]string { return a.Annotations }),
+		Alerts:            make([]webhookAlert, 0, len(n.alerts)),
+	}
+	for _, alert := range n.alerts {
+		s := "firing"
+		if alert.Resolved() {
+			s = "resolved"
+		}
+		msg.Alerts = append(msg.Alerts, webhookAlert{
+			Status:      s,
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartsAt:    alert.StartsAt,
+			EndsAt:      alert.EndsAt,
+			Fingerprint: alert.Fingerprint,
+		})
+	}
+	return msg
+}
+
+// postableAlert is an alert as accepted by POST /api/v2/alerts of Alertmanager.
+type postableAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+func alertmanagerPayload(n notification, repeatInterval time.Duration) []postableAlert {
+	alerts := make([]postableAlert, 0, len(n.alerts))
+	for _, alert := range n.alerts {
+		endsAt := alert.EndsAt
+		if endsAt.IsZero() {
+			// Alertmanager resolves alerts that are not sent again before they end.
+			// Keep them alive until well after the next repeat.
+			endsAt = time.Now().Add(3 * repeatInterval)
+		}
+		alerts = append(alerts, postableAlert{
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartsAt:    alert.StartsAt,
+			EndsAt:      endsAt,
+		})
+	}
+	return alerts
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string `json:"color"`
+	Title  string `json:"title"`
+	Text   string `json:"text"`
+	Footer string `json:"footer,omitempty"`
+}
+
+func slackPayload(n notification) slackMessage {
+	firing, resolved := 0, 0
+	for _, alert := range n.alerts {
+		if alert.Resolved() {
+			resolved++
+		} else {
+			firing++
+		}
+	}
+
+	groupValues := make([]string, 0, len(n.labels))
+	for k, v := range n.labels {
+		groupValues = append(groupValues, k+"="+v)
+	}
+	sort.Strings(groupValues)
+	title := strings.Join(groupValues, " ")
+
+	msg := slackMessage{}
+	if firing > 0 {
+		msg.Text = fmt.Sprintf("[FIRING:%d] %s", firing, title)
+	} else {
+		msg.Text = fmt.Sprintf("[RESOLVED] %s", title)
+	}
+	for _, alert := range n.alerts {
+		att := slackAttachment{
+			Color: "danger",
+			Title: alert.Labels[LabelAlertName],
+			Text:  alert.Annotations["summary"],
+		}
+		if alert.Resolved() {
+			att.Color = "good"
+			att.Title = "[RESOLVED] " + att.Title
+		}
+		if remediation := alert.Annotations["remediation"]; remediation != "" {
+			att.Footer = remediation
+		}
+		msg.Attachments = append(msg.Attachments, att)
+	}
+	return msg
+}
+
+// common returns the key/value pairs shared by every alert.
+func common(alerts []Alert, get func(Alert) map[string]string) map[string]string {
+	res := map[string]string{}
+	if len(alerts) == 0 {
+		return res
+	}
+	for k, v := range get(alerts[0]) {
+		res[k] = v
+	}
+	for _, alert := range alerts[1:] {
+		m := get(alert)
+		for k, v := range res {
+			if m[k] != v {
+				delete(res, k)
+			}
+		}
+	}
+	return res
+}
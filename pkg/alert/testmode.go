@@ -0,0 +1,63 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// StartStandIn starts a local HTTP server that logs every notification it receives,
+// and points every receiver of the configuration to it instead of the real webhooks.
+// It lets the rules and payloads be tried out without notifying anybody.
+func StartStandIn(cfg *Config) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		indented := &bytes.Buffer{}
+		if err := json.Indent(indented, body, "", "  "); err != nil {
+			indented = bytes.NewBuffer(body)
+		}
+		log.Info().
+			Str("receiver", r.URL.Path[1:]).
+			Msgf("stand-in received a notification:\n%s", indented)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := range cfg.Receivers {
+		cfg.Receivers[i].URL = srv.URL + "/" + cfg.Receivers[i].Name
+	}
+	log.Info().Str("url", srv.URL).Msg("alert test mode: notifications are sent to a local stand-in")
+	return srv
+}
+
+// NotifyTest sends a firing test alert to every receiver, bypassing the rules and the grouping.
+func (a *Alerter) NotifyTest(ctx context.Context) {
+	labels := map[string]string{
+		LabelAlertName: "KuviewTestAlert",
+		LabelSeverity:  "Low",
+	}
+	alert := Alert{
+		Labels:      labels,
+		Annotations: map[string]string{"summary": "This is a test notification sent by kuview."},
+		StartsAt:    time.Now(),
+		Fingerprint: fingerprint(labels),
+	}
+	for _, recv := range a.receivers {
+		n := notification{
+			group:    &group{key: "test"},
+			receiver: recv,
+			labels:   map[string]string{LabelAlertName: "KuviewTestAlert"},
+			alerts:   []Alert{alert},
+		}
+		if err := a.send(ctx, n); err != nil {
+			log.Error().Err(err).Str("receiver", recv.name).Msg("failed to send test notification")
+			continue
+		}
+		log.Info().Str("receiver", recv.name).Msg("test notification sent")
+	}
+}
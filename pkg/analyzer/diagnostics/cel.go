@@ -0,0 +1,59 @@
+package diagnostics
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// celRule is a custom rule defined by a CEL expression.
+type celRule struct {
+	name        string
+	kind        schema.GroupVersionKind
+	severity    types.Severity
+	expression  cel.Program
+	message     string
+	messageExpr cel.Program
+	remediation string
+}
+
+func (r *celRule) Name() string                  { return r.name }
+func (r *celRule) Kind() schema.GroupVersionKind { return r.kind }
+
+func (r *celRule) Evaluate(ctx *Context, obj client.Object) []Problem {
+	content, err := ctx.unstructured(obj)
+	if err != nil {
+		log.Error().Err(err).Str("rule", r.name).Msg("failed to convert object")
+		return nil
+	}
+	vars := map[string]any{
+		"object": content,
+		"now":    ctx.Now,
+	}
+
+	out, _, err := r.expression.ContextEval(ctx, vars)
+	if err != nil {
+		// most likely a field missing on this object, which is not a problem
+		log.Debug().Err(err).Str("rule", r.name).Str("name", obj.GetName()).Msg("failed to evaluate expression")
+		return nil
+	}
+	if matched, ok := out.Value().(bool); !ok || !matched {
+		return nil
+	}
+
+	msg := r.message
+	if r.messageExpr != nil {
+		if out, _, err := r.messageExpr.ContextEval(ctx, vars); err == nil {
+			if s, ok := out.Value().(string); ok {
+				msg = s
+			}
+		}
+	}
+	return []Problem{{
+		Severity:    r.severity,
+		Message:     msg,
+		Remediation: r.remediation,
+	}}
+}
@@ -0,0 +1,174 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/iwanhae/kuview/pkg/expr"
+	"github.com/iwanhae/kuview/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// errUnknownKind rejects the rules that would never fire.
+var errUnknownKind = errors.New("the kind is not watched, or is produced by the analyzers")
+
+// DefaultPendingThreshold is used when the configuration does not set one.
+const DefaultPendingThreshold = 5 * time.Minute
+
+// Config configures the built-in rules and defines custom rules.
+type Config struct {
+	// PendingThreshold is how long a Pod or PersistentVolumeClaim may be pending before it is reported.
+	PendingThreshold metav1.Duration `json:"pendingThreshold,omitempty"`
+	// Disabled lists the names of the built-in rules to turn off.
+	Disabled []string `json:"disabled,omitempty"`
+	// Rules are custom rules.
+	Rules []RuleConfig `json:"rules,omitempty"`
+}
+
+// RuleConfig defines a custom rule with CEL expressions.
+//
+//	name: host-network
+//	kinds: ["v1/Pod"]
+//	severity: Medium
+//	expression: has(object.spec.hostNetwork) && object.spec.hostNetwork
+//	messageExpression: '"pod " + object.metadata.name + " shares the network namespace of the node"'
+type RuleConfig struct {
+	Name string `json:"name"`
+	// Kinds are the kinds the rule is evaluated against, in the apiVersion/kind notation. e.g. "v1/Pod"
+	// They must be kinds kuview watches or produces, e.g. "kuview.iwanhae.kr/v1/Finding".
+	Kinds []string `json:"kinds"`
+	// Severity defaults to Medium.
+	Severity types.Severity `json:"severity,omitempty"`
+	// Expression evaluates to true when the object has the problem.
+	Expression string `json:"expression"`
+	// Message describes the problem. MessageExpression takes precedence if it evaluates successfully.
+	Message           string `json:"message,omitempty"`
+	MessageExpression string `json:"messageExpression,omitempty"`
+	Remediation       string `json:"remediation,omitempty"`
+}
+
+// LoadConfig reads the configuration from a YAML file.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read diagnostics config: %w", err)
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse diagnostics config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// BuildRules returns the enabled built-in rules and the compiled custom rules.
+// The custom rules may only be about the kinds the analyzers observe, given the watched objects.
+// A nil Config returns the built-in rules with the default settings.
+func (c *Config) BuildRules(watched []client.Object) ([]Rule, error) {
+	if c == nil {
+		c = &Config{}
+	}
+
+	threshold := c.PendingThreshold.Duration
+	if threshold == 0 {
+		threshold = DefaultPendingThreshold
+	}
+
+	builtin := BuiltinRules(threshold)
+	names := make(map[string]struct{}, len(builtin))
+	for _, r := range builtin {
+		names[r.Name()] = struct{}{}
+	}
+	disabled := make(map[string]struct{}, len(c.Disabled))
+	for _, name := range c.Disabled {
+		if _, ok := names[name]; !ok {
+			return nil, fmt.Errorf("cannot disable unknown built-in rule %q", name)
+		}
+		disabled[name] = struct{}{}
+	}
+
+	rules := []Rule{}
+	for _, r := range builtin {
+		if _, ok := disabled[r.Name()]; !ok {
+			rules = append(rules, r)
+		}
+	}
+
+	env, err := expr.NewEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	for _, rc := range c.Rules {
+		custom, err := rc.build(env, watched)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rc.Name, err)
+		}
+		if _, ok := names[rc.Name]; ok {
+			return nil, fmt.Errorf("invalid rule %q: name is used by a built-in rule", rc.Name)
+		}
+		names[rc.Name] = struct{}{}
+		rules = append(rules, custom...)
+	}
+
+	return rules, nil
+}
+
+func (rc RuleConfig) build(env *cel.Env, watched []client.Object) ([]Rule, error) {
+	if errs := validation.IsDNS1123Label(rc.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("name must be a DNS label: %v", errs)
+	}
+	if len(rc.Kinds) == 0 {
+		return nil, fmt.Errorf("at least one kind is required")
+	}
+
+	severity := rc.Severity
+	switch severity {
+	case "":
+		severity = types.SeverityMedium
+	case types.SeverityCritical, types.SeverityHigh, types.SeverityMedium, types.SeverityLow:
+	default:
+		return nil, fmt.Errorf("unknown severity %q", severity)
+	}
+
+	prg, err := expr.Compile(env, rc.Expression, cel.BoolType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile expression: %w", err)
+	}
+	var msgPrg cel.Program
+	if rc.MessageExpression != "" {
+		msgPrg, err = expr.Compile(env, rc.MessageExpression, cel.StringType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile messageExpression: %w", err)
+		}
+	}
+	message := rc.Message
+	if message == "" {
+		message = fmt.Sprintf("object matches the rule %s", rc.Name)
+	}
+
+	rules := make([]Rule, 0, len(rc.Kinds))
+	for _, k := range rc.Kinds {
+		gvk, err := types.ParseGVK(k)
+		if err != nil {
+			return nil, err
+		}
+		if !types.AnalyzableGVK(gvk, watched) {
+			return nil, fmt.Errorf("failed to evaluate kind %q: %w", k, errUnknownKind)
+		}
+		rules = append(rules, &celRule{
+			name:        rc.Name,
+			kind:        gvk,
+			severity:    severity,
+			expression:  prg,
+			message:     message,
+			messageExpr: msgPrg,
+			remediation: rc.Remediation,
+		})
+	}
+	return rules, nil
+}
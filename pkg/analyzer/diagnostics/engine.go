@@ -0,0 +1,195 @@
+// Package diagnostics evaluates rules against the observed objects and emits the problems found as Finding objects.
+package diagnostics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/analyzer"
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var findingGVK = types.KuviewGroupVersion.WithKind("Finding")
+
+// Rule detects a kind of problem on the objects of a single kind.
+type Rule interface {
+	// Name identifies the rule. It must be a valid DNS label, as it is part of the names of the findings.
+	Name() string
+	// Kind is the kind of the objects the rule is evaluated against.
+	Kind() schema.GroupVersionKind
+	// Evaluate returns the problems of the object. It returns nothing if the object is healthy.
+	Evaluate(ctx *Context, obj client.Object) []Problem
+}
+
+// dependent is implemented by rules that look up objects of other kinds while evaluating.
+type dependent interface {
+	DependsOn() []schema.GroupVersionKind
+}
+
+// Problem is a single violation of a rule.
+type Problem struct {
+	// Detail distinguishes the problems reported by a rule for the same object. e.g. the container name
+	Detail      string
+	Severity    types.Severity
+	Message     string
+	Remediation string
+}
+
+// Context is passed to the rules on every evaluation.
+type Context struct {
+	context.Context
+	Store analyzer.Store
+	// Now is the time of the evaluation. Rules must use it instead of time.Now,
+	// so that every rule sees the same point in time.
+	Now time.Time
+
+	engine *Engine
+	// readyServices are the services with a ready endpoint, keyed by namespace/name, indexed on first use
+	readyServices map[string]bool
+}
+
+// Engine is an analyzer.Analyzer that runs every rule against every object of its kind.
+type Engine struct {
+	rules []Rule
+
+	// the time each finding was first observed, keyed by controller.ObjectKey of the finding
+	firstSeen map[string]metav1.Time
+	// objects converted to unstructured for expression rules, keyed by controller.ObjectKey
+	converted map[string]convertedObject
+	touched   map[string]struct{}
+}
+
+type convertedObject struct {
+	resourceVersion string
+	content         map[string]any
+}
+
+var _ analyzer.Analyzer = (*Engine)(nil)
+
+func New(rules ...Rule) *Engine {
+	return &Engine{
+		rules:     rules,
+		firstSeen: make(map[string]metav1.Time),
+		converted: make(map[string]convertedObject),
+		touched:   make(map[string]struct{}),
+	}
+}
+
+func (e *Engine) Name() string {
+	return "diagnostics"
+}
+
+func (e *Engine) Watches() []schema.GroupVersionKind {
+	seen := make(map[schema.GroupVersionKind]struct{})
+	res := []schema.GroupVersionKind{}
+	add := func(gvk schema.GroupVersionKind) {
+		if _, ok := seen[gvk]; ok {
+			return
+		}
+		seen[gvk] = struct{}{}
+		res = append(res, gvk)
+	}
+	for _, r := range e.rules {
+		add(r.Kind())
+		if d, ok := r.(dependent); ok {
+			for _, gvk := range d.DependsOn() {
+				add(gvk)
+			}
+		}
+	}
+	return res
+}
+
+func (e *Engine) Analyze(ctx context.Context, store analyzer.Store) []client.Object {
+	ectx := &Context{
+		Context: ctx,
+		Store:   store,
+		Now:     time.Now(),
+		engine:  e,
+	}
+
+	firstSeen := make(map[string]metav1.Time)
+	res := []client.Object{}
+	for _, rule := range e.rules {
+		for _, obj := range store.List(rule.Kind()) {
+			for _, p := range rule.Evaluate(ectx, obj) {
+				f := newFinding(rule, obj, p)
+				key := controller.ObjectKey(f)
+				since, ok := e.firstSeen[key]
+				if !ok {
+					since = metav1.NewTime(ectx.Now)
+				}
+				firstSeen[key] = since
+				f.CreationTimestamp = since
+				res = append(res, f)
+			}
+		}
+	}
+	e.firstSeen = firstSeen
+
+	// forget the converted objects which were not used in this run
+	for key := range e.converted {
+		if _, ok := e.touched[key]; !ok {
+			delete(e.converted, key)
+		}
+	}
+	e.touched = make(map[string]struct{})
+
+	return res
+}
+
+func newFinding(rule Rule, obj client.Object, p Problem) *types.Finding {
+	gvk := rule.Kind()
+	name := rule.Name() + "." + gvk.Kind + "." + obj.GetName()
+	if p.Detail != "" {
+		name += "." + p.Detail
+	}
+	return &types.Finding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: findingGVK.GroupVersion().String(),
+			Kind:       findingGVK.Kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: obj.GetNamespace(),
+			Name:      strings.ToLower(name),
+		},
+		Spec: types.FindingSpec{
+			Rule:     rule.Name(),
+			Severity: p.Severity,
+			Object: types.ObjectReference{
+				APIVersion: gvk.GroupVersion().String(),
+				Kind:       gvk.Kind,
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+			},
+			Message:     p.Message,
+			Remediation: p.Remediation,
+		},
+	}
+}
+
+// unstructured returns the object as a map, reusing the previous conversion if the object did not change.
+func (c *Context) unstructured(obj client.Object) (map[string]any, error) {
+	e := c.engine
+	key := controller.ObjectKey(obj)
+	e.touched[key] = struct{}{}
+
+	if v, ok := e.converted[key]; ok && v.resourceVersion != "" && v.resourceVersion == obj.GetResourceVersion() {
+		return v.content, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	e.converted[key] = convertedObject{
+		resourceVersion: obj.GetResourceVersion(),
+		content:         content,
+	}
+	return content, nil
+}
@@ -0,0 +1,246 @@
+package diagnostics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/analyzer"
+	"github.com/iwanhae/kuview/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	podGVK           = v1.SchemeGroupVersion.WithKind("Pod")
+	nodeGVK          = v1.SchemeGroupVersion.WithKind("Node")
+	serviceGVK       = v1.SchemeGroupVersion.WithKind("Service")
+	pvcGVK           = v1.SchemeGroupVersion.WithKind("PersistentVolumeClaim")
+	endpointSliceGVK = discoveryv1.SchemeGroupVersion.WithKind("EndpointSlice")
+)
+
+// BuiltinRules returns the rules shipped with kuview.
+// Pods and PersistentVolumeClaims are reported as pending once they have been pending longer than pendingThreshold.
+func BuiltinRules(pendingThreshold time.Duration) []Rule {
+	return []Rule{
+		&containerWaitingRule{
+			name:        "crash-loop-back-off",
+			reasons:     []string{"CrashLoopBackOff"},
+			severity:    types.SeverityHigh,
+			remediation: "Check the logs of the previous run of the container (kubectl logs --previous) and its exit code.",
+		},
+		&containerWaitingRule{
+			name:        "image-pull-back-off",
+			reasons:     []string{"ImagePullBackOff", "ErrImagePull"},
+			severity:    types.SeverityHigh,
+			remediation: "Check that the image name and tag exist and that the node can authenticate to the registry (imagePullSecrets).",
+		},
+		&podPendingRule{threshold: pendingThreshold},
+		&pvcUnboundRule{threshold: pendingThreshold},
+		&serviceWithoutEndpointsRule{},
+		&nodeNotReadyRule{},
+		&missingRequestsRule{},
+	}
+}
+
+// containerWaitingRule reports containers waiting for one of the reasons.
+type containerWaitingRule struct {
+	name        string
+	reasons     []string
+	severity    types.Severity
+	remediation string
+}
+
+func (r *containerWaitingRule) Name() string                  { return r.name }
+func (r *containerWaitingRule) Kind() schema.GroupVersionKind { return podGVK }
+
+func (r *containerWaitingRule) Evaluate(ctx *Context, obj client.Object) []Problem {
+	pod := obj.(*v1.Pod)
+	problems := []Problem{}
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		if cs.State.Waiting == nil {
+			continue
+		}
+		for _, reason := range r.reasons {
+			if cs.State.Waiting.Reason != reason {
+				continue
+			}
+			msg := fmt.Sprintf("container %s is in %s", cs.Name, reason)
+			if cs.State.Waiting.Message != "" {
+				msg += ": " + cs.State.Waiting.Message
+			}
+			problems = append(problems, Problem{
+				Detail:      cs.Name,
+				Severity:    r.severity,
+				Message:     msg,
+				Remediation: r.remediation,
+			})
+		}
+	}
+	return problems
+}
+
+// podPendingRule reports pods pending longer than the threshold.
+type podPendingRule struct {
+	threshold time.Duration
+}
+
+func (r *podPendingRule) Name() string                  { return "pod-pending" }
+func (r *podPendingRule) Kind() schema.GroupVersionKind { return podGVK }
+
+func (r *podPendingRule) Evaluate(ctx *Context, obj client.Object) []Problem {
+	pod := obj.(*v1.Pod)
+	if pod.Status.Phase != v1.PodPending || ctx.Now.Sub(pod.CreationTimestamp.Time) < r.threshold {
+		return nil
+	}
+
+	msg := fmt.Sprintf("pod has been pending for more than %s", r.threshold)
+	remediation := "Check the events of the pod for the reason it cannot start."
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse {
+			msg += fmt.Sprintf(": %s: %s", c.Reason, c.Message)
+			remediation = "Check that a node matches the requests, node selectors, affinities and tolerations of the pod."
+		}
+	}
+	return []Problem{{
+		Severity:    types.SeverityMedium,
+		Message:     msg,
+		Remediation: remediation,
+	}}
+}
+
+// pvcUnboundRule reports claims that are lost or pending longer than the threshold.
+type pvcUnboundRule struct {
+	threshold time.Duration
+}
+
+func (r *pvcUnboundRule) Name() string                  { return "pvc-unbound" }
+func (r *pvcUnboundRule) Kind() schema.GroupVersionKind { return pvcGVK }
+
+func (r *pvcUnboundRule) Evaluate(ctx *Context, obj client.Object) []Problem {
+	pvc := obj.(*v1.PersistentVolumeClaim)
+	switch pvc.Status.Phase {
+	case v1.ClaimLost:
+		return []Problem{{
+			Severity:    types.SeverityHigh,
+			Message:     fmt.Sprintf("claim lost its volume %s", pvc.Spec.VolumeName),
+			Remediation: "The bound PersistentVolume was deleted. Restore the volume or recreate the claim.",
+		}}
+	case v1.ClaimPending:
+		if ctx.Now.Sub(pvc.CreationTimestamp.Time) < r.threshold {
+			return nil
+		}
+		return []Problem{{
+			Severity:    types.SeverityMedium,
+			Message:     fmt.Sprintf("claim has been unbound for more than %s", r.threshold),
+			Remediation: "Check that the StorageClass exists and its provisioner is running, or that a matching PersistentVolume is available.",
+		}}
+	}
+	return nil
+}
+
+// serviceWithoutEndpointsRule reports services whose selector matches no ready endpoint.
+type serviceWithoutEndpointsRule struct{}
+
+func (r *serviceWithoutEndpointsRule) Name() string                  { return "service-no-endpoints" }
+func (r *serviceWithoutEndpointsRule) Kind() schema.GroupVersionKind { return serviceGVK }
+func (r *serviceWithoutEndpointsRule) DependsOn() []schema.GroupVersionKind {
+	return []schema.GroupVersionKind{endpointSliceGVK}
+}
+
+func (r *serviceWithoutEndpointsRule) Evaluate(ctx *Context, obj client.Object) []Problem {
+	svc := obj.(*v1.Service)
+	if len(svc.Spec.Selector) == 0 || svc.Spec.Type == v1.ServiceTypeExternalName {
+		// endpoints of the service are managed by someone else
+		return nil
+	}
+
+	if ctx.readyServices == nil {
+		ctx.readyServices = readyServices(ctx.Store)
+	}
+	if ctx.readyServices[svc.Namespace+"/"+svc.Name] {
+		return nil
+	}
+
+	return []Problem{{
+		Severity:    types.SeverityHigh,
+		Message:     "service has no ready endpoints",
+		Remediation: "Check that the selector of the service matches the labels of running pods and that the pods pass their readiness probes.",
+	}}
+}
+
+// readyServices indexes the services with a ready endpoint by the kubernetes.io/service-name label of their slices,
+// keyed by namespace/name, so the services are not matched against every slice.
+func readyServices(store analyzer.Store) map[string]bool {
+	ready := map[string]bool{}
+	for _, o := range store.List(endpointSliceGVK) {
+		slice := o.(*discoveryv1.EndpointSlice)
+		name, ok := slice.Labels[discoveryv1.LabelServiceName]
+		if !ok {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready == nil || *ep.Conditions.Ready {
+				ready[slice.Namespace+"/"+name] = true
+				break
+			}
+		}
+	}
+	return ready
+}
+
+// nodeNotReadyRule reports nodes whose Ready condition is not True.
+type nodeNotReadyRule struct{}
+
+func (r *nodeNotReadyRule) Name() string                  { return "node-not-ready" }
+func (r *nodeNotReadyRule) Kind() schema.GroupVersionKind { return nodeGVK }
+
+func (r *nodeNotReadyRule) Evaluate(ctx *Context, obj client.Object) []Problem {
+	node := obj.(*v1.Node)
+	for _, c := range node.Status.Conditions {
+		if c.Type != v1.NodeReady || c.Status == v1.ConditionTrue {
+			continue
+		}
+		return []Problem{{
+			Severity:    types.SeverityCritical,
+			Message:     fmt.Sprintf("node is not ready (%s): %s", c.Reason, c.Message),
+			Remediation: "Check the kubelet and container runtime on the node, and its network connectivity to the API server.",
+		}}
+	}
+	return nil
+}
+
+// missingRequestsRule reports containers of active pods without cpu or memory requests.
+type missingRequestsRule struct{}
+
+func (r *missingRequestsRule) Name() string                  { return "missing-requests" }
+func (r *missingRequestsRule) Kind() schema.GroupVersionKind { return podGVK }
+
+func (r *missingRequestsRule) Evaluate(ctx *Context, obj client.Object) []Problem {
+	pod := obj.(*v1.Pod)
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return nil
+	}
+
+	problems := []Problem{}
+	for _, c := range pod.Spec.Containers {
+		missing := []v1.ResourceName{}
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			if _, ok := c.Resources.Requests[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		problems = append(problems, Problem{
+			Detail:      c.Name,
+			Severity:    types.SeverityLow,
+			Message:     fmt.Sprintf("container %s has no %v requests", c.Name, missing),
+			Remediation: "Set resources.requests so the scheduler can place the pod and the kubelet can protect it under pressure.",
+		})
+	}
+	return problems
+}
@@ -0,0 +1,241 @@
+// Package images builds an inventory of the container images used by the pods and flags poor image hygiene.
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/analyzer"
+	"github.com/iwanhae/kuview/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxNameBase bounds the part of the names taken from the repositories, so the names are valid DNS labels.
+const maxNameBase = 52
+
+var (
+	podGVK   = v1.SchemeGroupVersion.WithKind("Pod")
+	imageGVK = types.KuviewGroupVersion.WithKind("ContainerImage")
+)
+
+type Analyzer struct {
+	// allowedRegistries are glob patterns of the registries images may be pulled from. Empty allows every registry.
+	allowedRegistries []string
+}
+
+var _ analyzer.Analyzer = (*Analyzer)(nil)
+
+// New returns an analyzer flagging images from registries not matching any of the glob patterns, e.g. "*.azurecr.io".
+// Every registry is allowed if no pattern is given.
+func New(allowedRegistries ...string) (*Analyzer, error) {
+	for _, pattern := range allowedRegistries {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid registry pattern %q: %w", pattern, err)
+		}
+	}
+	return &Analyzer{allowedRegistries: allowedRegistries}, nil
+}
+
+func (a *Analyzer) Name() string {
+	return "images"
+}
+
+func (a *Analyzer) Watches() []schema.GroupVersionKind {
+	return []schema.GroupVersionKind{podGVK}
+}
+
+// inventory collects the sets of an image while walking the pods.
+type inventory struct {
+	ref          reference
+	references   map[string]struct{}
+	imageIDs     map[string]struct{}
+	pullPolicies map[string]struct{}
+	namespaces   map[string]struct{}
+	nodes        map[string]struct{}
+	usages       []types.ContainerImageUsage
+}
+
+func (a *Analyzer) Analyze(ctx context.Context, store analyzer.Store) []client.Object {
+	inventories := make(map[string]*inventory)
+
+	for _, obj := range store.List(podGVK) {
+		pod := obj.(*v1.Pod)
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			// the inventory tells what still runs
+			continue
+		}
+
+		imageIDs := make(map[string]string)
+		for _, statuses := range [][]v1.ContainerStatus{
+			pod.Status.InitContainerStatuses,
+			pod.Status.ContainerStatuses,
+			pod.Status.EphemeralContainerStatuses,
+		} {
+			for _, cs := range statuses {
+				imageIDs[cs.Name] = cs.ImageID
+			}
+		}
+
+		add := func(name, image string, pullPolicy v1.PullPolicy) {
+			ref := parseReference(image)
+			key := ref.String()
+			inv, ok := inventories[key]
+			if !ok {
+				inv = &inventory{
+					ref:          ref,
+					references:   make(map[string]struct{}),
+					imageIDs:     make(map[string]struct{}),
+					pullPolicies: make(map[string]struct{}),
+					namespaces:   make(map[string]struct{}),
+					nodes:        make(map[string]struct{}),
+				}
+				inventories[key] = inv
+			}
+			inv.references[image] = struct{}{}
+			if id := imageIDs[name]; id != "" {
+				inv.imageIDs[id] = struct{}{}
+			}
+			if pullPolicy != "" {
+				inv.pullPolicies[string(pullPolicy)] = struct{}{}
+			}
+			inv.namespaces[pod.Namespace] = struct{}{}
+			if pod.Spec.NodeName != "" {
+				inv.nodes[pod.Spec.NodeName] = struct{}{}
+			}
+			inv.usages = append(inv.usages, types.ContainerImageUsage{
+				Namespace:  pod.Namespace,
+				Pod:        pod.Name,
+				Container:  name,
+				Node:       pod.Spec.NodeName,
+				PullPolicy: string(pullPolicy),
+			})
+		}
+		for _, c := range pod.Spec.InitContainers {
+			add(c.Name, c.Image, c.ImagePullPolicy)
+		}
+		for _, c := range pod.Spec.Containers {
+			add(c.Name, c.Image, c.ImagePullPolicy)
+		}
+		for _, c := range pod.Spec.EphemeralContainers {
+			add(c.Name, c.Image, c.ImagePullPolicy)
+		}
+	}
+
+	res := make([]client.Object, 0, len(inventories))
+	for key, inv := range inventories {
+		sort.Slice(inv.usages, func(i, j int) bool {
+			x, y := inv.usages[i], inv.usages[j]
+			if x.Namespace != y.Namespace {
+				return x.Namespace < y.Namespace
+			}
+			if x.Pod != y.Pod {
+				return x.Pod < y.Pod
+			}
+			return x.Container < y.Container
+		})
+		res = append(res, &types.ContainerImage{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: imageGVK.GroupVersion().String(),
+				Kind:       imageGVK.Kind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: nameOf(inv.ref, key),
+			},
+			Spec: types.ContainerImageSpec{
+				Reference:    key,
+				Registry:     inv.ref.registry,
+				Repository:   inv.ref.repository,
+				Tag:          inv.ref.tag,
+				Digest:       inv.ref.digest,
+				References:   sorted(inv.references),
+				ImageIDs:     sorted(inv.imageIDs),
+				PullPolicies: sorted(inv.pullPolicies),
+				Namespaces:   sorted(inv.namespaces),
+				Nodes:        sorted(inv.nodes),
+				Usages:       inv.usages,
+				Issues:       a.issues(inv.ref),
+			},
+		})
+	}
+	return res
+}
+
+func (a *Analyzer) issues(ref reference) []types.ContainerImageIssue {
+	issues := []types.ContainerImageIssue{}
+	switch {
+	case ref.untagged:
+		issues = append(issues, types.ContainerImageIssue{
+			Check:    "latest-tag",
+			Severity: types.SeverityMedium,
+			Message:  "image has no tag, which means latest; pods may run different versions after a restart",
+		})
+	case ref.tag == defaultTag:
+		issues = append(issues, types.ContainerImageIssue{
+			Check:    "latest-tag",
+			Severity: types.SeverityMedium,
+			Message:  "image uses the latest tag; pods may run different versions after a restart",
+		})
+	}
+	if ref.digest == "" {
+		issues = append(issues, types.ContainerImageIssue{
+			Check:    "not-pinned",
+			Severity: types.SeverityLow,
+			Message:  "image is not pinned by digest; the tag may be moved to another image",
+		})
+	}
+	if len(a.allowedRegistries) > 0 && !a.allowed(ref.registry) {
+		issues = append(issues, types.ContainerImageIssue{
+			Check:    "registry-not-allowed",
+			Severity: types.SeverityHigh,
+			Message:  fmt.Sprintf("registry %s is not in the allowlist (%s)", ref.registry, strings.Join(a.allowedRegistries, ", ")),
+		})
+	}
+	return issues
+}
+
+func (a *Analyzer) allowed(registry string) bool {
+	for _, pattern := range a.allowedRegistries {
+		if ok, _ := path.Match(pattern, registry); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// nameOf returns a DNS-safe name of the image, the last element of its repository followed by a hash of its reference.
+// e.g. "log4j-app-3f2a9c1b7e" for "registry:5000/team/log4j_app:1.2"
+func nameOf(ref reference, key string) string {
+	base := strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(path.Base(ref.repository)))
+	base = strings.Trim(base, "-")
+	if len(base) > maxNameBase {
+		base = strings.TrimRight(base[:maxNameBase], "-")
+	}
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:5])
+	if base != "" {
+		name = base + "-" + name
+	}
+	return name
+}
+
+func sorted(set map[string]struct{}) []string {
+	res := make([]string, 0, len(set))
+	for v := range set {
+		res = append(res, v)
+	}
+	sort.Strings(res)
+	return res
+}
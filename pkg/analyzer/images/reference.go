@@ -0,0 +1,64 @@
+package images
+
+import "strings"
+
+const (
+	defaultRegistry = "docker.io"
+	defaultTag      = "latest"
+)
+
+// reference is an image reference split into its parts, with the defaults of the container runtimes applied.
+type reference struct {
+	registry   string
+	repository string
+	// tag is empty if the reference has a digest but no tag.
+	tag    string
+	digest string
+	// untagged is set if neither a tag nor a digest was given, which means latest.
+	untagged bool
+}
+
+// parseReference parses references like "nginx", "ghcr.io/iwanhae/kuview:latest" or "registry:5000/app@sha256:...".
+func parseReference(s string) reference {
+	ref := reference{}
+
+	name := s
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.digest = name[i+1:]
+		name = name[:i]
+	}
+	// a colon after the last slash separates the tag, a colon before it is the port of the registry
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.tag = name[i+1:]
+		name = name[:i]
+	}
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = defaultTag
+		ref.untagged = true
+	}
+
+	first, rest, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.registry = first
+		ref.repository = rest
+	} else {
+		ref.registry = defaultRegistry
+		ref.repository = name
+	}
+	if ref.registry == defaultRegistry && !strings.Contains(ref.repository, "/") {
+		ref.repository = "library/" + ref.repository
+	}
+	return ref
+}
+
+// String returns the normalized reference.
+func (r reference) String() string {
+	s := r.registry + "/" + r.repository
+	if r.tag != "" {
+		s += ":" + r.tag
+	}
+	if r.digest != "" {
+		s += "@" + r.digest
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+// Package analyzer derives virtual kuview resources from the objects streamed by the controllers.
+package analyzer
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Analyzer produces virtual objects from the objects observed by the pipeline.
+type Analyzer interface {
+	// Name identifies the analyzer in logs.
+	Name() string
+	// Watches returns the kinds the analyzer depends on. It is called once, when the pipeline is created.
+	// The analyzer runs again whenever an object of these kinds changes.
+	Watches() []schema.GroupVersionKind
+	// Analyze returns the complete set of objects derived from the store.
+	// Objects that were returned by the previous run but not by this one are deleted.
+	Analyze(ctx context.Context, store Store) []client.Object
+}
+
+const (
+	// debounceInterval is the time to wait for more changes before running the analyzers.
+	debounceInterval = time.Second
+	// resyncInterval is the period at which every analyzer runs even without changes,
+	// so that time-based conditions are re-evaluated.
+	resyncInterval = time.Minute
+)
+
+// Pipeline is a controller.Emitter that forwards every event to the next emitter
+// and emits the objects produced by the analyzers alongside them.
+type Pipeline struct {
+	next      controller.Emitter
+	analyzers []Analyzer
+	store     *store
+	// watchers are the indexes of the analyzers watching each kind
+	watchers map[schema.GroupVersionKind][]int
+
+	mu      sync.Mutex
+	dirty   []bool
+	trigger chan struct{}
+
+	// outputs of the previous run of each analyzer, keyed by controller.ObjectKey
+	outputs []map[string]client.Object
+}
+
+var _ controller.Emitter = (*Pipeline)(nil)
+var _ manager.Runnable = (*Pipeline)(nil)
+
+func NewPipeline(next controller.Emitter, analyzers ...Analyzer) *Pipeline {
+	p := &Pipeline{
+		next:      next,
+		analyzers: analyzers,
+		store:     newStore(),
+		dirty:     make([]bool, len(analyzers)),
+		trigger:   make(chan struct{}, 1),
+		outputs:   make([]map[string]client.Object, len(analyzers)),
+		watchers:  make(map[schema.GroupVersionKind][]int),
+	}
+	for i, a := range analyzers {
+		p.outputs[i] = make(map[string]client.Object)
+		for _, gvk := range a.Watches() {
+			if !slices.Contains(p.watchers[gvk], i) {
+				p.watchers[gvk] = append(p.watchers[gvk], i)
+			}
+		}
+	}
+	return p
+}
+
+// Emit implements controller.Emitter.
+func (p *Pipeline) Emit(v *controller.Event) {
+	p.next.Emit(v)
+
+	watchers := p.watchers[v.Object.GetObjectKind().GroupVersionKind()]
+	if len(watchers) == 0 {
+		return
+	}
+	p.mu.Lock()
+	for _, i := range watchers {
+		p.dirty[i] = true
+	}
+	p.mu.Unlock()
+
+	p.store.apply(v)
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Start implements manager.Runnable.
+func (p *Pipeline) Start(ctx context.Context) error {
+	log.Info().Int("analyzers", len(p.analyzers)).Msg("starting analyzer pipeline")
+
+	resync := time.NewTicker(resyncInterval)
+	defer resync.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.trigger:
+		case <-resync.C:
+			p.mu.Lock()
+			for i := range p.dirty {
+				p.dirty[i] = true
+			}
+			p.mu.Unlock()
+		}
+
+		// wait for the burst of changes to settle down
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(debounceInterval):
+		}
+
+		p.mu.Lock()
+		dirty := p.dirty
+		p.dirty = make([]bool, len(p.analyzers))
+		p.mu.Unlock()
+
+		for i, a := range p.analyzers {
+			if dirty[i] {
+				p.run(ctx, i, a)
+			}
+		}
+	}
+}
+
+func (p *Pipeline) run(ctx context.Context, i int, a Analyzer) {
+	start := time.Now()
+	objs := a.Analyze(ctx, p.store)
+
+	prev := p.outputs[i]
+	current := make(map[string]client.Object, len(objs))
+	changed := 0
+	for _, obj := range objs {
+		key := controller.ObjectKey(obj)
+		current[key] = obj
+		if old, ok := prev[key]; ok && reflect.DeepEqual(old, obj) {
+			continue
+		}
+		changed++
+		p.next.Emit(&controller.Event{
+			Type:   controller.EventTypeCreate,
+			Object: obj,
+		})
+	}
+
+	// GC: emit Delete events for objects that are no longer produced
+	for key, old := range prev {
+		if _, exists := current[key]; !exists {
+			changed++
+			p.next.Emit(&controller.Event{
+				Type:   controller.EventTypeDelete,
+				Object: old,
+			})
+		}
+	}
+	p.outputs[i] = current
+
+	log.Debug().
+		Str("analyzer", a.Name()).
+		Int("objects", len(current)).
+		Int("changed", changed).
+		Dur("took", time.Since(start)).
+		Msg("analyzer finished")
+}
@@ -0,0 +1,245 @@
+package podsecurity
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/types"
+	v1 "k8s.io/api/core/v1"
+)
+
+// baselineCapabilities are the capabilities the baseline profile allows to add.
+var baselineCapabilities = map[v1.Capability]struct{}{
+	"AUDIT_WRITE": {}, "CHOWN": {}, "DAC_OVERRIDE": {}, "FOWNER": {}, "FSETID": {}, "KILL": {}, "MKNOD": {},
+	"NET_BIND_SERVICE": {}, "SETFCAP": {}, "SETGID": {}, "SETPCAP": {}, "SETUID": {}, "SYS_CHROOT": {},
+}
+
+// baselineSysctls are the sysctls the baseline profile allows to set.
+var baselineSysctls = map[string]struct{}{
+	"kernel.shm_rmid_forced": {}, "net.ipv4.ip_local_port_range": {}, "net.ipv4.ip_unprivileged_port_start": {},
+	"net.ipv4.tcp_syncookies": {}, "net.ipv4.ping_group_range": {}, "net.ipv4.ip_local_reserved_ports": {},
+	"net.ipv4.tcp_keepalive_time": {}, "net.ipv4.tcp_fin_timeout": {}, "net.ipv4.tcp_keepalive_intvl": {},
+	"net.ipv4.tcp_keepalive_probes": {},
+}
+
+// baselineSELinuxTypes are the SELinux types the baseline profile allows to set.
+var baselineSELinuxTypes = map[string]struct{}{
+	"": {}, "container_t": {}, "container_init_t": {}, "container_kvm_t": {}, "container_engine_t": {},
+}
+
+// container is a container of any type with the fields the checks need.
+type container struct {
+	name            string
+	securityContext *v1.SecurityContext
+	ports           []v1.ContainerPort
+}
+
+func containers(pod *v1.Pod) []container {
+	res := []container{}
+	for _, c := range pod.Spec.InitContainers {
+		res = append(res, container{c.Name, c.SecurityContext, c.Ports})
+	}
+	for _, c := range pod.Spec.Containers {
+		res = append(res, container{c.Name, c.SecurityContext, c.Ports})
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		res = append(res, container{c.Name, c.SecurityContext, c.Ports})
+	}
+	return res
+}
+
+// evaluate returns the failed controls of the baseline and restricted profiles.
+func evaluate(pod *v1.Pod) []types.PodSecurityCheck {
+	checks := []types.PodSecurityCheck{}
+	fail := func(check string, profile types.PodSecurityLevel, container string, format string, args ...any) {
+		checks = append(checks, types.PodSecurityCheck{
+			Check:     check,
+			Profile:   profile,
+			Container: container,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+	psc := pod.Spec.SecurityContext
+	if psc == nil {
+		psc = &v1.PodSecurityContext{}
+	}
+
+	// baseline, pod level
+	hostNamespaces := []string{}
+	if pod.Spec.HostNetwork {
+		hostNamespaces = append(hostNamespaces, "hostNetwork")
+	}
+	if pod.Spec.HostPID {
+		hostNamespaces = append(hostNamespaces, "hostPID")
+	}
+	if pod.Spec.HostIPC {
+		hostNamespaces = append(hostNamespaces, "hostIPC")
+	}
+	if len(hostNamespaces) > 0 {
+		fail("host-namespaces", types.PodSecurityBaseline, "", "%s must not be set", strings.Join(hostNamespaces, ", "))
+	}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.HostPath != nil {
+			fail("host-path-volumes", types.PodSecurityBaseline, "", "volume %s mounts %s of the host", vol.Name, vol.HostPath.Path)
+		}
+	}
+	if psc.SeccompProfile != nil && psc.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+		fail("seccomp", types.PodSecurityBaseline, "", "seccomp profile must not be Unconfined")
+	}
+	if psc.WindowsOptions != nil && psc.WindowsOptions.HostProcess != nil && *psc.WindowsOptions.HostProcess {
+		fail("host-process", types.PodSecurityBaseline, "", "hostProcess must not be true")
+	}
+	if psc.AppArmorProfile != nil && psc.AppArmorProfile.Type == v1.AppArmorProfileTypeUnconfined {
+		fail("apparmor", types.PodSecurityBaseline, "", "AppArmor profile must not be Unconfined")
+	}
+	for _, key := range slices.Sorted(maps.Keys(pod.Annotations)) {
+		value := pod.Annotations[key]
+		if name, ok := strings.CutPrefix(key, v1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix); ok &&
+			value != v1.DeprecatedAppArmorBetaProfileRuntimeDefault && !strings.HasPrefix(value, v1.DeprecatedAppArmorBetaProfileNamePrefix) {
+			fail("apparmor", types.PodSecurityBaseline, name, "AppArmor profile must be runtime/default or localhost/*, not %s", value)
+		}
+	}
+	if msg := checkSELinux(psc.SELinuxOptions); msg != "" {
+		fail("selinux", types.PodSecurityBaseline, "", "%s", msg)
+	}
+	for _, sysctl := range psc.Sysctls {
+		if _, ok := baselineSysctls[sysctl.Name]; !ok {
+			fail("sysctls", types.PodSecurityBaseline, "", "sysctl %s must not be set", sysctl.Name)
+		}
+	}
+
+	// restricted, pod level
+	if psc.RunAsUser != nil && *psc.RunAsUser == 0 {
+		fail("run-as-user", types.PodSecurityRestricted, "", "runAsUser must not be 0")
+	}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.HostPath != nil {
+			// already failed baseline
+			continue
+		}
+		if !restrictedVolume(vol.VolumeSource) {
+			fail("volume-types", types.PodSecurityRestricted, "", "volume %s must be of an allowed type", vol.Name)
+		}
+	}
+
+	for _, c := range containers(pod) {
+		sc := c.securityContext
+		if sc == nil {
+			sc = &v1.SecurityContext{}
+		}
+
+		// baseline, container level
+		if sc.Privileged != nil && *sc.Privileged {
+			fail("privileged", types.PodSecurityBaseline, c.name, "container must not be privileged")
+		}
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Add {
+				if _, ok := baselineCapabilities[capability]; !ok {
+					fail("capabilities", types.PodSecurityBaseline, c.name, "capability %s must not be added", capability)
+				}
+			}
+		}
+		for _, p := range c.ports {
+			if p.HostPort != 0 {
+				fail("host-ports", types.PodSecurityBaseline, c.name, "hostPort %d must not be used", p.HostPort)
+			}
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+			fail("seccomp", types.PodSecurityBaseline, c.name, "seccomp profile must not be Unconfined")
+		}
+		if sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+			fail("host-process", types.PodSecurityBaseline, c.name, "hostProcess must not be true")
+		}
+		if sc.AppArmorProfile != nil && sc.AppArmorProfile.Type == v1.AppArmorProfileTypeUnconfined {
+			fail("apparmor", types.PodSecurityBaseline, c.name, "AppArmor profile must not be Unconfined")
+		}
+		if msg := checkSELinux(sc.SELinuxOptions); msg != "" {
+			fail("selinux", types.PodSecurityBaseline, c.name, "%s", msg)
+		}
+		if sc.ProcMount != nil && *sc.ProcMount != v1.DefaultProcMount {
+			fail("proc-mount", types.PodSecurityBaseline, c.name, "procMount must be Default")
+		}
+
+		// restricted, container level
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			fail("allow-privilege-escalation", types.PodSecurityRestricted, c.name, "allowPrivilegeEscalation must be false")
+		}
+
+		runAsNonRoot := psc.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			runAsNonRoot = sc.RunAsNonRoot
+		}
+		if runAsNonRoot == nil || !*runAsNonRoot {
+			fail("run-as-non-root", types.PodSecurityRestricted, c.name, "runAsNonRoot must be true")
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			fail("run-as-user", types.PodSecurityRestricted, c.name, "runAsUser must not be 0")
+		}
+
+		seccomp := psc.SeccompProfile
+		if sc.SeccompProfile != nil {
+			seccomp = sc.SeccompProfile
+		}
+		if seccomp == nil || (seccomp.Type != v1.SeccompProfileTypeRuntimeDefault && seccomp.Type != v1.SeccompProfileTypeLocalhost) {
+			fail("seccomp", types.PodSecurityRestricted, c.name, "seccomp profile must be RuntimeDefault or Localhost")
+		}
+
+		dropsAll := false
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Drop {
+				if capability == "ALL" {
+					dropsAll = true
+				}
+			}
+			for _, capability := range sc.Capabilities.Add {
+				if _, ok := baselineCapabilities[capability]; !ok || capability == "NET_BIND_SERVICE" {
+					// already failed baseline, or allowed
+					continue
+				}
+				fail("capabilities", types.PodSecurityRestricted, c.name, "capability %s must not be added", capability)
+			}
+		}
+		if !dropsAll {
+			fail("capabilities", types.PodSecurityRestricted, c.name, "capabilities must drop ALL")
+		}
+	}
+
+	return checks
+}
+
+// checkSELinux returns why the SELinux options fail the baseline profile, or an empty string.
+func checkSELinux(opts *v1.SELinuxOptions) string {
+	if opts == nil {
+		return ""
+	}
+	if _, ok := baselineSELinuxTypes[opts.Type]; !ok {
+		return fmt.Sprintf("SELinux type %s must not be set", opts.Type)
+	}
+	if opts.User != "" || opts.Role != "" {
+		return "SELinux user and role must not be set"
+	}
+	return ""
+}
+
+// restrictedVolume reports whether the restricted profile allows the type of the volume.
+func restrictedVolume(vol v1.VolumeSource) bool {
+	return vol.ConfigMap != nil || vol.CSI != nil || vol.DownwardAPI != nil || vol.EmptyDir != nil ||
+		vol.Ephemeral != nil || vol.PersistentVolumeClaim != nil || vol.Projected != nil || vol.Secret != nil ||
+		vol.Image != nil
+}
+
+// level returns the most restrictive profile satisfied by a pod with the failed checks.
+func level(checks []types.PodSecurityCheck) types.PodSecurityLevel {
+	l := types.PodSecurityRestricted
+	for _, c := range checks {
+		switch c.Profile {
+		case types.PodSecurityBaseline:
+			return types.PodSecurityPrivileged
+		case types.PodSecurityRestricted:
+			l = types.PodSecurityBaseline
+		}
+	}
+	return l
+}
@@ -0,0 +1,146 @@
+// Package podsecurity evaluates the running pods against the Pod Security Standards
+// and compares the results with the pod-security.kubernetes.io/* labels of their namespaces.
+package podsecurity
+
+import (
+	"context"
+
+	"github.com/iwanhae/kuview/pkg/analyzer"
+	"github.com/iwanhae/kuview/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	podGVK       = v1.SchemeGroupVersion.WithKind("Pod")
+	namespaceGVK = v1.SchemeGroupVersion.WithKind("Namespace")
+
+	violationGVK = types.KuviewGroupVersion.WithKind("PodSecurityViolation")
+	summaryGVK   = types.KuviewGroupVersion.WithKind("PodSecuritySummary")
+)
+
+// Labels of the namespaces configuring the Pod Security Admission.
+const (
+	labelEnforce = "pod-security.kubernetes.io/enforce"
+	labelAudit   = "pod-security.kubernetes.io/audit"
+	labelWarn    = "pod-security.kubernetes.io/warn"
+)
+
+type Analyzer struct{}
+
+var _ analyzer.Analyzer = (*Analyzer)(nil)
+
+func New() *Analyzer {
+	return &Analyzer{}
+}
+
+func (a *Analyzer) Name() string {
+	return "podsecurity"
+}
+
+func (a *Analyzer) Watches() []schema.GroupVersionKind {
+	return []schema.GroupVersionKind{podGVK, namespaceGVK}
+}
+
+func (a *Analyzer) Analyze(ctx context.Context, store analyzer.Store) []client.Object {
+	summaries := make(map[string]*types.PodSecuritySummary)
+	for _, obj := range store.List(namespaceGVK) {
+		ns := obj.(*v1.Namespace)
+		summaries[ns.Name] = newSummary(ns.Name, modesOf(ns))
+	}
+
+	res := []client.Object{}
+	for _, obj := range store.List(podGVK) {
+		pod := obj.(*v1.Pod)
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+
+		summary, ok := summaries[pod.Namespace]
+		if !ok {
+			// the namespace has not been observed yet
+			summary = newSummary(pod.Namespace, types.PodSecurityModes{})
+			summaries[pod.Namespace] = summary
+		}
+		modes := summary.Spec.Modes
+
+		checks := evaluate(pod)
+		l := level(checks)
+		violates := []string{}
+		for _, m := range []struct {
+			mode     string
+			required types.PodSecurityLevel
+		}{
+			{"enforce", modes.Enforce},
+			{"audit", modes.Audit},
+			{"warn", modes.Warn},
+		} {
+			if !l.Allows(m.required) {
+				violates = append(violates, m.mode)
+				summary.Spec.Violations[m.mode]++
+			}
+		}
+		summary.Spec.Pods++
+		summary.Spec.Levels[l]++
+
+		if len(checks) == 0 {
+			continue
+		}
+		res = append(res, &types.PodSecurityViolation{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: violationGVK.GroupVersion().String(),
+				Kind:       violationGVK.Kind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+			},
+			Spec: types.PodSecurityViolationSpec{
+				Level:    l,
+				Checks:   checks,
+				Modes:    modes,
+				Violates: violates,
+			},
+		})
+	}
+
+	for _, summary := range summaries {
+		res = append(res, summary)
+	}
+	return res
+}
+
+func newSummary(namespace string, modes types.PodSecurityModes) *types.PodSecuritySummary {
+	return &types.PodSecuritySummary{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: summaryGVK.GroupVersion().String(),
+			Kind:       summaryGVK.Kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespace,
+		},
+		Spec: types.PodSecuritySummarySpec{
+			Modes: modes,
+			Levels: map[types.PodSecurityLevel]int{
+				types.PodSecurityPrivileged: 0,
+				types.PodSecurityBaseline:   0,
+				types.PodSecurityRestricted: 0,
+			},
+			Violations: map[string]int{
+				"enforce": 0,
+				"audit":   0,
+				"warn":    0,
+			},
+		},
+	}
+}
+
+func modesOf(ns *v1.Namespace) types.PodSecurityModes {
+	return types.PodSecurityModes{
+		Enforce: types.PodSecurityLevel(ns.Labels[labelEnforce]),
+		Audit:   types.PodSecurityLevel(ns.Labels[labelAudit]),
+		Warn:    types.PodSecurityLevel(ns.Labels[labelWarn]),
+	}
+}
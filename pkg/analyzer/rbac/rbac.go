@@ -0,0 +1,335 @@
+// Package rbac flags RBAC grants that allow privilege escalation and ranks subjects by risk.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/analyzer"
+	"github.com/iwanhae/kuview/pkg/types"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	clusterRoleGVK        = rbacv1.SchemeGroupVersion.WithKind("ClusterRole")
+	clusterRoleBindingGVK = rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding")
+	roleGVK               = rbacv1.SchemeGroupVersion.WithKind("Role")
+	roleBindingGVK        = rbacv1.SchemeGroupVersion.WithKind("RoleBinding")
+
+	subjectRiskGVK = types.KuviewGroupVersion.WithKind("RBACSubjectRisk")
+)
+
+type Analyzer struct{}
+
+var _ analyzer.Analyzer = (*Analyzer)(nil)
+
+func New() *Analyzer {
+	return &Analyzer{}
+}
+
+func (a *Analyzer) Name() string {
+	return "rbac"
+}
+
+func (a *Analyzer) Watches() []schema.GroupVersionKind {
+	return []schema.GroupVersionKind{
+		clusterRoleGVK, clusterRoleBindingGVK, roleGVK, roleBindingGVK,
+	}
+}
+
+// grant is a role bound to subjects in a scope.
+type grant struct {
+	binding  types.ObjectReference
+	role     types.ObjectReference
+	scope    string
+	rules    []rbacv1.PolicyRule
+	subjects []rbacv1.Subject
+}
+
+func (a *Analyzer) Analyze(ctx context.Context, store analyzer.Store) []client.Object {
+	grants := collectGrants(store)
+
+	risks := make(map[string]*types.RBACSubjectRisk)
+	for _, g := range grants {
+		findings := checkGrant(g)
+		for _, subject := range g.subjects {
+			subjectFindings := append(append([]types.RBACRiskFinding{}, findings...), checkSubject(g, subject)...)
+			if len(subjectFindings) == 0 {
+				continue
+			}
+
+			name := subjectName(subject)
+			risk, ok := risks[name]
+			if !ok {
+				risk = &types.RBACSubjectRisk{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: subjectRiskGVK.GroupVersion().String(),
+						Kind:       subjectRiskGVK.Kind,
+					},
+					ObjectMeta: metav1.ObjectMeta{Name: name},
+					Spec: types.RBACSubjectRiskSpec{
+						Subject: normalizeSubject(subject),
+					},
+				}
+				risks[name] = risk
+			}
+			risk.Spec.Findings = append(risk.Spec.Findings, subjectFindings...)
+		}
+	}
+
+	ranked := make([]*types.RBACSubjectRisk, 0, len(risks))
+	for _, risk := range risks {
+		sortFindings(risk.Spec.Findings)
+		for _, f := range risk.Spec.Findings {
+			risk.Spec.Score += f.Severity.Weight()
+			if f.Severity.Weight() > risk.Spec.Severity.Weight() {
+				risk.Spec.Severity = f.Severity
+			}
+		}
+		ranked = append(ranked, risk)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Spec.Score != ranked[j].Spec.Score {
+			return ranked[i].Spec.Score > ranked[j].Spec.Score
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+
+	res := make([]client.Object, 0, len(ranked))
+	for i, risk := range ranked {
+		risk.Spec.Rank = i + 1
+		res = append(res, risk)
+	}
+	return res
+}
+
+func collectGrants(store analyzer.Store) []grant {
+	clusterRoles := make(map[string]*rbacv1.ClusterRole)
+	for _, obj := range store.List(clusterRoleGVK) {
+		cr := obj.(*rbacv1.ClusterRole)
+		clusterRoles[cr.Name] = cr
+	}
+
+	grants := []grant{}
+	for _, obj := range store.List(clusterRoleBindingGVK) {
+		crb := obj.(*rbacv1.ClusterRoleBinding)
+		cr, ok := clusterRoles[crb.RoleRef.Name]
+		if !ok {
+			continue
+		}
+		grants = append(grants, grant{
+			binding:  types.ObjectReference{Kind: "ClusterRoleBinding", Name: crb.Name},
+			role:     types.ObjectReference{Kind: "ClusterRole", Name: cr.Name},
+			rules:    cr.Rules,
+			subjects: crb.Subjects,
+		})
+	}
+
+	for _, obj := range store.List(roleBindingGVK) {
+		rb := obj.(*rbacv1.RoleBinding)
+		g := grant{
+			binding:  types.ObjectReference{Kind: "RoleBinding", Namespace: rb.Namespace, Name: rb.Name},
+			scope:    rb.Namespace,
+			subjects: rb.Subjects,
+		}
+		switch rb.RoleRef.Kind {
+		case "ClusterRole":
+			cr, ok := clusterRoles[rb.RoleRef.Name]
+			if !ok {
+				continue
+			}
+			g.role = types.ObjectReference{Kind: "ClusterRole", Name: cr.Name}
+			g.rules = cr.Rules
+		case "Role":
+			o, ok := store.Get(roleGVK, rb.Namespace, rb.RoleRef.Name)
+			if !ok {
+				continue
+			}
+			r := o.(*rbacv1.Role)
+			g.role = types.ObjectReference{Kind: "Role", Namespace: r.Namespace, Name: r.Name}
+			g.rules = r.Rules
+		default:
+			continue
+		}
+		grants = append(grants, g)
+	}
+
+	return grants
+}
+
+// checkGrant returns the findings caused by the rules of the bound role.
+func checkGrant(g grant) []types.RBACRiskFinding {
+	where := "in namespace " + g.scope
+	if g.scope == "" {
+		where = "cluster-wide"
+	}
+
+	found := make(map[string]types.RBACRiskFinding)
+	add := func(rule string, severity types.Severity, format string, args ...any) {
+		if old, ok := found[rule]; ok && old.Severity.Weight() >= severity.Weight() {
+			return
+		}
+		found[rule] = types.RBACRiskFinding{
+			Rule:     rule,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...) + " " + where,
+			Scope:    g.scope,
+			Binding:  g.binding,
+			Role:     g.role,
+		}
+	}
+
+	for _, r := range g.rules {
+		if len(r.Resources) == 0 {
+			// nonResourceURLs only
+			continue
+		}
+
+		if contains(r.Verbs, rbacv1.VerbAll) && contains(r.Resources, rbacv1.ResourceAll) && contains(r.APIGroups, rbacv1.APIGroupAll) {
+			severity := types.SeverityHigh
+			if g.scope == "" {
+				severity = types.SeverityCritical
+			}
+			add("full-access", severity, "can perform any action on any resource")
+			continue
+		}
+		if contains(r.Verbs, rbacv1.VerbAll) {
+			add("wildcard-verbs", types.SeverityHigh, "can perform any verb on %s", strings.Join(r.Resources, ", "))
+		}
+		if contains(r.Resources, rbacv1.ResourceAll) {
+			add("wildcard-resources", types.SeverityHigh, "can %s any resource", strings.Join(r.Verbs, ", "))
+		}
+
+		for _, resource := range []string{"roles", "clusterroles"} {
+			if allows(r, rbacv1.GroupName, resource, "escalate") {
+				add("escalate", types.SeverityHigh, "can escalate %s beyond its own permissions", resource)
+			}
+			if allows(r, rbacv1.GroupName, resource, "bind") {
+				add("bind", types.SeverityHigh, "can bind %s it does not hold", resource)
+			}
+		}
+		for _, resource := range []string{"users", "groups", "serviceaccounts"} {
+			if allows(r, "", resource, "impersonate") {
+				add("impersonate", types.SeverityCritical, "can impersonate %s", resource)
+			}
+		}
+		if allows(r, "", "pods/exec", "create") {
+			add("pods-exec", types.SeverityHigh, "can exec into pods")
+		}
+		if len(r.ResourceNames) == 0 {
+			for _, verb := range []string{"get", "list", "watch"} {
+				if !allows(r, "", "secrets", verb) {
+					continue
+				}
+				if g.scope == "" {
+					add("read-secrets", types.SeverityHigh, "can read secrets")
+				} else {
+					add("read-secrets", types.SeverityMedium, "can read secrets")
+				}
+				break
+			}
+		}
+	}
+
+	findings := make([]types.RBACRiskFinding, 0, len(found))
+	for _, f := range found {
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// checkSubject returns the findings caused by who the role is bound to.
+func checkSubject(g grant, subject rbacv1.Subject) []types.RBACRiskFinding {
+	findings := []types.RBACRiskFinding{}
+	switch {
+	case subject.Kind == rbacv1.GroupKind && subject.Name == "system:masters":
+		findings = append(findings, types.RBACRiskFinding{
+			Rule:     "system-masters",
+			Severity: types.SeverityCritical,
+			Message:  fmt.Sprintf("%s %s is bound to the system:masters group, which bypasses authorization", g.role.Kind, g.role.Name),
+			Scope:    g.scope,
+			Binding:  g.binding,
+			Role:     g.role,
+		})
+	case subject.Kind == rbacv1.ServiceAccountKind && subject.Name == "default":
+		findings = append(findings, types.RBACRiskFinding{
+			Rule:     "default-serviceaccount",
+			Severity: types.SeverityMedium,
+			Message:  fmt.Sprintf("%s %s is bound to the default ServiceAccount, which every pod in the namespace uses unless told otherwise", g.role.Kind, g.role.Name),
+			Scope:    g.scope,
+			Binding:  g.binding,
+			Role:     g.role,
+		})
+	}
+	return findings
+}
+
+func sortFindings(findings []types.RBACRiskFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity.Weight() != b.Severity.Weight() {
+			return a.Severity.Weight() > b.Severity.Weight()
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		if a.Binding.Namespace != b.Binding.Namespace {
+			return a.Binding.Namespace < b.Binding.Namespace
+		}
+		return a.Binding.Name < b.Binding.Name
+	})
+}
+
+// subjectName returns a name that is unique among all kinds of subjects.
+func subjectName(s rbacv1.Subject) string {
+	switch s.Kind {
+	case rbacv1.ServiceAccountKind:
+		return fmt.Sprintf("serviceaccount:%s:%s", s.Namespace, s.Name)
+	case rbacv1.GroupKind:
+		return "group:" + s.Name
+	default:
+		return "user:" + s.Name
+	}
+}
+
+func normalizeSubject(s rbacv1.Subject) rbacv1.Subject {
+	if s.Kind == rbacv1.ServiceAccountKind {
+		s.APIGroup = ""
+	}
+	return s
+}
+
+// allows reports whether the rule grants the verb on the resource, following the wildcard semantics of RBAC.
+func allows(r rbacv1.PolicyRule, group, resource, verb string) bool {
+	if !contains(r.APIGroups, group) || !contains(r.Verbs, verb) {
+		return false
+	}
+	for _, res := range r.Resources {
+		if res == rbacv1.ResourceAll || res == resource {
+			return true
+		}
+		// "pods/*" matches every subresource of pods, "*/exec" matches exec of every resource
+		main, sub, ok := strings.Cut(resource, "/")
+		if !ok {
+			continue
+		}
+		if res == main+"/*" || res == "*/"+sub {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == "*" || item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"sync"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Store is a read-only view of the objects observed by the pipeline.
+type Store interface {
+	// List returns every object of the given kind.
+	List(gvk schema.GroupVersionKind) []client.Object
+	// Get returns the object of the given kind, namespace and name.
+	Get(gvk schema.GroupVersionKind, namespace, name string) (client.Object, bool)
+}
+
+type store struct {
+	rwmu    sync.RWMutex
+	objects map[schema.GroupVersionKind]map[string]client.Object
+}
+
+var _ Store = (*store)(nil)
+
+func newStore() *store {
+	return &store{
+		objects: make(map[schema.GroupVersionKind]map[string]client.Object),
+	}
+}
+
+func (s *store) apply(v *controller.Event) {
+	gvk := v.Object.GetObjectKind().GroupVersionKind()
+	key := storeKey(v.Object.GetNamespace(), v.Object.GetName())
+
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+
+	switch v.Type {
+	case controller.EventTypeCreate:
+		objs, ok := s.objects[gvk]
+		if !ok {
+			objs = make(map[string]client.Object)
+			s.objects[gvk] = objs
+		}
+		objs[key] = v.Object
+	case controller.EventTypeDelete:
+		delete(s.objects[gvk], key)
+	}
+}
+
+func (s *store) List(gvk schema.GroupVersionKind) []client.Object {
+	s.rwmu.RLock()
+	defer s.rwmu.RUnlock()
+
+	objs := s.objects[gvk]
+	res := make([]client.Object, 0, len(objs))
+	for _, obj := range objs {
+		res = append(res, obj)
+	}
+	return res
+}
+
+func (s *store) Get(gvk schema.GroupVersionKind, namespace, name string) (client.Object, bool) {
+	s.rwmu.RLock()
+	defer s.rwmu.RUnlock()
+
+	obj, ok := s.objects[gvk][storeKey(namespace, name)]
+	return obj, ok
+}
+
+func storeKey(namespace, name string) string {
+	return namespace + "/" + name
+}
@@ -0,0 +1,204 @@
+// Package audit records who read what through kuview: the subscriptions, the listed objects,
+// the streamed logs and the share links, correlated by the request ID.
+package audit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// bufferSize is the number of events waiting to be written, beyond which events are dropped.
+	bufferSize = 4096
+	// batchSize is the maximum number of events written at once.
+	batchSize = 256
+	// flushInterval is how long an event may wait to be batched with the next ones.
+	flushInterval = time.Second
+	// writeTimeout bounds the write of a batch to a sink, retries included.
+	writeTimeout = 40 * time.Second
+)
+
+var eventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kuview_audit_events_dropped_total",
+	Help: "Number of audit events lost, by reason: \"buffer\" if the sinks fell behind, \"sink\" if a sink failed to write them.",
+}, []string{"reason"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(eventsDropped)
+}
+
+// Stage tells whether an event records the start or the end of an action.
+// Streams are recorded when they start and when they end, the other actions once completed.
+type Stage string
+
+const (
+	StageStarted   Stage = "Started"
+	StageCompleted Stage = "Completed"
+)
+
+// Action is what the user did.
+type Action string
+
+const (
+	// ActionSubscribe is a subscription to the events, over the event stream or the WebSocket.
+	ActionSubscribe Action = "subscribe"
+	// ActionWebSocket is a WebSocket connection, which the subscriptions and log streams are multiplexed over.
+	ActionWebSocket Action = "websocket"
+	// ActionList is a listing of the objects through the REST APIs.
+	ActionList Action = "list"
+	// ActionLogs is a stream of the logs of a container.
+	ActionLogs        Action = "logs"
+	ActionShareCreate Action = "share.create"
+	ActionShareRevoke Action = "share.revoke"
+)
+
+// Event is a record of the audit stream.
+type Event struct {
+	Time time.Time `json:"time"`
+	// RequestID is the X-Request-ID of the request, shared by the events of a connection.
+	RequestID string `json:"requestID,omitempty"`
+	Stage     Stage  `json:"stage"`
+	Action    Action `json:"action"`
+
+	User   string   `json:"user,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// AuthMethod is how the user authenticated, e.g. "oidc" or "share".
+	AuthMethod string `json:"authMethod,omitempty"`
+	// ShareID is the share link the request was authenticated with, or the one created or revoked.
+	ShareID  string `json:"shareID,omitempty"`
+	SourceIP string `json:"sourceIP,omitempty"`
+
+	// Stream is the ID of a WebSocket subscription or log stream.
+	Stream string `json:"stream,omitempty"`
+	// Filters are the parameters of a subscription or listing, e.g. the kinds and namespaces.
+	Filters   map[string]string `json:"filters,omitempty"`
+	Cluster   string            `json:"cluster,omitempty"`
+	Kind      string            `json:"kind,omitempty"`
+	Namespace string            `json:"namespace,omitempty"`
+	Pod       string            `json:"pod,omitempty"`
+	Container string            `json:"container,omitempty"`
+	// ObjectCount is the number of objects listed, or sent in the snapshot of a subscription.
+	ObjectCount int `json:"objectCount,omitempty"`
+	// Objects are the keys of the objects listed, up to MaxObjects.
+	Objects []string `json:"objects,omitempty"`
+	// DurationMs is how long a stream lasted, in the Completed stage.
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// MaxObjects bounds the object keys recorded by an event.
+const MaxObjects = 100
+
+// Sink writes the events somewhere.
+type Sink interface {
+	Write(ctx context.Context, events []*Event) error
+	Close() error
+}
+
+// Logger writes the events to the sinks in the background, so recording never blocks a request.
+// A nil Logger records nothing.
+type Logger struct {
+	sinks []Sink
+	ch    chan *Event
+	done  chan struct{}
+	// mu guards closing ch, as streams may still end while closing
+	mu     sync.RWMutex
+	closed bool
+	// dropped counts the events lost because the buffer was full
+	dropped atomic.Int64
+}
+
+// New starts writing the events recorded to the sinks, until closed.
+func New(sinks ...Sink) *Logger {
+	l := &Logger{
+		sinks: sinks,
+		ch:    make(chan *Event, bufferSize),
+		done:  make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Log records the event, timestamped now if not set. It is dropped if the sinks fall behind.
+func (l *Logger) Log(evt *Event) {
+	if l == nil {
+		return
+	}
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return
+	}
+	select {
+	case l.ch <- evt:
+	default:
+		eventsDropped.WithLabelValues("buffer").Inc()
+		if l.dropped.Add(1)%100 == 1 {
+			log.Warn().Int64("dropped", l.dropped.Load()).Msg("audit sinks are falling behind, dropping audit events")
+		}
+	}
+}
+
+// Close writes the events recorded so far and closes the sinks. The events logged afterwards are ignored.
+func (l *Logger) Close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.closed = true
+	close(l.ch)
+	l.mu.Unlock()
+	<-l.done
+	for _, s := range l.sinks {
+		if err := s.Close(); err != nil {
+			log.Warn().Err(err).Msg("failed to close audit sink")
+		}
+	}
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Event, 0, batchSize)
+	for {
+		select {
+		case evt, ok := <-l.ch:
+			if !ok {
+				l.write(batch)
+				return
+			}
+			batch = append(batch, evt)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		l.write(batch)
+		batch = batch[:0]
+	}
+}
+
+// write writes the batch to every sink. The events a sink fails to write, once it gave up retrying, are lost.
+func (l *Logger) write(batch []*Event) {
+	if len(batch) == 0 {
+		return
+	}
+	for _, s := range l.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		if err := s.Write(ctx, batch); err != nil {
+			eventsDropped.WithLabelValues("sink").Add(float64(len(batch)))
+			log.Error().Err(err).Int("events", len(batch)).Msg("failed to write audit events, dropping them")
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FileSink appends the events to a file as JSON lines, or to stdout if the path is "-".
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "-" {
+		return &FileSink{f: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileSink{f: f}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, events []*Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := bufio.NewWriter(s.f)
+	enc := json.NewEncoder(w)
+	for _, evt := range events {
+		if err := enc.Encode(evt); err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	if s.f == os.Stdout {
+		return nil
+	}
+	return s.f.Close()
+}
+
+const (
+	// webhookAttempts bounds the posts of a batch to the webhook.
+	webhookAttempts = 3
+	// webhookAttemptTimeout bounds a post, so a hanging webhook leaves time to retry.
+	webhookAttemptTimeout = 10 * time.Second
+	// webhookBackoff is the delay before the first retry, doubled before every next one.
+	webhookBackoff = time.Second
+)
+
+// WebhookSink posts the events to a URL as a JSON array.
+// Batches failing with a network error, 429 or a 5xx status are posted again with a backoff.
+type WebhookSink struct {
+	url string
+	// token is sent as a bearer token, if set
+	token  string
+	client *http.Client
+}
+
+func NewWebhookSink(url, token string) *WebhookSink {
+	return &WebhookSink{url: url, token: token, client: &http.Client{}}
+}
+
+func (s *WebhookSink) Write(ctx context.Context, events []*Event) error {
+	b, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit events: %w", err)
+	}
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(ctx, b)
+		if err == nil || !retry || attempt == webhookAttempts {
+			return err
+		}
+		log.Warn().Err(err).Int("attempt", attempt).Dur("backoff", backoff).Msg("failed to post audit events, retrying")
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the events once, telling whether it may succeed if retried.
+func (s *WebhookSink) post(ctx context.Context, b []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookAttemptTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(b))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kuview")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send audit events: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return retry, fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	return false, nil
+}
+
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package cluster resolves the clusters watched by a kuview server.
+package cluster
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// InCluster is the context name selecting the service account of the pod kuview runs in.
+const InCluster = "in-cluster"
+
+// NestedSeparator joins the name of an upstream kuview server and the IDs of its clusters, e.g. "prod~inner".
+const NestedSeparator = "~"
+
+// ValidateID checks that an ID can tag events. It must not contain a slash, which separates the parts of
+// the cache keys, nor NestedSeparator, so the upstream of a nested cluster is unambiguous.
+func ValidateID(id string) error {
+	if id == "" {
+		return fmt.Errorf("the ID must not be empty")
+	}
+	if strings.Contains(id, "/") || strings.Contains(id, NestedSeparator) {
+		return fmt.Errorf("the ID must not contain %q nor %q", "/", NestedSeparator)
+	}
+	return nil
+}
+
+// Nested returns the ID of a cluster of the upstream kuview server.
+func Nested(upstream, id string) string {
+	return upstream + NestedSeparator + id
+}
+
+// SplitNested returns the upstream and the inner ID of a nested cluster, as in "prod~inner".
+// ok is false if the cluster is not nested.
+func SplitNested(id string) (upstream, inner string, ok bool) {
+	return strings.Cut(id, NestedSeparator)
+}
+
+type Cluster struct {
+	// ID tags the events and cache keys of the cluster.
+	ID     string
+	Config *rest.Config
+	// Namespace is the namespace of the context, or of the pod when in cluster.
+	Namespace string
+}
+
+// Current loads the cluster of the current context, or the one kuview runs in.
+// Its ID is empty, as it is the only cluster watched.
+func Current() (Cluster, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return Cluster{}, fmt.Errorf("failed to load the config: %w", err)
+	}
+	return Cluster{Config: cfg, Namespace: namespaceOf("")}, nil
+}
+
+// namespaceOf returns the namespace of the kubeconfig context, the current one if empty.
+// It falls back to the namespace of the pod when in cluster.
+func namespaceOf(context string) string {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if f := flag.Lookup("kubeconfig"); f != nil {
+		rules.ExplicitPath = f.Value.String()
+	}
+	ns, _, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{
+		CurrentContext: context,
+	}).Namespace()
+	if err != nil {
+		return ""
+	}
+	return ns
+}
+
+// inClusterNamespace returns the namespace of the pod kuview runs in.
+func inClusterNamespace() string {
+	b, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// Parse loads the clusters of a comma separated list of kubeconfig contexts.
+// An entry may be prefixed with an ID, as in "prod=gke_prod_asia-northeast3_main", otherwise the context name is the ID.
+// e.g. "prod=prod-admin,staging,local=in-cluster"
+func Parse(spec string) ([]Cluster, error) {
+	clusters := []Cluster{}
+	seen := make(map[string]struct{})
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, context, found := strings.Cut(entry, "=")
+		if !found {
+			context = id
+		}
+		if id == "" || context == "" {
+			return nil, fmt.Errorf("invalid cluster %q", entry)
+		}
+		if err := ValidateID(id); err != nil {
+			return nil, fmt.Errorf("invalid cluster %q: %w", entry, err)
+		}
+		if _, ok := seen[id]; ok {
+			return nil, fmt.Errorf("duplicated cluster %q", id)
+		}
+		seen[id] = struct{}{}
+
+		var cfg *rest.Config
+		var ns string
+		var err error
+		if context == InCluster {
+			cfg, err = rest.InClusterConfig()
+			ns = inClusterNamespace()
+		} else {
+			cfg, err = config.GetConfigWithContext(context)
+			ns = namespaceOf(context)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the config of cluster %q: %w", id, err)
+		}
+		clusters = append(clusters, Cluster{ID: id, Config: cfg, Namespace: ns})
+	}
+	return clusters, nil
+}
@@ -0,0 +1,436 @@
+package config
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/rs/zerolog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the configuration of the kuview server.
+// It is read from a YAML file, then overridden by KUVIEW_* environment variables and flags, in this order.
+//
+//	listen:
+//	  address: ":8001"
+//	log:
+//	  level: debug
+//	clusters: ["prod=prod-admin", "staging"]
+//	kinds: ["v1/Node", "v1/Pod", "v1/Namespace"]
+//	predicates:
+//	  excludeNamespaces: [kube-system]
+//	retention:
+//	  finishedPods: 1h
+//	http:
+//	  allowOrigins: ["https://dashboard.example.com"]
+type Config struct {
+	Listen ListenConfig `json:"listen"`
+	TLS    TLSConfig    `json:"tls,omitempty"`
+	Log    LogConfig    `json:"log"`
+	// Clusters are the kubeconfig contexts to watch, optionally prefixed with an ID as in "prod=prod-admin".
+	// "in-cluster" selects the service account of the pod. Defaults to the current context.
+	Clusters []string `json:"clusters,omitempty"`
+	// Namespaces are watched instead of the whole cluster. ["auto"] detects the namespaces the user may watch pods in.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Kinds are the kinds to watch, in the apiVersion/kind notation. Defaults to every supported kind.
+	Kinds       []string          `json:"kinds,omitempty"`
+	Predicates  PredicatesConfig  `json:"predicates,omitempty"`
+	Retention   RetentionConfig   `json:"retention,omitempty"`
+	Metrics     MetricsConfig     `json:"metrics"`
+	HTTP        HTTPConfig        `json:"http"`
+	Auth        AuthConfig        `json:"auth,omitempty"`
+	Diagnostics DiagnosticsConfig `json:"diagnostics,omitempty"`
+	Alert       AlertConfig       `json:"alert,omitempty"`
+	Relay       RelayConfig       `json:"relay,omitempty"`
+	Images      ImagesConfig      `json:"images,omitempty"`
+	Audit       AuditConfig       `json:"audit,omitempty"`
+	Limits      LimitsConfig      `json:"limits"`
+}
+
+type ListenConfig struct {
+	// Address is the host:port the server listens on. Defaults to ":8001".
+	Address string `json:"address"`
+}
+
+type TLSConfig struct {
+	// CertFile and KeyFile serve HTTPS instead of HTTP if both are set. They are reloaded when rotated.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// SelfSigned serves HTTPS with a certificate generated for localhost on startup, for development.
+	SelfSigned bool `json:"selfSigned,omitempty"`
+	// ClientCAFile verifies client certificates, which authenticate clients instead of the bearer token.
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+	// ClientAuth is require to reject clients without a verified certificate, or optional. Defaults to require.
+	ClientAuth ClientAuth `json:"clientAuth,omitempty"`
+}
+
+// ClientAuth tells whether clients must present a certificate.
+type ClientAuth string
+
+const (
+	ClientAuthRequire  ClientAuth = "require"
+	ClientAuthOptional ClientAuth = "optional"
+)
+
+// Enabled reports whether the server is served over TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.SelfSigned
+}
+
+// LogFormat is how the log lines are written.
+type LogFormat string
+
+const (
+	// LogFormatConsole writes human-readable lines.
+	LogFormatConsole LogFormat = "console"
+	// LogFormatJSON writes a JSON object per line.
+	LogFormatJSON LogFormat = "json"
+)
+
+type LogConfig struct {
+	// Level is one of trace, debug, info, warn and error. Defaults to info.
+	Level string `json:"level"`
+	// Format defaults to console.
+	Format LogFormat `json:"format"`
+}
+
+// PredicatesConfig filters the changes sent to the clients.
+type PredicatesConfig struct {
+	// NodeHeartbeats sends the updates of nodes that only renew the heartbeats of their conditions.
+	NodeHeartbeats bool `json:"nodeHeartbeats,omitempty"`
+	// ExcludeNamespaces are namespaces whose objects are not sent.
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+}
+
+type RetentionConfig struct {
+	// FinishedPods is how long pods that succeeded or failed are shown after they finished. Forever if zero.
+	FinishedPods metav1.Duration `json:"finishedPods,omitempty"`
+}
+
+type MetricsConfig struct {
+	// Interval is the period at which metrics.k8s.io is polled. Defaults to 10s.
+	Interval metav1.Duration `json:"interval"`
+	// Cluster serves the cached objects as metrics in the style of kube-state-metrics at /metrics/cluster.
+	Cluster bool `json:"cluster,omitempty"`
+}
+
+type HTTPConfig struct {
+	// AllowOrigins are the origins allowed to make cross-origin requests. Defaults to ["*"].
+	AllowOrigins []string `json:"allowOrigins"`
+	// GzipLevel is the compression level of the responses, from 1 to 9. Defaults to 9.
+	GzipLevel int `json:"gzipLevel"`
+	// H2C serves HTTP/2 without TLS to clients with prior knowledge, e.g. a proxy terminating TLS.
+	// HTTP/2 is always served over TLS.
+	H2C bool `json:"h2c,omitempty"`
+	// TrustedProxies are the CIDRs of the proxies whose X-Forwarded-For header tells the address of the clients,
+	// e.g. ["10.0.0.0/8"]. The address of the peer is used if empty, so clients can not forge it.
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+}
+
+// AuthConfig configures how the API requests are authenticated. Every request is allowed if nothing is set.
+// The UI itself is public, and the verified client certificates of tls.clientCAFile are accepted too.
+type AuthConfig struct {
+	// TokenFile holds a bearer token of a user named "token", e.g. to only serve a relay.
+	TokenFile string `json:"tokenFile,omitempty"`
+	// Tokens are static bearer tokens of named users.
+	Tokens []TokenConfig `json:"tokens,omitempty"`
+	// TokenReview authenticates other bearer tokens, e.g. of service accounts, with the first cluster.
+	TokenReview TokenReviewConfig `json:"tokenReview,omitempty"`
+	// OIDC logs users of the UI in with an OpenID Connect provider.
+	OIDC OIDCConfig `json:"oidc,omitempty"`
+	// Authorization shows the users only what they may list in the clusters themselves.
+	Authorization AuthorizationConfig `json:"authorization,omitempty"`
+	// ShareLinks lets the users create expiring links to a namespace or objects, e.g. for a vendor.
+	ShareLinks ShareLinksConfig `json:"shareLinks,omitempty"`
+}
+
+// authenticates reports whether any way to authenticate the users is configured, besides share links.
+func (c AuthConfig) authenticates(tls TLSConfig) bool {
+	return c.TokenFile != "" || len(c.Tokens) > 0 || c.TokenReview.Enabled || c.OIDC.Enabled() || tls.ClientCAFile != ""
+}
+
+type TokenConfig struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+	// TokenFile holds the token, read again on reload.
+	TokenFile string `json:"tokenFile"`
+}
+
+type TokenReviewConfig struct {
+	// Enabled requires kuview to be bound to the system:auth-delegator cluster role.
+	Enabled bool `json:"enabled,omitempty"`
+	// Audiences the tokens must be issued for, the audience of the API server if empty.
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+type AuthorizationConfig struct {
+	// Enabled requires kuview to be bound to the system:auth-delegator cluster role,
+	// and allowed to impersonate the users and groups, which it reads the logs as.
+	Enabled bool `json:"enabled,omitempty"`
+	// ExemptUsers and ExemptGroups see every object kuview sees, e.g. ["token"] for a relay.
+	ExemptUsers  []string `json:"exemptUsers,omitempty"`
+	ExemptGroups []string `json:"exemptGroups,omitempty"`
+}
+
+type ShareLinksConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// SecretFile holds at least 32 bytes signing the links, shared by the replicas.
+	// Links end on restart if it is not set.
+	SecretFile string `json:"secretFile,omitempty"`
+	// MaxTTL bounds how long links may be valid, 24h by default.
+	MaxTTL metav1.Duration `json:"maxTTL,omitempty"`
+	// DenyListFile keeps the revoked links over restarts.
+	DenyListFile string `json:"denyListFile,omitempty"`
+}
+
+type OIDCConfig struct {
+	// IssuerURL enables the login, e.g. https://accounts.google.com
+	IssuerURL        string `json:"issuerURL,omitempty"`
+	ClientID         string `json:"clientID,omitempty"`
+	ClientSecretFile string `json:"clientSecretFile,omitempty"`
+	// RedirectURL is the callback registered at the provider, e.g. https://kuview.example.com/auth/callback
+	RedirectURL string `json:"redirectURL,omitempty"`
+	// Scopes default to openid, email and profile.
+	Scopes []string `json:"scopes,omitempty"`
+	// UsernameClaim defaults to email, and GroupsClaim to groups.
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	GroupsClaim   string `json:"groupsClaim,omitempty"`
+	// CookieSecretFile holds at least 32 bytes signing the session cookies, shared by the replicas.
+	// Sessions end on restart if it is not set.
+	CookieSecretFile string `json:"cookieSecretFile,omitempty"`
+	// SessionTTL defaults to 12h.
+	SessionTTL metav1.Duration `json:"sessionTTL,omitempty"`
+}
+
+// Enabled reports whether users log in with OIDC.
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != ""
+}
+
+type DiagnosticsConfig struct {
+	// ConfigFile is a YAML file configuring the diagnostics rules.
+	ConfigFile string `json:"configFile,omitempty"`
+}
+
+type AlertConfig struct {
+	// ConfigFile is a YAML file configuring the alert rules and receivers.
+	ConfigFile string `json:"configFile,omitempty"`
+	// Test sends notifications to a local stand-in that logs them instead of the configured receivers.
+	Test bool `json:"test,omitempty"`
+}
+
+type RelayConfig struct {
+	// ConfigFile is a YAML file listing the upstream kuview servers merged by `kuview relay`.
+	ConfigFile string `json:"configFile,omitempty"`
+}
+
+type ImagesConfig struct {
+	// RegistryAllowlist are glob patterns of the registries images may be pulled from, e.g. ["ghcr.io", "*.azurecr.io"]
+	RegistryAllowlist []string `json:"registryAllowlist,omitempty"`
+}
+
+// LimitsConfig bounds what a client, the user or the IP address while authentication is disabled, may open at once.
+// Zero is unlimited. Clients beyond a limit get 429 with Retry-After.
+type LimitsConfig struct {
+	// MaxSubscribers bounds the event streams and WebSocket connections. Defaults to 1000, and 50 per client.
+	MaxSubscribers          int `json:"maxSubscribers"`
+	MaxSubscribersPerClient int `json:"maxSubscribersPerClient"`
+	// MaxLogStreams bounds the logs read at once. Defaults to 100, and 10 per client.
+	MaxLogStreams          int `json:"maxLogStreams"`
+	MaxLogStreamsPerClient int `json:"maxLogStreamsPerClient"`
+	// ProxyRate is how many requests per second a client may send to the log proxy, in bursts of ProxyBurst.
+	// Defaults to 5 per second in bursts of 20.
+	ProxyRate  float64 `json:"proxyRate"`
+	ProxyBurst int     `json:"proxyBurst"`
+}
+
+// AuditConfig configures the audit stream of the subscriptions, listings, log streams and share links.
+type AuditConfig struct {
+	// File appends the events to a file as JSON lines, or to stdout if "-".
+	File    string             `json:"file,omitempty"`
+	Webhook AuditWebhookConfig `json:"webhook,omitempty"`
+}
+
+type AuditWebhookConfig struct {
+	// URL receives the events as JSON arrays, posted at most every second.
+	URL string `json:"url,omitempty"`
+	// TokenFile holds a bearer token sent to the webhook.
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() *Config {
+	return &Config{
+		Listen: ListenConfig{Address: ":8001"},
+		Log: LogConfig{
+			Level:  zerolog.InfoLevel.String(),
+			Format: LogFormatConsole,
+		},
+		Metrics: MetricsConfig{Interval: metav1.Duration{Duration: 10 * time.Second}},
+		HTTP: HTTPConfig{
+			AllowOrigins: []string{"*"},
+			GzipLevel:    gzip.BestCompression,
+		},
+		Limits: LimitsConfig{
+			MaxSubscribers:          1000,
+			MaxSubscribersPerClient: 50,
+			MaxLogStreams:           100,
+			MaxLogStreamsPerClient:  10,
+			ProxyRate:               5,
+			ProxyBurst:              20,
+		},
+	}
+}
+
+// readFile overrides the configuration with the YAML file.
+func (c *Config) readFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+	invalid := func(field, format string, args ...any) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Listen.Address); err != nil {
+		invalid("listen.address", "%v", err)
+	}
+	switch {
+	case c.TLS.SelfSigned && (c.TLS.CertFile != "" || c.TLS.KeyFile != ""):
+		invalid("tls.selfSigned", "can not be combined with certFile and keyFile")
+	case c.TLS.Enabled() && !c.TLS.SelfSigned && (c.TLS.CertFile == "" || c.TLS.KeyFile == ""):
+		invalid("tls", "certFile and keyFile must be set together")
+	}
+	if c.TLS.ClientCAFile != "" && !c.TLS.Enabled() {
+		invalid("tls.clientCAFile", "requires TLS, set certFile and keyFile or selfSigned")
+	}
+	if c.TLS.ClientAuth != "" && c.TLS.ClientAuth != ClientAuthRequire && c.TLS.ClientAuth != ClientAuthOptional {
+		invalid("tls.clientAuth", "unknown value %q, must be %s or %s", c.TLS.ClientAuth, ClientAuthRequire, ClientAuthOptional)
+	}
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil || c.Log.Level == "" {
+		invalid("log.level", "unknown level %q", c.Log.Level)
+	}
+	if c.Log.Format != LogFormatConsole && c.Log.Format != LogFormatJSON {
+		invalid("log.format", "unknown format %q, must be %s or %s", c.Log.Format, LogFormatConsole, LogFormatJSON)
+	}
+	if slices.Contains(c.Namespaces, "auto") && len(c.Namespaces) > 1 {
+		invalid("namespaces", "auto can not be combined with other namespaces")
+	}
+	known := supportedKinds()
+	for _, gvk := range c.Kinds {
+		if !slices.Contains(known, gvk) {
+			invalid("kinds", "unsupported kind %q, must be one of %v", gvk, known)
+		}
+	}
+	if c.Retention.FinishedPods.Duration < 0 {
+		invalid("retention.finishedPods", "must not be negative")
+	}
+	if c.Metrics.Interval.Duration < time.Second {
+		invalid("metrics.interval", "must be at least 1s")
+	}
+	if len(c.HTTP.AllowOrigins) == 0 {
+		invalid("http.allowOrigins", "must not be empty, use [\"*\"] to allow every origin")
+	}
+	if c.HTTP.GzipLevel < gzip.BestSpeed || c.HTTP.GzipLevel > gzip.BestCompression {
+		invalid("http.gzipLevel", "must be between %d and %d", gzip.BestSpeed, gzip.BestCompression)
+	}
+	for i, cidr := range c.HTTP.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			invalid(fmt.Sprintf("http.trustedProxies[%d]", i), "%v", err)
+		}
+	}
+	for i, t := range c.Auth.Tokens {
+		if t.Name == "" || t.TokenFile == "" {
+			invalid(fmt.Sprintf("auth.tokens[%d]", i), "name and tokenFile are required")
+		}
+	}
+	if oidc := c.Auth.OIDC; oidc.Enabled() {
+		if u, err := url.Parse(oidc.IssuerURL); err != nil || u.Scheme != "https" {
+			invalid("auth.oidc.issuerURL", "must be an https URL")
+		}
+		if oidc.ClientID == "" {
+			invalid("auth.oidc.clientID", "is required")
+		}
+		if u, err := url.Parse(oidc.RedirectURL); err != nil || u.Host == "" || u.Path != "/auth/callback" {
+			invalid("auth.oidc.redirectURL", "must be the absolute URL of /auth/callback of kuview")
+		}
+		if oidc.SessionTTL.Duration < 0 {
+			invalid("auth.oidc.sessionTTL", "must not be negative")
+		}
+	}
+	if c.Auth.Authorization.Enabled && !c.Auth.authenticates(c.TLS) {
+		invalid("auth.authorization.enabled", "requires a way to authenticate the users")
+	}
+	if c.Auth.ShareLinks.Enabled && !c.Auth.authenticates(c.TLS) {
+		invalid("auth.shareLinks.enabled", "requires a way to authenticate the users creating the links")
+	}
+	if c.Auth.ShareLinks.MaxTTL.Duration < 0 {
+		invalid("auth.shareLinks.maxTTL", "must not be negative")
+	}
+	if l := c.Limits; l.MaxSubscribers < 0 || l.MaxSubscribersPerClient < 0 || l.MaxLogStreams < 0 ||
+		l.MaxLogStreamsPerClient < 0 || l.ProxyRate < 0 || l.ProxyBurst < 0 {
+		invalid("limits", "must not be negative, 0 is unlimited")
+	}
+	if u := c.Audit.Webhook.URL; u != "" {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			invalid("audit.webhook.url", "must be an http or https URL")
+		}
+	}
+	if c.Alert.Test && c.Alert.ConfigFile == "" {
+		invalid("alert.test", "requires alert.configFile")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func supportedKinds() []string {
+	kinds := make([]string, len(types.ObjectSchemas))
+	for i, obj := range types.ObjectSchemas {
+		kinds[i] = types.FormatGVK(obj.GetObjectKind().GroupVersionKind())
+	}
+	return kinds
+}
+
+// Objects returns the schemas of the kinds to watch.
+func (c *Config) Objects() []client.Object {
+	if len(c.Kinds) == 0 {
+		return types.ObjectSchemas
+	}
+	objs := make([]client.Object, 0, len(c.Kinds))
+	for _, obj := range types.ObjectSchemas {
+		if slices.Contains(c.Kinds, types.FormatGVK(obj.GetObjectKind().GroupVersionKind())) {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// Dump returns the configuration as YAML.
+func (c *Config) Dump() ([]byte, error) {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variables overriding the flags, e.g. KUVIEW_LOG_LEVEL for -log-level.
+const EnvPrefix = "KUVIEW_"
+
+// Load builds the configuration from the defaults, the file given by -config or KUVIEW_CONFIG,
+// the environment and the flags in args, each overriding the previous one, and validates it.
+func Load(name string, args []string) (*Config, error) {
+	// The file is read before the flags are applied, so the flags are parsed once to find it.
+	var path string
+	scan := flag.NewFlagSet(name, flag.ContinueOnError)
+	scan.SetOutput(io.Discard)
+	scan.StringVar(&path, "config", os.Getenv(EnvPrefix+"CONFIG"), "")
+	bindFlags(scan, Default())
+	_ = scan.Parse(args)
+
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.String("config", path, "path to a YAML file configuring the server, also KUVIEW_CONFIG. Flags and KUVIEW_* environment variables override it")
+	bindFlags(fs, cfg)
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if !ok || f.Name == "config" || err != nil {
+			return
+		}
+		if setErr := fs.Set(f.Name, v); setErr != nil {
+			err = fmt.Errorf("invalid %s: %w", envName(f.Name), setErr)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// bindFlags defines the flags of the settings, defaulting to their current value.
+func bindFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Listen.Address, "listen-address", cfg.Listen.Address, "host:port to listen on")
+	fs.StringVar(&cfg.TLS.CertFile, "tls-cert-file", cfg.TLS.CertFile, "path to the certificate to serve HTTPS with")
+	fs.StringVar(&cfg.TLS.KeyFile, "tls-key-file", cfg.TLS.KeyFile, "path to the private key of the certificate")
+	fs.BoolVar(&cfg.TLS.SelfSigned, "tls-self-signed", cfg.TLS.SelfSigned, "serve HTTPS with a certificate generated for localhost, for development")
+	fs.StringVar(&cfg.TLS.ClientCAFile, "tls-client-ca-file", cfg.TLS.ClientCAFile, "path to the CA certificates verifying client certificates, which authenticate clients instead of the bearer token")
+	fs.Func("tls-client-auth", "require or optional client certificates (default \"require\")", func(v string) error {
+		cfg.TLS.ClientAuth = ClientAuth(v)
+		return nil
+	})
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "one of trace, debug, info, warn and error")
+	fs.Func("log-format", fmt.Sprintf("console or json (default %q)", cfg.Log.Format), func(v string) error {
+		cfg.Log.Format = LogFormat(v)
+		return nil
+	})
+	fs.Var((*stringList)(&cfg.Clusters), "clusters", "comma separated kubeconfig contexts to watch, optionally prefixed with an ID as in \"prod=prod-admin\"; \"in-cluster\" selects the service account of the pod. Defaults to the current context")
+	fs.Var((*stringList)(&cfg.Namespaces), "namespaces", "comma separated namespaces to watch instead of the whole cluster, or \"auto\" to detect the namespaces the user may watch pods in. Kinds the user may not watch are skipped then")
+	fs.Var((*stringList)(&cfg.Kinds), "kinds", "comma separated kinds to watch, e.g. \"v1/Pod,v1/Node\". Defaults to every supported kind")
+	fs.BoolVar(&cfg.Predicates.NodeHeartbeats, "node-heartbeats", cfg.Predicates.NodeHeartbeats, "send the updates of nodes that only renew their heartbeats")
+	fs.Var((*stringList)(&cfg.Predicates.ExcludeNamespaces), "exclude-namespaces", "comma separated namespaces whose objects are not sent")
+	fs.DurationVar(&cfg.Retention.FinishedPods.Duration, "finished-pod-retention", cfg.Retention.FinishedPods.Duration, "how long pods that succeeded or failed are shown after they finished, forever if 0")
+	fs.DurationVar(&cfg.Metrics.Interval.Duration, "metrics-interval", cfg.Metrics.Interval.Duration, "period at which metrics.k8s.io is polled")
+	fs.BoolVar(&cfg.Metrics.Cluster, "cluster-metrics", cfg.Metrics.Cluster, "serve the cached pods, nodes, volumes and services as metrics at /metrics/cluster, like kube-state-metrics")
+	fs.Var((*stringList)(&cfg.HTTP.AllowOrigins), "allow-origins", "comma separated origins allowed to make cross-origin requests")
+	fs.IntVar(&cfg.HTTP.GzipLevel, "gzip-level", cfg.HTTP.GzipLevel, "compression level of the responses, from 1 to 9")
+	fs.BoolVar(&cfg.HTTP.H2C, "h2c", cfg.HTTP.H2C, "serve HTTP/2 without TLS to clients with prior knowledge, e.g. a proxy terminating TLS")
+	fs.Var((*stringList)(&cfg.HTTP.TrustedProxies), "trusted-proxies", "comma separated CIDRs of the proxies whose X-Forwarded-For header tells the address of the clients")
+	fs.StringVar(&cfg.Auth.TokenFile, "token-file", cfg.Auth.TokenFile, "path to a file holding a bearer token accepted by the API, e.g. to only serve a relay")
+	fs.BoolVar(&cfg.Auth.TokenReview.Enabled, "token-review", cfg.Auth.TokenReview.Enabled, "authenticate bearer tokens, e.g. of service accounts, with the TokenReview API of the first cluster")
+	fs.BoolVar(&cfg.Auth.Authorization.Enabled, "authorization", cfg.Auth.Authorization.Enabled, "show the users only the objects and logs they may read in the clusters themselves")
+	fs.BoolVar(&cfg.Auth.ShareLinks.Enabled, "share-links", cfg.Auth.ShareLinks.Enabled, "let the users create expiring links to a namespace or objects")
+	fs.StringVar(&cfg.Auth.OIDC.IssuerURL, "oidc-issuer-url", cfg.Auth.OIDC.IssuerURL, "issuer of the OpenID Connect provider users log in with")
+	fs.StringVar(&cfg.Auth.OIDC.ClientID, "oidc-client-id", cfg.Auth.OIDC.ClientID, "client ID registered at the OpenID Connect provider")
+	fs.StringVar(&cfg.Auth.OIDC.ClientSecretFile, "oidc-client-secret-file", cfg.Auth.OIDC.ClientSecretFile, "path to the client secret registered at the OpenID Connect provider")
+	fs.StringVar(&cfg.Auth.OIDC.RedirectURL, "oidc-redirect-url", cfg.Auth.OIDC.RedirectURL, "URL of /auth/callback registered at the OpenID Connect provider")
+	fs.StringVar(&cfg.Auth.OIDC.CookieSecretFile, "oidc-cookie-secret-file", cfg.Auth.OIDC.CookieSecretFile, "path to at least 32 bytes signing the session cookies")
+	fs.StringVar(&cfg.Diagnostics.ConfigFile, "diagnostics-config", cfg.Diagnostics.ConfigFile, "path to a YAML file configuring the diagnostics rules")
+	fs.IntVar(&cfg.Limits.MaxSubscribers, "max-subscribers", cfg.Limits.MaxSubscribers, "maximum number of event streams and WebSocket connections, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.MaxSubscribersPerClient, "max-subscribers-per-client", cfg.Limits.MaxSubscribersPerClient, "maximum number of event streams and WebSocket connections of a user or IP address")
+	fs.IntVar(&cfg.Limits.MaxLogStreams, "max-log-streams", cfg.Limits.MaxLogStreams, "maximum number of logs read at once, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.MaxLogStreamsPerClient, "max-log-streams-per-client", cfg.Limits.MaxLogStreamsPerClient, "maximum number of logs read at once by a user or IP address")
+	fs.Float64Var(&cfg.Limits.ProxyRate, "proxy-rate", cfg.Limits.ProxyRate, "requests per second a user or IP address may send to the log proxy, 0 for unlimited")
+	fs.IntVar(&cfg.Limits.ProxyBurst, "proxy-burst", cfg.Limits.ProxyBurst, "requests a user or IP address may send to the log proxy at once")
+	fs.StringVar(&cfg.Audit.File, "audit-file", cfg.Audit.File, "path to a file the audit events are appended to, - for stdout")
+	fs.StringVar(&cfg.Audit.Webhook.URL, "audit-webhook-url", cfg.Audit.Webhook.URL, "URL the audit events are posted to")
+	fs.StringVar(&cfg.Alert.ConfigFile, "alert-config", cfg.Alert.ConfigFile, "path to a YAML file configuring the alert rules and receivers")
+	fs.BoolVar(&cfg.Alert.Test, "alert-test", cfg.Alert.Test, "send notifications to a local stand-in that logs them instead of the configured receivers")
+	fs.StringVar(&cfg.Relay.ConfigFile, "relay-config", cfg.Relay.ConfigFile, "path to a YAML file listing the upstream kuview servers merged by kuview relay")
+	fs.Var((*stringList)(&cfg.Images.RegistryAllowlist), "image-registry-allowlist", "comma separated glob patterns of the registries images may be pulled from, e.g. \"ghcr.io,*.azurecr.io\"")
+}
+
+// stringList is a comma separated flag. Setting it replaces the list.
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = nil
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// reloadable are the settings applied on reload, by their path in the file.
+var reloadable = []string{
+	"log.level", "http.allowOrigins", "auth.tokenFile", "auth.tokens",
+	"limits.maxSubscribers", "limits.maxSubscribersPerClient", "limits.maxLogStreams",
+	"limits.maxLogStreamsPerClient", "limits.proxyRate", "limits.proxyBurst",
+}
+
+// RestartRequired lists the settings of next that differ from c and are only applied on restart.
+func (c *Config) RestartRequired(next *Config) []string {
+	a, b := flatten(c), flatten(next)
+	var changed []string
+	for key := range a {
+		if !reflect.DeepEqual(a[key], b[key]) && !slices.Contains(reloadable, key) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok && !slices.Contains(reloadable, key) {
+			changed = append(changed, key)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// flatten maps the settings by their path in the file, e.g. "log.level".
+func flatten(c *Config) map[string]any {
+	b, _ := json.Marshal(c)
+	var m map[string]any
+	_ = json.Unmarshal(b, &m)
+	flat := make(map[string]any)
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			if nested, ok := v.(map[string]any); ok {
+				walk(prefix+k+".", nested)
+				continue
+			}
+			flat[prefix+k] = v
+		}
+	}
+	walk("", m)
+	return flat
+}
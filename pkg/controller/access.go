@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// canWatch reports whether the user may list and watch the resource, in the namespace or cluster-wide if empty.
+func canWatch(ctx context.Context, cl client.Client, gvr schema.GroupVersionResource, namespace string) (bool, error) {
+	for _, verb := range []string{"list", "watch"} {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      verb,
+					Group:     gvr.Group,
+					Version:   gvr.Version,
+					Resource:  gvr.Resource,
+				},
+			},
+		}
+		if err := cl.Create(ctx, review); err != nil {
+			return false, fmt.Errorf("failed to review access to %s: %w", gvr.Resource, err)
+		}
+		if !review.Status.Allowed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// probeAccess drops the kinds the user may not watch and reports them to the tracker.
+// If namespaces are given, the cache is limited to them: namespaced kinds are watched in the namespaces
+// they are allowed in, and cluster-scoped kinds are watched if allowed cluster-wide.
+// Kinds whose access can not be reviewed are watched anyway, leaving errors to the watch error handler.
+func probeAccess(ctx context.Context, cfg *rest.Config, objs []client.Object, namespaces []string, tracker *syncTracker) (cache.Options, []client.Object, error) {
+	opts := cache.Options{}
+	if len(namespaces) > 0 {
+		opts.DefaultNamespaces = make(map[string]cache.Config, len(namespaces))
+		opts.ByObject = make(map[client.Object]cache.ByObject)
+		for _, ns := range namespaces {
+			opts.DefaultNamespaces[ns] = cache.Config{}
+		}
+	}
+
+	cl, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return opts, nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	watched := make([]client.Object, 0, len(objs))
+	for _, obj := range objs {
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		mapping, err := cl.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			log.Warn().Err(err).Str("kind", gvk.Kind).Msg("skipping a kind the API server does not serve")
+			tracker.disable(obj, false, err.Error())
+			continue
+		} else if err != nil {
+			log.Warn().Err(err).Str("kind", gvk.Kind).Msg("failed to map kind, watching it anyway")
+			tracker.track(obj)
+			watched = append(watched, obj)
+			continue
+		}
+
+		scopes := namespaces
+		if len(namespaces) == 0 || mapping.Scope.Name() == meta.RESTScopeNameRoot {
+			// cluster-wide
+			scopes = []string{""}
+		}
+		allowed := make(map[string]cache.Config)
+		for _, ns := range scopes {
+			ok, err := canWatch(ctx, cl, mapping.Resource, ns)
+			if err != nil {
+				log.Warn().Err(err).Str("kind", gvk.Kind).Msg("failed to review access, watching the kind anyway")
+				ok = true
+			}
+			if ok {
+				allowed[ns] = cache.Config{}
+			}
+		}
+		switch {
+		case len(allowed) == 0:
+			log.Warn().Str("kind", gvk.Kind).Msg("skipping a kind the user may not watch")
+			tracker.disable(obj, true, fmt.Sprintf("the user may not list and watch %s", mapping.Resource.Resource))
+			continue
+		case len(allowed) < len(scopes):
+			log.Warn().Str("kind", gvk.Kind).Int("namespaces", len(allowed)).Msg("watching a kind in the allowed namespaces only")
+			opts.ByObject[obj] = cache.ByObject{Namespaces: allowed}
+		}
+		tracker.track(obj)
+		watched = append(watched, obj)
+	}
+	return opts, watched, nil
+}
+
+// AccessibleNamespaces detects the namespaces the user may watch pods in.
+// It returns nil if pods may be watched cluster-wide, and the fallback if namespaces may not be listed.
+func AccessibleNamespaces(ctx context.Context, cfg *rest.Config, fallback string) ([]string, error) {
+	cl, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	pods := v1.SchemeGroupVersion.WithResource("pods")
+
+	ok, err := canWatch(ctx, cl, pods, "")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, nil
+	}
+
+	list := &v1.NamespaceList{}
+	if err := cl.List(ctx, list); err != nil {
+		if !apierrors.IsForbidden(err) {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		if fallback == "" {
+			return nil, fmt.Errorf("namespaces may not be listed and no default namespace is configured")
+		}
+		return []string{fallback}, nil
+	}
+
+	namespaces := []string{}
+	for _, ns := range list.Items {
+		ok, err := canWatch(ctx, cl, pods, ns.Name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("pods may not be watched in any namespace")
+	}
+	return namespaces, nil
+}
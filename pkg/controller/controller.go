@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"slices"
+	"time"
 
 	"github.com/go-logr/logr"
 	kulog "github.com/iwanhae/kuview/pkg/logger"
@@ -11,7 +13,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -22,21 +26,56 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
-func New(ctx context.Context, cfg rest.Config, objs []client.Object, emitter Emitter) (manager.Manager, error) {
+type Options struct {
+	// Cluster labels the metrics of the controller, see pkg/server/metrics.go.
+	Cluster string
+	// Namespaces limits the cache to the namespaces.
+	// Everything is watched cluster-wide if empty.
+	Namespaces []string
+	// ExcludeNamespaces are namespaces whose objects are not emitted.
+	ExcludeNamespaces []string
+	// NodeHeartbeats emits the updates of nodes that only renew the heartbeats of their conditions.
+	NodeHeartbeats bool
+	// FinishedPodRetention is how long pods that succeeded or failed are emitted after they finished.
+	// They are emitted as deleted then. Forever if zero.
+	FinishedPodRetention time.Duration
+	// MetricsInterval is the period at which metrics.k8s.io is polled. Defaults to 10s.
+	MetricsInterval time.Duration
+}
+
+// New creates a manager emitting the objects of the kinds the user may watch.
+// Every kind is reported as a SyncStatus object, including the ones skipped because they are forbidden.
+func New(ctx context.Context, cfg rest.Config, objs []client.Object, emitter Emitter, opts Options) (manager.Manager, error) {
 	logger := logr.New(kulog.New(zlog.Logger))
 	clog.SetLogger(logger)
 
-	go parseMetricsLoop(ctx, cfg, emitter)
+	tracker := newSyncTracker(emitter)
+	cacheOpts, objs, err := probeAccess(ctx, &cfg, objs, opts.Namespaces, tracker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe access: %w", err)
+	}
+	cacheOpts.DefaultWatchErrorHandler = tracker.watchError
+
+	go parseMetricsLoop(ctx, cfg, emitter, opts)
 
 	mgr, err := manager.New(&cfg, manager.Options{
-		LeaderElection:   false,
+		Cache:          cacheOpts,
+		LeaderElection: false,
+		// kuview serves the metrics of every cluster at /metrics, rather than a server per cluster
 		Metrics:          server.Options{BindAddress: "0"},
 		PprofBindAddress: "0",
 		Logger:           logger,
+		// a manager is created per cluster, each with the same controllers
+		Controller: config.Controller{SkipNameValidation: ptr.To(true)},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create manager: %w", err)
 	}
+	tracker.cache = mgr.GetCache()
+	tracker.scheme = mgr.GetScheme()
+	if err := mgr.Add(tracker); err != nil {
+		return nil, fmt.Errorf("failed to add sync tracker: %w", err)
+	}
 
 	for _, obj := range objs {
 		// Get dereferenced type of the object
@@ -45,10 +84,11 @@ func New(ctx context.Context, cfg rest.Config, objs []client.Object, emitter Emi
 			fmt.Sprintf("kuview_%s/%s", obj.GetObjectKind().GroupVersionKind().Group, obj.GetObjectKind().GroupVersionKind().Kind),
 			mgr, controller.Options{
 				Reconciler: &dummyReconciler{
-					T:       T,
-					obj:     obj,
-					client:  mgr.GetClient(),
-					emitter: emitter,
+					T:         T,
+					obj:       obj,
+					client:    mgr.GetClient(),
+					emitter:   emitter,
+					retention: opts.FinishedPodRetention,
 				},
 			})
 		if err != nil {
@@ -57,11 +97,11 @@ func New(ctx context.Context, cfg rest.Config, objs []client.Object, emitter Emi
 
 		var p predicate.TypedPredicate[client.Object] = predicate.NewPredicateFuncs(
 			func(object client.Object) bool {
-				return true
+				return !slices.Contains(opts.ExcludeNamespaces, object.GetNamespace())
 			},
 		)
 		// in case of Node, filter out heartbeat events
-		if obj.GetObjectKind().GroupVersionKind().String() == "/v1, Kind=Node" {
+		if obj.GetObjectKind().GroupVersionKind().String() == "/v1, Kind=Node" && !opts.NodeHeartbeats {
 			p = predicate.TypedFuncs[client.Object]{
 				CreateFunc: func(e event.TypedCreateEvent[client.Object]) bool {
 					return true
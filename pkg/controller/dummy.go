@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"reflect"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -14,6 +15,8 @@ type dummyReconciler struct {
 	obj     client.Object
 	client  client.Reader
 	emitter Emitter
+	// retention of finished pods, forever if zero
+	retention time.Duration
 }
 
 func (r *dummyReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
@@ -21,22 +24,36 @@ func (r *dummyReconciler) Reconcile(ctx context.Context, req reconcile.Request)
 	err := r.client.Get(ctx, req.NamespacedName, obj)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			obj.GetObjectKind().SetGroupVersionKind(r.obj.GetObjectKind().GroupVersionKind())
-			obj.SetNamespace(req.Namespace)
-			obj.SetName(req.Name)
-			r.emitter.Emit(&Event{
-				Type:   EventTypeDelete,
-				Object: obj,
-			})
+			r.emitDelete(req, obj)
 			return reconcile.Result{}, nil
 		}
 		return reconcile.Result{}, err
 	}
 
+	// finished pods past their retention are shown as deleted
+	expiry, expires := expiresIn(obj, r.retention)
+	if expires && expiry <= 0 {
+		r.emitDelete(req, obj)
+		return reconcile.Result{}, nil
+	}
+
 	r.emitter.Emit(&Event{
 		Type:   EventTypeCreate,
 		Object: obj,
 	})
 
+	if expires {
+		return reconcile.Result{RequeueAfter: expiry}, nil
+	}
 	return reconcile.Result{}, nil
 }
+
+func (r *dummyReconciler) emitDelete(req reconcile.Request, obj client.Object) {
+	obj.GetObjectKind().SetGroupVersionKind(r.obj.GetObjectKind().GroupVersionKind())
+	obj.SetNamespace(req.Namespace)
+	obj.SetName(req.Name)
+	r.emitter.Emit(&Event{
+		Type:   EventTypeDelete,
+		Object: obj,
+	})
+}
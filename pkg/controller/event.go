@@ -1,6 +1,10 @@
 package controller
 
-import "sigs.k8s.io/controller-runtime/pkg/client"
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
 
 type Emitter interface {
 	Emit(v *Event)
@@ -9,6 +13,13 @@ type Emitter interface {
 type Event struct {
 	Type   EventType     `json:"type"`
 	Object client.Object `json:"object"`
+	// Cluster is the ID of the cluster the object belongs to. It is empty when a single cluster is watched.
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// Key returns the key identifying the object of the event among all clusters and kinds.
+func (e *Event) Key() string {
+	return ClusterObjectKey(e.Cluster, e.Object)
 }
 
 type EventType string
@@ -17,3 +28,35 @@ const (
 	EventTypeCreate EventType = "create"
 	EventTypeDelete EventType = "delete"
 )
+
+// ObjectKey returns the key identifying the object among all kinds.
+// Format: group/version/kind/namespace/name
+func ObjectKey(obj client.Object) string {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	return fmt.Sprintf("%s/%s/%s/%s/%s", gvk.Group, gvk.Version, gvk.Kind, obj.GetNamespace(), obj.GetName())
+}
+
+// ClusterObjectKey returns the key identifying the object among all clusters and kinds.
+// Format: cluster/group/version/kind/namespace/name, or the ObjectKey if the cluster is empty.
+func ClusterObjectKey(cluster string, obj client.Object) string {
+	if cluster == "" {
+		return ObjectKey(obj)
+	}
+	return cluster + "/" + ObjectKey(obj)
+}
+
+// WithCluster returns an emitter tagging the events with the cluster before passing them to the next emitter.
+func WithCluster(cluster string, next Emitter) Emitter {
+	return &clusterEmitter{cluster: cluster, next: next}
+}
+
+type clusterEmitter struct {
+	cluster string
+	next    Emitter
+}
+
+func (e *clusterEmitter) Emit(v *Event) {
+	evt := *v
+	evt.Cluster = e.cluster
+	e.next.Emit(&evt)
+}
@@ -2,23 +2,45 @@ package controller
 
 import (
 	"context"
+	"slices"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	metricsLoopFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kuview_metrics_poll_failures_total",
+		Help: "Number of failures to poll metrics.k8s.io, by cluster and resource.",
+	}, []string{"cluster", "resource"})
+	metricsLoopUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kuview_metrics_loop_up",
+		Help: "Whether metrics.k8s.io is polled, by cluster. The loop gives up after repeated failures.",
+	}, []string{"cluster"})
 )
 
 func init() {
 	metricsv1beta1.AddToScheme(scheme.Scheme)
+	ctrlmetrics.Registry.MustRegister(metricsLoopFailures, metricsLoopUp)
 }
 
-// parseMetricsLoop emits events for metrics.k8s.io/v1beta1 resources, if available
-func parseMetricsLoop(ctx context.Context, cfg rest.Config, emitter Emitter) {
+// parseMetricsLoop emits events for metrics.k8s.io/v1beta1 resources, if available.
+// Pod metrics are listed per namespace if namespaces are given.
+func parseMetricsLoop(ctx context.Context, cfg rest.Config, emitter Emitter, opts Options) {
+	interval := opts.MetricsInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
 	cfg.GroupVersion = &schema.GroupVersion{
 		Group:   "metrics.k8s.io",
 		Version: "v1beta1",
@@ -36,64 +58,80 @@ func parseMetricsLoop(ctx context.Context, cfg rest.Config, emitter Emitter) {
 	}
 
 	log.Info().Msg("starting metrics loop")
+	metricsLoopUp.WithLabelValues(opts.Cluster).Set(1)
+	defer metricsLoopUp.WithLabelValues(opts.Cluster).Set(0)
 
 	failcount := 0
 	// Maps to track resources from previous iterations for GC
 	previousNodes := make(map[string]*metricsv1beta1.NodeMetrics)
 	previousPods := make(map[string]*metricsv1beta1.PodMetrics)
+	// set once node metrics turn out to be forbidden, e.g. to a user with access to some namespaces only
+	skipNodes := false
 
 	for ctx.Err() == nil {
 		currentNodes := make(map[string]*metricsv1beta1.NodeMetrics)
 
-		nodes := &metricsv1beta1.NodeMetricsList{}
-		if err := cl.Get().AbsPath("/apis/metrics.k8s.io/v1beta1/nodes").Do(ctx).Into(nodes); err != nil {
-			log.Error().Err(err).Msg("failed to get nodes")
-			failcount++
-		} else {
-			failcount = 0
-			for _, node := range nodes.Items {
-				if node.Usage.Cpu().IsZero() || node.Usage.Memory().IsZero() {
-					// Some Prometheus-based metrics services have bugs that incorrectly report the total CPU usage as zero, which is nonsensical.
-					// Skip for this time.
-					log.Warn().
-						Str("node", node.Name).
-						Msg("some node metrics report a CPU usage of 0. skip this data.")
-					continue
+		if !skipNodes {
+			nodes := &metricsv1beta1.NodeMetricsList{}
+			if err := cl.Get().AbsPath("/apis/metrics.k8s.io/v1beta1/nodes").Do(ctx).Into(nodes); err != nil {
+				if apierrors.IsForbidden(err) {
+					log.Warn().Err(err).Msg("node metrics are forbidden, skipping them")
+					skipNodes = true
+				} else {
+					log.Error().Err(err).Msg("failed to get nodes")
+					metricsLoopFailures.WithLabelValues(opts.Cluster, "nodes").Inc()
+					failcount++
 				}
-				node.APIVersion = "metrics.k8s.io/v1beta1"
-				node.Kind = "NodeMetrics"
-
-				nodeCopy := node
-				currentNodes[node.Name] = &nodeCopy
+			} else {
+				failcount = 0
+				for _, node := range nodes.Items {
+					if node.Usage.Cpu().IsZero() || node.Usage.Memory().IsZero() {
+						// Some Prometheus-based metrics services have bugs that incorrectly report the total CPU usage as zero, which is nonsensical.
+						// Skip for this time.
+						log.Warn().
+							Str("node", node.Name).
+							Msg("some node metrics report a CPU usage of 0. skip this data.")
+						continue
+					}
+					node.APIVersion = "metrics.k8s.io/v1beta1"
+					node.Kind = "NodeMetrics"
+
+					nodeCopy := node
+					currentNodes[node.Name] = &nodeCopy
 
-				emitter.Emit(&Event{
-					Type:   EventTypeCreate,
-					Object: &node,
-				})
-			}
-
-			// GC: emit Delete events for nodes that existed before but are now gone
-			for nodeName, prevNode := range previousNodes {
-				if _, exists := currentNodes[nodeName]; !exists {
-					log.Info().Str("node", nodeName).Msg("node metrics disappeared, emitting delete event")
 					emitter.Emit(&Event{
-						Type:   EventTypeDelete,
-						Object: prevNode,
+						Type:   EventTypeCreate,
+						Object: &node,
 					})
 				}
+
+				// GC: emit Delete events for nodes that existed before but are now gone
+				for nodeName, prevNode := range previousNodes {
+					if _, exists := currentNodes[nodeName]; !exists {
+						log.Info().Str("node", nodeName).Msg("node metrics disappeared, emitting delete event")
+						emitter.Emit(&Event{
+							Type:   EventTypeDelete,
+							Object: prevNode,
+						})
+					}
+				}
+				previousNodes = currentNodes
 			}
-			previousNodes = currentNodes
 		}
 
 		currentPods := make(map[string]*metricsv1beta1.PodMetrics)
 
 		pods := &metricsv1beta1.PodMetricsList{}
-		if err := cl.Get().AbsPath("/apis/metrics.k8s.io/v1beta1/pods").Do(ctx).Into(pods); err != nil {
+		if err := listPodMetrics(ctx, cl, opts.Namespaces, pods); err != nil {
 			log.Error().Err(err).Msg("failed to get pods")
+			metricsLoopFailures.WithLabelValues(opts.Cluster, "pods").Inc()
 			failcount++
 		} else {
 			failcount = 0
 			for _, pod := range pods.Items {
+				if slices.Contains(opts.ExcludeNamespaces, pod.Namespace) {
+					continue
+				}
 				pod.APIVersion = "metrics.k8s.io/v1beta1"
 				pod.Kind = "PodMetrics"
 
@@ -127,6 +165,26 @@ func parseMetricsLoop(ctx context.Context, cfg rest.Config, emitter Emitter) {
 			log.Error().Msg("failed more than 10 times, exiting")
 			return
 		}
-		time.Sleep(10 * time.Second)
+		time.Sleep(interval)
+	}
+}
+
+// listPodMetrics lists the pod metrics of the namespaces, or of all namespaces if none is given.
+// The namespaces whose metrics are forbidden or that do not exist anymore are skipped.
+func listPodMetrics(ctx context.Context, cl *rest.RESTClient, namespaces []string, pods *metricsv1beta1.PodMetricsList) error {
+	if len(namespaces) == 0 {
+		return cl.Get().AbsPath("/apis/metrics.k8s.io/v1beta1/pods").Do(ctx).Into(pods)
+	}
+	for _, ns := range namespaces {
+		list := &metricsv1beta1.PodMetricsList{}
+		if err := cl.Get().AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", ns, "pods").Do(ctx).Into(list); err != nil {
+			if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+				log.Debug().Err(err).Str("namespace", ns).Msg("skipping the pod metrics of namespace")
+				continue
+			}
+			return err
+		}
+		pods.Items = append(pods.Items, list.Items...)
 	}
+	return nil
 }
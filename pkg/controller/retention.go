@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// expiresIn returns how long the object is kept before it is treated as deleted, if it is a finished pod.
+// It returns ok false for objects kept forever.
+func expiresIn(obj client.Object, retention time.Duration) (d time.Duration, ok bool) {
+	pod, isPod := obj.(*v1.Pod)
+	if retention <= 0 || !isPod {
+		return 0, false
+	}
+	if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+		return 0, false
+	}
+	return retention - time.Since(podFinishedAt(pod)), true
+}
+
+// podFinishedAt returns when the last container of a finished pod terminated,
+// or when it was created if none reports it, e.g. a pod evicted before it started.
+func podFinishedAt(pod *v1.Pod) time.Time {
+	finished := pod.CreationTimestamp.Time
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, s := range statuses {
+			if t := s.State.Terminated; t != nil && t.FinishedAt.After(finished) {
+				finished = t.FinishedAt.Time
+			}
+		}
+	}
+	return finished
+}
@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/rs/zerolog/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// syncInterval is the period at which the informers are inspected.
+	syncInterval = 5 * time.Second
+	// syncPollInterval is the shorter period used until every watched kind has synced.
+	syncPollInterval = 500 * time.Millisecond
+	// errorWindow is how long a kind is considered erroring after its last error.
+	// The reflectors retry with a backoff shorter than this, so a persistent error keeps it erroring.
+	errorWindow = time.Minute
+)
+
+var syncStatusGVK = types.KuviewGroupVersion.WithKind("SyncStatus")
+
+// syncTracker reports whether every kind can be watched as SyncStatus objects.
+type syncTracker struct {
+	emitter Emitter
+	// cache and scheme are set once the manager is created
+	cache  cache.Cache
+	scheme *runtime.Scheme
+
+	mu    sync.Mutex
+	kinds []*kindState
+	// byType maps the type descriptions of the reflectors to the kinds, e.g. "*v1.Pod"
+	byType map[string]*kindState
+}
+
+type kindState struct {
+	obj     client.Object
+	gvk     schema.GroupVersionKind
+	watched bool
+	status  types.SyncStatusSpec
+	// emitted is the status last emitted, nil before the first emission
+	emitted *types.SyncStatusSpec
+}
+
+var _ manager.Runnable = (*syncTracker)(nil)
+
+func newSyncTracker(emitter Emitter) *syncTracker {
+	return &syncTracker{
+		emitter: emitter,
+		byType:  make(map[string]*kindState),
+	}
+}
+
+// track registers a kind that is watched.
+func (t *syncTracker) track(obj client.Object) {
+	t.add(obj, true, false, "")
+}
+
+// disable registers a kind that is not watched for the reason.
+func (t *syncTracker) disable(obj client.Object, forbidden bool, reason string) {
+	t.add(obj, false, forbidden, reason)
+}
+
+func (t *syncTracker) add(obj client.Object, watched bool, forbidden bool, reason string) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	k := &kindState{
+		obj:     obj,
+		gvk:     gvk,
+		watched: watched,
+		status: types.SyncStatusSpec{
+			GVK:       types.FormatGVK(gvk),
+			Forbidden: forbidden,
+			LastError: reason,
+		},
+	}
+	if reason != "" {
+		now := metav1.Now()
+		k.status.LastErrorTime = &now
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.kinds = append(t.kinds, k)
+	t.byType[reflect.TypeOf(obj).String()] = k
+}
+
+// watchError is the watch error handler of the informers. It replaces the default one logging every retry.
+func (t *syncTracker) watchError(ctx context.Context, r *toolscache.Reflector, err error) {
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// the watch was closed and is restarted
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k, ok := t.byType[r.TypeDescription()]
+	if !ok {
+		return
+	}
+	if !k.status.Erroring || k.status.LastError != err.Error() {
+		log.Warn().Err(err).Str("kind", k.gvk.Kind).Msg("failed to watch")
+	}
+	now := metav1.Now()
+	k.status.Erroring = true
+	k.status.Forbidden = apierrors.IsForbidden(err)
+	k.status.LastError = err.Error()
+	k.status.LastErrorTime = &now
+}
+
+// Start implements manager.Runnable.
+func (t *syncTracker) Start(ctx context.Context) error {
+	var lastFull time.Time
+	for {
+		full := time.Since(lastFull) >= syncInterval
+		if full {
+			lastFull = time.Now()
+		}
+		interval := syncInterval
+		if !t.inspect(ctx, full) {
+			interval = syncPollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// inspect refreshes the status of every kind and emits the changed ones.
+// Unless full, only the kinds that have not synced yet are inspected.
+// It reports whether every watched kind has synced.
+func (t *syncTracker) inspect(ctx context.Context, full bool) bool {
+	allSynced := true
+	t.mu.Lock()
+	kinds := append([]*kindState(nil), t.kinds...)
+	t.mu.Unlock()
+
+	for _, k := range kinds {
+		if !full && (!k.watched || k.status.Synced) {
+			continue
+		}
+		synced, objects := false, 0
+		if k.watched {
+			synced, objects = t.count(ctx, k)
+			allSynced = allSynced && synced
+		}
+
+		t.mu.Lock()
+		k.status.Synced = synced
+		k.status.Objects = objects
+		if k.status.Erroring && time.Since(k.status.LastErrorTime.Time) > errorWindow {
+			k.status.Erroring = false
+			k.status.Forbidden = false
+		}
+		status := k.status
+		changed := k.emitted == nil || !reflect.DeepEqual(*k.emitted, status)
+		if changed {
+			k.emitted = &status
+		}
+		t.mu.Unlock()
+
+		if !changed {
+			continue
+		}
+		t.emitter.Emit(&Event{
+			Type: EventTypeCreate,
+			Object: &types.SyncStatus{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: syncStatusGVK.GroupVersion().String(),
+					Kind:       syncStatusGVK.Kind,
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: syncStatusName(k.gvk),
+				},
+				Spec: status,
+			},
+		})
+	}
+	return allSynced
+}
+
+// count reports whether the informer of the kind has synced and how many objects it holds.
+func (t *syncTracker) count(ctx context.Context, k *kindState) (bool, int) {
+	informer, err := t.cache.GetInformer(ctx, k.obj, cache.BlockUntilSynced(false))
+	if err != nil || !informer.HasSynced() {
+		return false, 0
+	}
+	obj, err := t.scheme.New(k.gvk.GroupVersion().WithKind(k.gvk.Kind + "List"))
+	if err != nil {
+		return true, 0
+	}
+	list, ok := obj.(client.ObjectList)
+	if !ok {
+		return true, 0
+	}
+	if err := t.cache.List(ctx, list, client.UnsafeDisableDeepCopy); err != nil {
+		return true, 0
+	}
+	return true, meta.LenList(list)
+}
+
+// syncStatusName returns the name of the SyncStatus of a kind. e.g. "pod.v1", "clusterrole.v1.rbac.authorization.k8s.io"
+func syncStatusName(gvk schema.GroupVersionKind) string {
+	name := strings.ToLower(gvk.Kind) + "." + gvk.Version
+	if gvk.Group != "" {
+		name += "." + gvk.Group
+	}
+	return name
+}
+
+// clusterStatusName is the name of the SyncStatus reporting that a cluster cannot be watched at all.
+const clusterStatusName = "cluster"
+
+// ClusterStatus returns the event of the SyncStatus reporting that the cluster cannot be watched because of err,
+// e.g. as its API server is unreachable. If err is nil, the event deletes it once the cluster is watched again.
+// The status has no GVK, as it is not about a kind.
+func ClusterStatus(err error) *Event {
+	status := &types.SyncStatus{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: syncStatusGVK.GroupVersion().String(),
+			Kind:       syncStatusGVK.Kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterStatusName,
+		},
+	}
+	if err == nil {
+		return &Event{Type: EventTypeDelete, Object: status}
+	}
+	now := metav1.Now()
+	status.Spec = types.SyncStatusSpec{
+		Erroring:      true,
+		LastError:     err.Error(),
+		LastErrorTime: &now,
+	}
+	return &Event{Type: EventTypeCreate, Object: status}
+}
@@ -0,0 +1,36 @@
+// Package expr compiles the CEL expressions users write in the configuration of kuview.
+package expr
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+)
+
+// costLimit bounds the work a single expression may do on one object.
+const costLimit = 1_000_000
+
+// NewEnv returns the environment expressions are compiled in.
+// Expressions can access the evaluated object as `object` and the evaluation time as `now`.
+func NewEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("now", cel.TimestampType),
+		ext.Strings(),
+		ext.Lists(),
+		ext.Sets(),
+	)
+}
+
+// Compile compiles an expression, checking that it evaluates to the expected type.
+func Compile(env *cel.Env, expr string, want *cel.Type) (cel.Program, error) {
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	if !ast.OutputType().IsExactType(want) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must evaluate to %s, got %s", want, ast.OutputType())
+	}
+	return env.Program(ast, cel.CostLimit(costLimit))
+}
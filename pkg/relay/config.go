@@ -0,0 +1,102 @@
+package relay
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+// Config lists the kuview servers a relay subscribes to.
+//
+//	upstreams:
+//	  - name: prod
+//	    url: https://kuview.prod.example.com
+//	    tokenFile: /var/run/secrets/kuview/prod-token
+//	  - name: staging
+//	    url: https://kuview.staging.example.com
+//	    tls:
+//	      caFile: /etc/kuview/ca.crt
+//	      certFile: /etc/kuview/relay.crt
+//	      keyFile: /etc/kuview/relay.key
+type Config struct {
+	Upstreams []UpstreamConfig `json:"upstreams"`
+}
+
+type UpstreamConfig struct {
+	// Name is the cluster ID the objects of the upstream are tagged with.
+	Name string `json:"name"`
+	// URL is the base URL of the upstream kuview server, without the /kuview path.
+	URL string `json:"url"`
+	// Token is sent as a bearer token. TokenFile is read on every connection, so it may be rotated.
+	Token     string    `json:"token,omitempty"`
+	TokenFile string    `json:"tokenFile,omitempty"`
+	TLS       TLSConfig `json:"tls,omitempty"`
+}
+
+type TLSConfig struct {
+	// CAFile verifies the certificate of the upstream. The system roots are used if empty.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the client certificate presented to the upstream for mutual TLS.
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relay config: %w", err)
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse relay config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Clusters validates the config and returns the upstreams as clusters.
+// Their configs point to the upstream kuview servers, which proxy the requests to their API servers.
+func (c *Config) Clusters() ([]cluster.Cluster, error) {
+	if len(c.Upstreams) == 0 {
+		return nil, fmt.Errorf("no upstream is configured")
+	}
+	clusters := make([]cluster.Cluster, 0, len(c.Upstreams))
+	seen := make(map[string]struct{})
+	for _, u := range c.Upstreams {
+		if err := cluster.ValidateID(u.Name); err != nil {
+			return nil, fmt.Errorf("invalid upstream name %q: %w", u.Name, err)
+		}
+		if _, ok := seen[u.Name]; ok {
+			return nil, fmt.Errorf("duplicated upstream %q", u.Name)
+		}
+		seen[u.Name] = struct{}{}
+
+		parsed, err := url.Parse(u.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("invalid url %q of upstream %q", u.URL, u.Name)
+		}
+		if u.Token != "" && u.TokenFile != "" {
+			return nil, fmt.Errorf("upstream %q: token and tokenFile are mutually exclusive", u.Name)
+		}
+		clusters = append(clusters, cluster.Cluster{
+			ID: u.Name,
+			Config: &rest.Config{
+				Host:            strings.TrimSuffix(u.URL, "/"),
+				BearerToken:     u.Token,
+				BearerTokenFile: u.TokenFile,
+				TLSClientConfig: rest.TLSClientConfig{
+					CAFile:   u.TLS.CAFile,
+					CertFile: u.TLS.CertFile,
+					KeyFile:  u.TLS.KeyFile,
+					Insecure: u.TLS.InsecureSkipVerify,
+				},
+			},
+		})
+	}
+	return clusters, nil
+}
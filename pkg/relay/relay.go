@@ -0,0 +1,312 @@
+// Package relay subscribes to the event streams of other kuview servers
+// and re-publishes their objects tagged with the name of the upstream as the cluster.
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/server"
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+	// settleTimeout is how long the stream must be quiet after connecting before the snapshot is considered
+	// complete and the objects missing from it are deleted, if the upstream does not send the end of the snapshot.
+	settleTimeout = 10 * time.Second
+	// statusInterval is the period at which the object count of the status is refreshed.
+	statusInterval = 10 * time.Second
+	// idleTimeout is how long the stream may be silent before the connection is considered lost.
+	// Upstreams send a heartbeat every server.HeartbeatInterval.
+	idleTimeout = 3 * server.HeartbeatInterval
+)
+
+var statusGVK = types.KuviewGroupVersion.WithKind("UpstreamStatus")
+
+// Relay is a manager.Runnable that streams the objects of the upstreams to the next emitter.
+type Relay struct {
+	upstreams []*upstream
+}
+
+var _ manager.Runnable = (*Relay)(nil)
+
+func New(clusters []cluster.Cluster, next controller.Emitter) (*Relay, error) {
+	r := &Relay{}
+	for _, c := range clusters {
+		cl, err := rest.HTTPClientFor(c.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a client for upstream %q: %w", c.ID, err)
+		}
+		r.upstreams = append(r.upstreams, &upstream{
+			name:    c.ID,
+			url:     c.Config.Host,
+			cl:      cl,
+			next:    next,
+			objects: make(map[string]*controller.Event),
+		})
+	}
+	return r, nil
+}
+
+// Start implements manager.Runnable.
+func (r *Relay) Start(ctx context.Context) error {
+	log.Info().Int("upstreams", len(r.upstreams)).Msg("starting relay")
+
+	var wg sync.WaitGroup
+	for _, u := range r.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.run(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+type upstream struct {
+	name string
+	url  string
+	cl   *http.Client
+	next controller.Emitter
+
+	// the last create events of the objects relayed from the upstream, keyed by controller.Event.Key
+	objects map[string]*controller.Event
+	status  types.UpstreamStatusSpec
+}
+
+// wireEvent is a controller.Event as encoded in the stream.
+type wireEvent struct {
+	Type    controller.EventType `json:"type"`
+	Object  json.RawMessage      `json:"object"`
+	Cluster string               `json:"cluster,omitempty"`
+}
+
+// run keeps a connection to the upstream until the context is done.
+func (u *upstream) run(ctx context.Context) {
+	u.status.URL = u.url
+	u.emitStatus()
+
+	backoff := minBackoff
+	for {
+		connected, err := u.stream(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = minBackoff
+		}
+		u.status.Connected = false
+		u.status.LastError = err.Error()
+		u.emitStatus()
+		log.Warn().Err(err).
+			Str("upstream", u.name).
+			Dur("backoff", backoff).
+			Msg("disconnected from upstream")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// stream relays the events of a single connection. It reports whether the connection was established.
+func (u *upstream) stream(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url+"/kuview", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := u.cl.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if u.status.LastConnected != nil {
+		u.status.Reconnects++
+	}
+	now := metav1.Now()
+	u.status.Connected = true
+	u.status.LastConnected = &now
+	u.emitStatus()
+	log.Info().Str("upstream", u.name).Msg("connected to upstream")
+
+	// The upstream sends a snapshot of its objects first.
+	// Objects relayed before but missing from it were deleted while disconnected.
+	stale := make(map[string]struct{}, len(u.objects))
+	for key := range u.objects {
+		stale[key] = struct{}{}
+	}
+
+	var idle atomic.Bool
+	body := newIdleReader(resp.Body, idleTimeout, func() {
+		idle.Store(true)
+		cancel()
+	})
+	defer body.Stop()
+
+	// events carries the objects, and nil at the end of the snapshot
+	events := make(chan *controller.Event)
+	var readErr error
+	go func() {
+		defer close(events)
+		readErr = readEvents(body, func(name string, data []byte) error {
+			var evt *controller.Event
+			switch name {
+			case "":
+				var err error
+				evt, err = u.decode(data)
+				if err != nil {
+					log.Error().Err(err).Str("upstream", u.name).Msg("failed to decode event")
+					return nil
+				}
+			case server.EventSnapshotEnd:
+			default:
+				// other control events are not relayed, the relay sends its own
+				return nil
+			}
+			select {
+			case events <- evt:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	objects := len(u.objects)
+	sweep := func() {
+		for key := range stale {
+			v := u.objects[key]
+			delete(u.objects, key)
+			u.next.Emit(&controller.Event{
+				Type:    controller.EventTypeDelete,
+				Object:  v.Object,
+				Cluster: v.Cluster,
+			})
+		}
+		if len(stale) > 0 {
+			log.Info().Str("upstream", u.name).Int("deleted", len(stale)).Msg("deleted objects missing from the snapshot")
+		}
+		stale = nil
+		objects = len(u.objects)
+		u.emitStatus()
+	}
+
+	settle := time.NewTimer(settleTimeout)
+	defer settle.Stop()
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if idle.Load() {
+				return true, fmt.Errorf("no data for %s", idleTimeout)
+			}
+			return true, ctx.Err()
+		case evt, ok := <-events:
+			if !ok {
+				if idle.Load() {
+					return true, fmt.Errorf("no data for %s", idleTimeout)
+				}
+				if errors.Is(readErr, context.Canceled) {
+					return true, readErr
+				}
+				return true, fmt.Errorf("stream closed: %w", readErr)
+			}
+			if evt == nil {
+				if stale != nil {
+					sweep()
+				}
+				continue
+			}
+			key := evt.Key()
+			switch evt.Type {
+			case controller.EventTypeCreate:
+				u.objects[key] = evt
+			case controller.EventTypeDelete:
+				delete(u.objects, key)
+			}
+			u.next.Emit(evt)
+
+			if stale != nil {
+				delete(stale, key)
+				settle.Reset(settleTimeout)
+			}
+		case <-settle.C:
+			if stale != nil {
+				sweep()
+			}
+		case <-ticker.C:
+			if stale == nil && objects != len(u.objects) {
+				objects = len(u.objects)
+				u.emitStatus()
+			}
+		}
+	}
+}
+
+// decode parses an event of the upstream and tags it with the upstream.
+// Objects of an upstream watching several clusters are tagged with "upstream~cluster".
+func (u *upstream) decode(data []byte) (*controller.Event, error) {
+	w := wireEvent{}
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(w.Object); err != nil {
+		return nil, err
+	}
+	evt := &controller.Event{
+		Type:    w.Type,
+		Object:  obj,
+		Cluster: u.name,
+	}
+	if w.Cluster != "" {
+		evt.Cluster = cluster.Nested(u.name, w.Cluster)
+	}
+	return evt, nil
+}
+
+func (u *upstream) emitStatus() {
+	u.status.Objects = len(u.objects)
+	u.next.Emit(&controller.Event{
+		Type:    controller.EventTypeCreate,
+		Cluster: u.name,
+		Object: &types.UpstreamStatus{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: statusGVK.GroupVersion().String(),
+				Kind:       statusGVK.Kind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: u.name,
+			},
+			Spec: u.status,
+		},
+	})
+}
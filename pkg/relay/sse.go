@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"time"
+)
+
+// maxLineSize bounds a line of the stream, which holds a whole object.
+const maxLineSize = 64 << 20
+
+// idleReader calls onIdle if nothing is read for the timeout,
+// e.g. when the upstream is gone without closing the connection.
+type idleReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func newIdleReader(r io.Reader, timeout time.Duration, onIdle func()) *idleReader {
+	return &idleReader{r: r, timeout: timeout, timer: time.AfterFunc(timeout, onIdle)}
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// Stop stops watching the reader.
+func (r *idleReader) Stop() {
+	r.timer.Stop()
+}
+
+// readEvents parses a text/event-stream and calls fn with the name and the data of every event.
+// The name is empty for unnamed events. It returns io.EOF when the stream ends.
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+func readEvents(r io.Reader, fn func(event string, data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+
+	var event string
+	var data []byte
+	hasData := false
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			// a blank line dispatches the event
+			if hasData {
+				if err := fn(event, data); err != nil {
+					return err
+				}
+			}
+			event, data, hasData = "", data[:0], false
+			continue
+		}
+		if line[0] == ':' {
+			// comment
+			continue
+		}
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			event = string(value)
+		case "data":
+			if hasData {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+			hasData = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/audit"
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/labstack/echo/v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ObjectList is the response of the REST APIs listing cached objects.
+type ObjectList struct {
+	Items []client.Object `json:"items"`
+	// Clusters are the IDs of the clusters of the items, in the same order.
+	// It is omitted when a single cluster is watched.
+	Clusters []string `json:"clusters,omitempty"`
+}
+
+// ClusterList is the response of GET /kuview/api/clusters.
+type ClusterList struct {
+	// Items are the IDs of the watched clusters. The first one is the default of the log proxy.
+	// The ID is empty when a single cluster is watched.
+	Items []string `json:"items"`
+}
+
+func (s *Server) listClusters(c echo.Context) error {
+	return c.JSON(http.StatusOK, ClusterList{Items: s.clusters})
+}
+
+// matchesQuery reports whether the name of the object contains q, or the reference of a container image,
+// so images are found by tag, e.g. q=log4j-app:1.2. Relayed images are unstructured.
+func matchesQuery(obj client.Object, q string) bool {
+	if strings.Contains(obj.GetName(), q) {
+		return true
+	}
+	switch obj := obj.(type) {
+	case *types.ContainerImage:
+		return strings.Contains(obj.Spec.Reference, q)
+	case *unstructured.Unstructured:
+		if obj.GroupVersionKind() != types.KuviewGroupVersion.WithKind("ContainerImage") {
+			return false
+		}
+		ref, _, _ := unstructured.NestedString(obj.Object, "spec", "reference")
+		return strings.Contains(ref, q)
+	}
+	return false
+}
+
+// listObjects returns the cached objects of a kind, e.g.
+// GET /kuview/api/objects/kuview.iwanhae.kr/v1/PodSecuritySummary
+// GET /kuview/api/objects/v1/Pod?namespace=default&labelSelector=app=web
+// GET /kuview/api/objects/kuview.iwanhae.kr/v1/ContainerImage?q=log4j-app:1.2&cluster=prod
+func (s *Server) listObjects(c echo.Context) error {
+	gvk, err := types.ParseGVK(c.Param("*"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	cluster := c.QueryParam("cluster")
+	namespace := c.QueryParam("namespace")
+	name := c.QueryParam("name")
+	// q matches a substring of the name, or of the reference of a container image
+	q := c.QueryParam("q")
+	selector, err := labels.Parse(c.QueryParam("labelSelector"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	events := []*controller.Event{}
+	s.rwmu.RLock()
+	for _, v := range s.cache {
+		obj := v.Object
+		if obj.GetObjectKind().GroupVersionKind() != gvk {
+			continue
+		}
+		if cluster != "" && v.Cluster != cluster {
+			continue
+		}
+		if namespace != "" && obj.GetNamespace() != namespace {
+			continue
+		}
+		if name != "" && obj.GetName() != name {
+			continue
+		}
+		if q != "" && !matchesQuery(obj, q) {
+			continue
+		}
+		if !selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		events = append(events, v)
+	}
+	s.rwmu.RUnlock()
+	events = s.viewerOf(c).filter(c.Request().Context(), events)
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key() < events[j].Key()
+	})
+	evt := auditEvent(c, audit.ActionList, audit.StageCompleted)
+	evt.Filters = auditFilters(c.QueryParams())
+	evt.Cluster, evt.Kind, evt.Namespace = cluster, types.FormatGVK(gvk), namespace
+	evt.ObjectCount, evt.Objects = len(events), auditedObjects(events)
+	s.audit(evt)
+
+	res := ObjectList{Items: make([]client.Object, 0, len(events))}
+	for _, v := range events {
+		res.Items = append(res.Items, v.Object)
+		if len(s.clusters) > 1 {
+			res.Clusters = append(res.Clusters, v.Cluster)
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
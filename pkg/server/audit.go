@@ -0,0 +1,70 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/audit"
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/server/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// auditEvent returns an event of the request, telling who did it.
+func auditEvent(c echo.Context, action audit.Action, stage audit.Stage) *audit.Event {
+	evt := &audit.Event{
+		Time:      time.Now(),
+		RequestID: middleware.GetRequestID(c.Request().Context()),
+		Stage:     stage,
+		Action:    action,
+		// taken from X-Forwarded-For only if sent by a trusted proxy
+		SourceIP: c.RealIP(),
+	}
+	if u := middleware.UserOf(c); u != nil {
+		evt.User, evt.Groups, evt.AuthMethod = u.Name, u.Groups, u.Method
+		if u.Share != nil {
+			evt.ShareID = u.Share.ID
+		}
+	}
+	return evt
+}
+
+// completed returns the Completed event of a stream started with evt.
+func completed(evt *audit.Event, err error) *audit.Event {
+	done := *evt
+	done.Time = time.Now()
+	done.Stage = audit.StageCompleted
+	done.DurationMs = done.Time.Sub(evt.Time).Milliseconds()
+	if err != nil {
+		done.Error = err.Error()
+	}
+	return &done
+}
+
+// audit records the event, if auditing is enabled.
+func (s *Server) audit(evt *audit.Event) {
+	s.opts.Load().Audit.Log(evt)
+}
+
+// auditedObjects returns the keys of the first events, as many as an audit event records.
+func auditedObjects(events []*controller.Event) []string {
+	keys := make([]string, 0, min(len(events), audit.MaxObjects))
+	for _, v := range events[:min(len(events), audit.MaxObjects)] {
+		keys = append(keys, v.Key())
+	}
+	return keys
+}
+
+// auditFilters returns the non-empty parameters, lists joined by commas.
+func auditFilters(params map[string][]string) map[string]string {
+	filters := map[string]string{}
+	for k, v := range params {
+		if joined := strings.Join(v, ","); joined != "" {
+			filters[k] = joined
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return filters
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/iwanhae/kuview/pkg/server/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// anonymous is the user of the requests while authentication is disabled.
+var anonymous = middleware.User{Name: "anonymous", Method: "none"}
+
+// whoami returns the authenticated user, e.g. for the UI to tell who is logged in.
+func (s *Server) whoami(c echo.Context) error {
+	if u := middleware.UserOf(c); u != nil {
+		return c.JSON(http.StatusOK, u)
+	}
+	return c.JSON(http.StatusOK, anonymous)
+}
+
+// checkWebSocketOrigin rejects cross-site WebSocket handshakes authenticated with the session or share cookie,
+// which browsers send without asking the server like they do for cross-origin fetches.
+// Other origins have to be listed explicitly in AllowOrigins, a wildcard does not allow them.
+func (s *Server) checkWebSocketOrigin(c echo.Context) error {
+	u := middleware.UserOf(c)
+	origin := c.Request().Header.Get(echo.HeaderOrigin)
+	if u == nil || (u.Method != "oidc" && u.Method != "share") || origin == "" {
+		return nil
+	}
+	if parsed, err := url.Parse(origin); err == nil && parsed.Host == c.Request().Host {
+		return nil
+	}
+	if slices.Contains(s.opts.Load().AllowOrigins, origin) {
+		return nil
+	}
+	return echo.NewHTTPError(http.StatusForbidden, "cross-origin WebSocket handshake from "+origin)
+}
@@ -0,0 +1,416 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/server/middleware"
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// accessReviewTTL is how long the decision of a SubjectAccessReview is reused.
+	accessReviewTTL = time.Minute
+	// accessReviewCacheSize bounds the decisions kept, expired ones are dropped beyond it.
+	accessReviewCacheSize = 16384
+	// accessReviewParallelism bounds the reviews requested at once for a snapshot.
+	accessReviewParallelism = 8
+	// accessReviewRefresh is how long before it expires a decision used by the live events is reviewed again.
+	accessReviewRefresh = 15 * time.Second
+	// accessReviewTimeout bounds the reviews requested in the background.
+	accessReviewTimeout = 10 * time.Second
+)
+
+// AuthorizationOptions show every user only the objects they may list in the cluster themselves.
+type AuthorizationOptions struct {
+	// ExemptUsers and ExemptGroups see every object kuview sees, e.g. the user of a relay.
+	ExemptUsers  []string
+	ExemptGroups []string
+}
+
+// authorizer decides what the users may see with SubjectAccessReviews of the clusters.
+// The service account of kuview needs the system:auth-delegator cluster role,
+// and the permission to impersonate the users and groups for their logs.
+type authorizer struct {
+	opts AuthorizationOptions
+	// clients are keyed by the cluster ID, like the upstreams
+	clients map[string]kubernetes.Interface
+
+	mu        sync.Mutex
+	decisions map[accessKey]accessDecision
+	// reviewing are the accesses reviewed in the background
+	reviewing map[accessKey]struct{}
+}
+
+// access is what a user needs to see an object: to list the resource in the namespace, or cluster-wide if empty.
+type access struct {
+	group     string
+	resource  string
+	namespace string
+}
+
+type accessKey struct {
+	cluster string
+	user    string
+	// groups are joined by newlines
+	groups string
+	access
+}
+
+type accessDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+func newAuthorizer(opts AuthorizationOptions, upstreams map[string]*upstream) (*authorizer, error) {
+	clients := make(map[string]kubernetes.Interface, len(upstreams))
+	for id, up := range upstreams {
+		client, err := kubernetes.NewForConfig(up.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a client for access reviews of cluster %q: %w", id, err)
+		}
+		clients[id] = client
+	}
+	return &authorizer{
+		opts:      opts,
+		clients:   clients,
+		decisions: make(map[accessKey]accessDecision),
+		reviewing: make(map[accessKey]struct{}),
+	}, nil
+}
+
+// viewer is a user whose view is restricted, to what they may list or to the scope of a share link.
+// A nil viewer sees everything.
+type viewer struct {
+	// authz reviews the access of the user, nil if authorization is disabled or the user is exempt
+	authz *authorizer
+	// user is the creator of the share link, if any
+	user *middleware.User
+	// share restricts the view to the scope of the link, if set
+	share *middleware.ShareScope
+	links *middleware.ShareLinks
+}
+
+// viewerOf returns the viewer of the request, nil if nothing restricts what the user sees.
+func (s *Server) viewerOf(c echo.Context) *viewer {
+	u := middleware.UserOf(c)
+	if u == nil {
+		return nil
+	}
+	v := &viewer{user: u}
+	if u.Share != nil {
+		v.share = u.Share
+		v.links = s.opts.Load().ShareLinks
+		v.user = &u.Share.CreatedBy
+	}
+	if s.authz != nil && !s.authz.exempt(v.user) {
+		v.authz = s.authz
+	}
+	if v.authz == nil && v.share == nil {
+		return nil
+	}
+	return v
+}
+
+// exempt reports whether the user sees everything regardless of their access.
+func (a *authorizer) exempt(u *middleware.User) bool {
+	if slices.Contains(a.opts.ExemptUsers, u.Name) {
+		return true
+	}
+	for _, g := range u.Groups {
+		if slices.Contains(a.opts.ExemptGroups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessOf returns the access needed to see the object of the event.
+// Public objects, like the sync statuses, are seen by everyone. The objects kuview synthesizes
+// are seen by those who may list the objects they are derived from, and unknown ones by no one.
+func accessOf(v *controller.Event) (a access, public bool, known bool) {
+	obj := v.Object
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	switch gvk.Group {
+	case types.KuviewGroupVersion.Group:
+		switch gvk.Kind {
+		case "SyncStatus", "UpstreamStatus":
+			return access{}, true, true
+		case "Finding":
+			ref, ok := findingSubject(obj)
+			if !ok {
+				return access{}, false, false
+			}
+			gv, err := schema.ParseGroupVersion(ref.APIVersion)
+			if err != nil {
+				return access{}, false, false
+			}
+			return accessTo(gv.WithKind(ref.Kind), ref.Namespace), false, true
+		case "PodSecurityViolation":
+			return access{resource: "pods", namespace: obj.GetNamespace()}, false, true
+		case "PodSecuritySummary":
+			// named after the namespace
+			return access{resource: "pods", namespace: obj.GetName()}, false, true
+		case "ContainerImage":
+			// the usages span every namespace
+			return access{resource: "pods"}, false, true
+		case "RBACSubjectRisk":
+			return access{group: "rbac.authorization.k8s.io", resource: "clusterrolebindings"}, false, true
+		}
+		return access{}, false, false
+	case "metrics.k8s.io":
+		switch gvk.Kind {
+		case "NodeMetrics":
+			return access{group: gvk.Group, resource: "nodes"}, false, true
+		case "PodMetrics":
+			return access{group: gvk.Group, resource: "pods", namespace: obj.GetNamespace()}, false, true
+		}
+	}
+	return accessTo(gvk, obj.GetNamespace()), false, true
+}
+
+// findingSubject returns the object a finding is about. Findings are unstructured when relayed.
+func findingSubject(obj client.Object) (types.ObjectReference, bool) {
+	var ref types.ObjectReference
+	switch f := obj.(type) {
+	case *types.Finding:
+		ref = f.Spec.Object
+	case *unstructured.Unstructured:
+		if f.GroupVersionKind() != types.KuviewGroupVersion.WithKind("Finding") {
+			break
+		}
+		ref.APIVersion, _, _ = unstructured.NestedString(f.Object, "spec", "object", "apiVersion")
+		ref.Kind, _, _ = unstructured.NestedString(f.Object, "spec", "object", "kind")
+		ref.Namespace, _, _ = unstructured.NestedString(f.Object, "spec", "object", "namespace")
+		ref.Name, _, _ = unstructured.NestedString(f.Object, "spec", "object", "name")
+	}
+	return ref, ref.Kind != ""
+}
+
+func accessTo(gvk schema.GroupVersionKind, namespace string) access {
+	plural, _ := meta.UnsafeGuessKindToResource(gvk)
+	return access{group: gvk.Group, resource: plural.Resource, namespace: namespace}
+}
+
+// allowed reports whether the viewer may see the object of the event, asking the cluster unless decided recently.
+// Namespaced objects are allowed if the resource may be listed cluster-wide or in the namespace.
+func (v *viewer) allowed(ctx context.Context, evt *controller.Event) bool {
+	return v.decide(evt, func(cluster string, a access) bool { return v.review(ctx, cluster, a) })
+}
+
+// allowedNow is allowed without waiting for the cluster, for the live events not to stall behind the reviews.
+// The accesses not decided yet are reviewed in the background, and denied meanwhile.
+func (v *viewer) allowedNow(ctx context.Context, evt *controller.Event) bool {
+	return v.decide(evt, func(cluster string, a access) bool { return v.decided(ctx, cluster, a) })
+}
+
+func (v *viewer) decide(evt *controller.Event, review func(cluster string, a access) bool) bool {
+	if v == nil {
+		return true
+	}
+	a, public, known := accessOf(evt)
+	if public {
+		return true
+	}
+	if v.share != nil && !v.inScope(evt) {
+		return false
+	}
+	if v.authz == nil {
+		return true
+	}
+	if !known {
+		return false
+	}
+	clusterWide := a
+	clusterWide.namespace = ""
+	if review(evt.Cluster, clusterWide) {
+		return true
+	}
+	return a.namespace != "" && review(evt.Cluster, a)
+}
+
+// filter returns the events the viewer may see, reviewing the access to the kinds and namespaces in parallel first.
+func (v *viewer) filter(ctx context.Context, events []*controller.Event) []*controller.Event {
+	if v == nil {
+		return events
+	}
+	if v.authz == nil {
+		return slices.DeleteFunc(events, func(evt *controller.Event) bool { return !v.allowed(ctx, evt) })
+	}
+	type clusterAccess struct {
+		cluster string
+		access
+	}
+	pending := map[clusterAccess]struct{}{}
+	for _, evt := range events {
+		if v.share != nil && !v.inScope(evt) {
+			continue
+		}
+		if a, public, known := accessOf(evt); !public && known {
+			pending[clusterAccess{evt.Cluster, a}] = struct{}{}
+		}
+	}
+	g := errgroup.Group{}
+	g.SetLimit(accessReviewParallelism)
+	for ca := range pending {
+		g.Go(func() error {
+			// allowed asks for the cluster-wide access first
+			clusterWide := ca.access
+			clusterWide.namespace = ""
+			if !v.review(ctx, ca.cluster, clusterWide) && ca.namespace != "" {
+				v.review(ctx, ca.cluster, ca.access)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	allowed := make([]*controller.Event, 0, len(events))
+	for _, evt := range events {
+		if v.allowed(ctx, evt) {
+			allowed = append(allowed, evt)
+		}
+	}
+	return allowed
+}
+
+// review reports whether the viewer may list the resource, asking the cluster unless decided recently.
+// Access is denied if it can not be reviewed.
+func (v *viewer) review(ctx context.Context, cluster string, a access) bool {
+	key := v.keyOf(cluster, a)
+	v.authz.mu.Lock()
+	cached, ok := v.authz.decisions[key]
+	v.authz.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.allowed
+	}
+	return v.reviewNow(ctx, key)
+}
+
+// decided reports whether the viewer may list the resource as decided before, without asking the cluster.
+// A decision about to expire is reviewed again in the background, and used until replaced,
+// at most for another accessReviewTTL. An access not decided yet is reviewed in the background and denied.
+func (v *viewer) decided(ctx context.Context, cluster string, a access) bool {
+	key := v.keyOf(cluster, a)
+	now := time.Now()
+	v.authz.mu.Lock()
+	defer v.authz.mu.Unlock()
+	cached, ok := v.authz.decisions[key]
+	if ok && now.Before(cached.expires.Add(-accessReviewRefresh)) {
+		return cached.allowed
+	}
+	if _, reviewing := v.authz.reviewing[key]; !reviewing {
+		v.authz.reviewing[key] = struct{}{}
+		// the review outlives the event, but not the stream for long
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), accessReviewTimeout)
+		go func() {
+			defer cancel()
+			v.reviewNow(ctx, key)
+			v.authz.mu.Lock()
+			delete(v.authz.reviewing, key)
+			v.authz.mu.Unlock()
+		}()
+	}
+	return ok && now.Before(cached.expires.Add(accessReviewTTL)) && cached.allowed
+}
+
+func (v *viewer) keyOf(cluster string, a access) accessKey {
+	return accessKey{cluster: cluster, user: v.user.Name, groups: strings.Join(v.user.Groups, "\n"), access: a}
+}
+
+// reviewNow asks the cluster whether the viewer may list the resource, and caches the decision.
+func (v *viewer) reviewNow(ctx context.Context, key accessKey) bool {
+	allowed, err := v.subjectAccessReview(ctx, key.cluster, key.access)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("user", v.user.Name).Str("resource", key.resource).Msg("failed to review access, denying it")
+		if ctx.Err() != nil {
+			// not cached, the request was only canceled
+			return false
+		}
+	}
+
+	now := time.Now()
+	v.authz.mu.Lock()
+	defer v.authz.mu.Unlock()
+	if len(v.authz.decisions) >= accessReviewCacheSize {
+		for k, d := range v.authz.decisions {
+			// the expired decisions still used by the live events are kept
+			if now.After(d.expires.Add(accessReviewTTL)) {
+				delete(v.authz.decisions, k)
+			}
+		}
+	}
+	if _, ok := v.authz.decisions[key]; ok || len(v.authz.decisions) < accessReviewCacheSize {
+		v.authz.decisions[key] = accessDecision{allowed: allowed, expires: now.Add(accessReviewTTL)}
+	}
+	return allowed
+}
+
+func (v *viewer) subjectAccessReview(ctx context.Context, id string, a access) (bool, error) {
+	client, ok := v.authz.clients[id]
+	if !ok {
+		// a cluster of an upstream kuview server, as in "prod~inner"
+		parent, _, _ := cluster.SplitNested(id)
+		if client, ok = v.authz.clients[parent]; !ok {
+			return false, fmt.Errorf("unknown cluster %q", id)
+		}
+	}
+	review, err := client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   v.user.Name,
+			Groups: v.user.Groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: a.namespace,
+				Verb:      "list",
+				Group:     a.group,
+				Resource:  a.resource,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to review access to %s: %w", a.resource, err)
+	}
+	return review.Status.Allowed, nil
+}
+
+// impersonate returns a transport sending the requests as the viewer,
+// or the transport itself if the access of the viewer is not reviewed.
+func (v *viewer) impersonate(rt http.RoundTripper) http.RoundTripper {
+	if v == nil || v.authz == nil {
+		return rt
+	}
+	return transport.NewImpersonatingRoundTripper(transport.ImpersonationConfig{
+		UserName: v.user.Name,
+		Groups:   v.user.Groups,
+	}, rt)
+}
+
+// stripCredentials removes the credentials of the client from a request forwarded to a cluster,
+// so the request is sent with those of kuview, and the client can not impersonate anyone itself.
+func stripCredentials(h http.Header) {
+	h.Del(echo.HeaderAuthorization)
+	h.Del("Cookie")
+	for name := range h {
+		if strings.HasPrefix(name, "Impersonate-") {
+			h.Del(name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TestAccessOfRelayedFinding checks that a finding needs the same access whether it is typed or relayed unstructured.
+func TestAccessOfRelayedFinding(t *testing.T) {
+	finding := &types.Finding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: types.KuviewGroupVersion.String(), Kind: "Finding"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "crash-loop-back-off.pod.web-0"},
+		Spec: types.FindingSpec{
+			Rule:   "crash-loop-back-off",
+			Object: types.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "shop", Name: "web"},
+		},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(finding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayed := &unstructured.Unstructured{Object: content}
+
+	want := access{group: "apps", resource: "deployments", namespace: "shop"}
+	for _, evt := range []*controller.Event{{Object: finding}, {Object: relayed}} {
+		a, public, known := accessOf(evt)
+		if public || !known || a != want {
+			t.Errorf("accessOf(%T) = %+v, %v, %v, want %+v", evt.Object, a, public, known, want)
+		}
+	}
+}
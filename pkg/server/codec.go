@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/runtime/serializer/cbor/direct"
+)
+
+// ContentTypeCBORStream is the media type of the binary stream of /kuview.
+// Every frame is a CBOR data item prefixed with its length as a big-endian uint32.
+// Object events are encoded like their JSON, and control events as {"event": name, "data": data}.
+const ContentTypeCBORStream = "application/vnd.kuview.cbor-stream"
+
+// codec writes the events of a subscription in the encoding negotiated with the client.
+type codec interface {
+	contentType() string
+	// encode returns the encoded object event, empty if it can not be encoded.
+	encode(f *frame) []byte
+	// writeEvents writes encoded object events.
+	writeEvents(w io.Writer, events [][]byte) error
+	writeControl(w io.Writer, c *control) error
+	writeHeartbeat(w io.Writer, hb Heartbeat) error
+	// writeRetry hints the client how long to wait before reconnecting, if the encoding supports it.
+	writeRetry(w io.Writer, retry time.Duration) error
+}
+
+// negotiateCodec selects the codec of the Accept header, server-sent events by default.
+func negotiateCodec(accept string, batch int) codec {
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err == nil && mediaType == ContentTypeCBORStream {
+			return cborCodec{}
+		}
+	}
+	return sseCodec{batch: batch}
+}
+
+// sseCodec writes server-sent events with JSON data, in batch messages of at most batch events if positive.
+type sseCodec struct {
+	batch int
+}
+
+func (sseCodec) contentType() string { return "text/event-stream" }
+
+func (sseCodec) encode(f *frame) []byte { return f.JSON() }
+
+func (c sseCodec) writeEvents(w io.Writer, events [][]byte) error {
+	return writeEvents(w, events, c.batch)
+}
+
+func (sseCodec) writeControl(w io.Writer, c *control) error {
+	b, err := json.Marshal(c.data)
+	if err != nil {
+		// a control event without data is not written
+		return nil
+	}
+	evt := Event{Event: []byte(c.name), Data: b}
+	return evt.MarshalTo(w)
+}
+
+// writeHeartbeat writes the heartbeat as a comment, e.g. `: heartbeat {"lagMs":3,"queued":0}`.
+func (sseCodec) writeHeartbeat(w io.Writer, hb Heartbeat) error {
+	b, err := json.Marshal(hb)
+	if err != nil {
+		return fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+	evt := Event{Comment: append([]byte(EventHeartbeat+" "), b...)}
+	return evt.MarshalTo(w)
+}
+
+func (sseCodec) writeRetry(w io.Writer, retry time.Duration) error {
+	evt := Event{Retry: []byte(strconv.FormatInt(retry.Milliseconds(), 10))}
+	return evt.MarshalTo(w)
+}
+
+// cborCodec writes length-prefixed CBOR frames.
+type cborCodec struct{}
+
+func (cborCodec) contentType() string { return ContentTypeCBORStream }
+
+func (cborCodec) encode(f *frame) []byte { return f.CBOR() }
+
+func (cborCodec) writeEvents(w io.Writer, events [][]byte) error {
+	for _, data := range events {
+		if err := writeCBORFrame(w, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cborCodec) writeControl(w io.Writer, c *control) error {
+	b, err := direct.Marshal(map[string]any{"event": c.name, "data": c.data})
+	if err != nil {
+		return nil
+	}
+	return writeCBORFrame(w, b)
+}
+
+func (c cborCodec) writeHeartbeat(w io.Writer, hb Heartbeat) error {
+	return c.writeControl(w, controlEvent(EventHeartbeat, hb))
+}
+
+func (cborCodec) writeRetry(io.Writer, time.Duration) error { return nil }
+
+func writeCBORFrame(w io.Writer, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// eventAsCBOR encodes the event like its JSON.
+// Strings are encoded as byte strings, and byte slices as byte strings tagged to be base64 encoded.
+func eventAsCBOR(v *controller.Event) []byte {
+	b, err := direct.Marshal(v)
+	if err == nil {
+		return b
+	}
+	// Objects with JSON marshalers of their own, e.g. unstructured ones, are transcoded from their JSON.
+	var obj any
+	if err := json.Unmarshal(eventAsJSON(v), &obj); err != nil {
+		return nil
+	}
+	b, err = direct.Marshal(obj)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode event as CBOR")
+		return nil
+	}
+	return b
+}
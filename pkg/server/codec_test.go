@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// benchmarkPods is the number of pods of the snapshot encoded by BenchmarkEncodeSnapshot.
+const benchmarkPods = 1000
+
+// benchmarkPod returns a pod of one container, as created by a deployment and scheduled.
+func benchmarkPod(i int) *v1.Pod {
+	name := fmt.Sprintf("web-7c9f8d6b5-%05d", i)
+	created := metav1.Unix(1700000000, 0)
+	return &v1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               "1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+			ResourceVersion:   "123456",
+			CreationTimestamp: created,
+			Labels:            map[string]string{"app": "web", "pod-template-hash": "7c9f8d6b5"},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-7c9f8d6b5",
+				UID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8", Controller: ptr.To(true), BlockOwnerDeletion: ptr.To(true),
+			}},
+		},
+		Spec: v1.PodSpec{
+			NodeName: "node-1",
+			Containers: []v1.Container{{
+				Name:  "web",
+				Image: "nginx:1.27",
+				Ports: []v1.ContainerPort{{ContainerPort: 80, Protocol: v1.ProtocolTCP}},
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse("128Mi")},
+					Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("256Mi")},
+				},
+				TerminationMessagePath:   "/dev/termination-log",
+				TerminationMessagePolicy: v1.TerminationMessageReadFile,
+				ImagePullPolicy:          v1.PullIfNotPresent,
+			}},
+			RestartPolicy:      v1.RestartPolicyAlways,
+			DNSPolicy:          v1.DNSClusterFirst,
+			ServiceAccountName: "default",
+			SchedulerName:      "default-scheduler",
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodReady, Status: v1.ConditionTrue, LastTransitionTime: created},
+				{Type: v1.PodScheduled, Status: v1.ConditionTrue, LastTransitionTime: created},
+			},
+			HostIP:    "10.0.0.1",
+			PodIP:     fmt.Sprintf("10.1.%d.%d", i/256%256, i%256),
+			StartTime: &created,
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:    "web",
+				Ready:   true,
+				Image:   "docker.io/library/nginx:1.27",
+				ImageID: "docker.io/library/nginx@sha256:0f04e4f646a3f14bf31d8bc8d885b6c951fdcf42589d06845f64d18aec6a3c4d",
+				State:   v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: created}},
+			}},
+		},
+	}
+}
+
+// BenchmarkEncodeSnapshot encodes a snapshot of pods as SSE with JSON and as CBOR frames,
+// reporting the size of the stream per pod, raw and gzipped. They are the figures of the README.
+func BenchmarkEncodeSnapshot(b *testing.B) {
+	events := make([]*controller.Event, benchmarkPods)
+	for i := range events {
+		events[i] = &controller.Event{Type: controller.EventTypeCreate, Object: benchmarkPod(i)}
+	}
+
+	for _, bc := range []struct {
+		name   string
+		encode func(*controller.Event) []byte
+		codec  codec
+	}{
+		{"json", eventAsJSON, sseCodec{}},
+		{"cbor", eventAsCBOR, cborCodec{}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			buf := &bytes.Buffer{}
+			for b.Loop() {
+				buf.Reset()
+				encoded := make([][]byte, len(events))
+				for i, v := range events {
+					encoded[i] = bc.encode(v)
+				}
+				if err := bc.codec.writeEvents(buf, encoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			gzipped := &bytes.Buffer{}
+			gw := gzip.NewWriter(gzipped)
+			if _, err := gw.Write(buf.Bytes()); err != nil {
+				b.Fatal(err)
+			}
+			if err := gw.Close(); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(buf.Len())/benchmarkPods, "B/pod")
+			b.ReportMetric(float64(gzipped.Len())/benchmarkPods, "gzip-B/pod")
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/benchmarkPods, "ns/pod")
+		})
+	}
+}
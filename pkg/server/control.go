@@ -0,0 +1,143 @@
+package server
+
+import (
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Names of the control events of the stream. Objects are sent as unnamed events.
+const (
+	// EventSnapshotBegin precedes the snapshot of the cache sent to a new subscriber. Its data is SnapshotBegin.
+	EventSnapshotBegin = "snapshot-begin"
+	// EventSnapshotEnd follows the last object of the snapshot. Its data is SnapshotEnd.
+	EventSnapshotEnd = "snapshot-end"
+	// EventSynced tells that the initial list of a kind has been cached. Its data is Synced.
+	// It is sent after the snapshot for the kinds synced by then, and as soon as the others sync.
+	EventSynced = "synced"
+	// EventBatch packs several object events into one message when subscribed with the batch parameter.
+	// Its data is a JSON array of the events.
+	EventBatch = "batch"
+	// EventHeartbeat is sent every HeartbeatInterval to keep idle connections open. Its data is Heartbeat.
+	// It is a comment of the SSE stream, so EventSource clients ignore it.
+	EventHeartbeat = "heartbeat"
+	// EventReconnect tells the client to reconnect, after the retry interval, as the server is shutting down.
+	// The stream ends after it. Its data is Reconnect.
+	EventReconnect = "reconnect"
+)
+
+const (
+	// HeartbeatInterval is shorter than the idle timeout of common load balancers and ingress controllers, 60s.
+	HeartbeatInterval = 15 * time.Second
+	// RetryInterval is the reconnection delay hinted to EventSource clients.
+	RetryInterval = 3 * time.Second
+	// subscriberBuffer is the number of events a subscriber may fall behind before it is disconnected.
+	// It absorbs bursts like the snapshot of a reconnected relay upstream.
+	subscriberBuffer = 16384
+	// writeTimeout bounds a write to a subscriber, to detect peers gone without closing the connection.
+	writeTimeout = 30 * time.Second
+)
+
+type SnapshotBegin struct {
+	// Total is the number of objects in the snapshot.
+	Total int `json:"total"`
+	// Kinds counts the objects of the snapshot per kind in the "apiVersion/kind" notation. e.g. "v1/Pod"
+	Kinds map[string]int `json:"kinds"`
+}
+
+type SnapshotEnd struct {
+	Total int `json:"total"`
+}
+
+type Heartbeat struct {
+	// LagMs is the longest delay in milliseconds between the distribution of an event and its write to the client
+	// since the last heartbeat.
+	LagMs int64 `json:"lagMs"`
+	// Queued is the number of events waiting to be written to the client.
+	Queued int `json:"queued"`
+}
+
+type Reconnect struct {
+	Reason string `json:"reason"`
+}
+
+type Synced struct {
+	Cluster string `json:"cluster,omitempty"`
+	// GVK is the kind in the "apiVersion/kind" notation. e.g. "v1/Pod"
+	GVK string `json:"gvk"`
+	// Objects is the number of cached objects of the kind when it synced.
+	Objects int `json:"objects"`
+}
+
+// control is a control event, encoded by the codec of the subscription.
+type control struct {
+	name string
+	data any
+}
+
+func controlEvent(name string, data any) *control {
+	return &control{name: name, data: data}
+}
+
+func newSnapshotBegin(cache []*controller.Event) SnapshotBegin {
+	begin := SnapshotBegin{Total: len(cache), Kinds: make(map[string]int)}
+	for _, v := range cache {
+		begin.Kinds[types.FormatGVK(v.Object.GetObjectKind().GroupVersionKind())]++
+	}
+	return begin
+}
+
+// syncedKinds remembers the kinds a subscriber has been told to be synced, keyed by cluster and kind.
+type syncedKinds map[string]struct{}
+
+// observe returns the synced event to send if the event reports a kind synced for the first time.
+func (t syncedKinds) observe(v *controller.Event) *control {
+	if v.Type != controller.EventTypeCreate {
+		return nil
+	}
+	spec, ok := syncStatusOf(v.Object)
+	if !ok {
+		return nil
+	}
+	key := v.Cluster + "/" + spec.GVK
+	if !spec.Synced {
+		// notify again once it syncs after all
+		delete(t, key)
+		return nil
+	}
+	if _, ok := t[key]; ok {
+		return nil
+	}
+	t[key] = struct{}{}
+	return controlEvent(EventSynced, Synced{
+		Cluster: v.Cluster,
+		GVK:     spec.GVK,
+		Objects: spec.Objects,
+	})
+}
+
+// syncStatusOf returns the spec of a SyncStatus, which is unstructured when relayed from another server.
+func syncStatusOf(obj client.Object) (types.SyncStatusSpec, bool) {
+	switch o := obj.(type) {
+	case *types.SyncStatus:
+		return o.Spec, true
+	case *unstructured.Unstructured:
+		if o.GroupVersionKind() != types.KuviewGroupVersion.WithKind("SyncStatus") {
+			return types.SyncStatusSpec{}, false
+		}
+		spec, ok := o.Object["spec"].(map[string]any)
+		if !ok {
+			return types.SyncStatusSpec{}, false
+		}
+		res := types.SyncStatusSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &res); err != nil {
+			return types.SyncStatusSpec{}, false
+		}
+		return res, true
+	}
+	return types.SyncStatusSpec{}, false
+}
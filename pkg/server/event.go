@@ -3,18 +3,44 @@ package server
 import (
 	"bytes"
 	"context"
-	"fmt"
+	"net/http"
 	"runtime"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/iwanhae/kuview/pkg/audit"
 	"github.com/iwanhae/kuview/pkg/controller"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog/log"
 )
 
-func (s *Server) subscribe(c echo.Context) error {
+func (s *Server) subscribe(c echo.Context) (err error) {
+	if err := s.refuseWhileDraining(c); err != nil {
+		return err
+	}
+	release, err := s.acquireStream(c, streamSubscriber)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// cluster limits the events to a comma separated list of clusters
+	filter := &filter{}
+	if param := c.QueryParam("cluster"); param != "" {
+		filter.clusters = setOf(strings.Split(param, ","))
+	}
+
+	// batch packs up to that many events into one message
+	batch, err := parseBatchSize(c.QueryParam("batch"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
 	w := c.Response()
-	w.Header().Set("Content-Type", "text/event-stream")
+	// the events are written as server-sent events, or as binary frames if accepted
+	codec := negotiateCodec(c.Request().Header.Get(echo.HeaderAccept), batch)
+	w.Header().Set("Content-Type", codec.contentType())
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
@@ -22,74 +48,167 @@ func (s *Server) subscribe(c echo.Context) error {
 	// This is to prevent a race condition where a client subscribes
 	// and then misses an event that was sent before the client was
 	// able to receive it.
-	s.rwmu.Lock()
-	subCh := make(chan *controller.Event, 1024)
-	s.subscribers[subCh] = struct{}{}
-	cache := make([]*controller.Event, 0, len(s.cache))
-	for _, v := range s.cache {
-		cache = append(cache, &controller.Event{
-			Type:   controller.EventTypeCreate,
-			Object: v,
-		})
-	}
-	s.rwmu.Unlock()
+	var cache []*controller.Event
+	sub := s.addSubscriber(func(snapshot map[string]*controller.Event) {
+		cache = make([]*controller.Event, 0, len(snapshot))
+		for _, v := range snapshot {
+			if filter.matches(v) {
+				cache = append(cache, v)
+			}
+		}
+	})
+	subscribersGauge.WithLabelValues("sse").Inc()
+	// the access of the user is reviewed once subscribed, not to block the distribution meanwhile
+	viewer := s.viewerOf(c)
+	// the stream ends with the share link it was opened with
+	ctx, cancel := viewer.withShare(c.Request().Context())
+	defer cancel()
+	cache = viewer.filter(ctx, cache)
+	// the objects the client needs first are sent first
+	parseViewHint(c.QueryParam("namespace"), c.QueryParam("kinds")).sort(cache)
 	log.Ctx(c.Request().Context()).Info().Msg("subscribed")
+	started := auditEvent(c, audit.ActionSubscribe, audit.StageStarted)
+	started.Filters = auditFilters(c.QueryParams())
+	started.ObjectCount = len(cache)
+	s.audit(started)
 
 	defer func() {
-		s.rwmu.Lock()
-		defer s.rwmu.Unlock()
-
-		if _, ok := s.subscribers[subCh]; ok {
-			delete(s.subscribers, subCh)
-			close(subCh)
-		}
+		s.removeSubscriber(sub)
+		subscribersGauge.WithLabelValues("sse").Dec()
 		log.Ctx(c.Request().Context()).Info().Msg("unsubscribed")
+		s.audit(completed(started, err))
 	}()
 
-	// 2. Send the snapshot to the client.
-	for v := range s.encodeEventsParallel(c.Request().Context(), cache) {
+	// 2. Send the snapshot to the client, framed by control events.
+	// Every write must complete in time, or the peer is considered gone.
+	rc := http.NewResponseController(w)
+	extendWriteDeadline(rc)
+	if err := codec.writeRetry(w, RetryInterval); err != nil {
+		return err
+	}
+	if err := codec.writeControl(w, controlEvent(EventSnapshotBegin, newSnapshotBegin(cache))); err != nil {
+		return err
+	}
+	for v := range s.encodeEventsParallel(ctx, cache, codec) {
+		extendWriteDeadline(rc)
 		if _, err := w.Write(v); err != nil {
 			return err
 		}
 	}
+	if err := codec.writeControl(w, controlEvent(EventSnapshotEnd, SnapshotEnd{Total: len(cache)})); err != nil {
+		return err
+	}
+	synced := syncedKinds{}
+	for _, v := range cache {
+		if evt := synced.observe(v); evt != nil {
+			if err := codec.writeControl(w, evt); err != nil {
+				return err
+			}
+		}
+	}
 	w.Flush()
 
-	// 3. Send real-time events to the client.
+	// 3. Send real-time events to the client, and heartbeats while idle.
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+	// lag is the longest delay of the events written since the last heartbeat
+	var lag time.Duration
+	events := make([][]byte, 0, max(batch, 1))
+	controls := []*control{}
+	add := func(f *frame) {
+		if !filter.matches(f.event) || !viewer.allowedNow(ctx, f.event) {
+			return
+		}
+		events = append(events, codec.encode(f))
+		if evt := synced.observe(f.event); evt != nil {
+			controls = append(controls, evt)
+		}
+	}
 	for {
 		select {
-		case <-c.Request().Context().Done():
-			// Client disconnected.
+		case <-ctx.Done():
+			// Client disconnected, or the share link expired or was revoked.
+			return nil
+		case <-s.shutdown:
+			// The client reconnects to another replica after the retry interval.
+			extendWriteDeadline(rc)
+			if err := codec.writeRetry(w, RetryInterval); err != nil {
+				return err
+			}
+			if err := codec.writeControl(w, controlEvent(EventReconnect, Reconnect{Reason: "shutdown"})); err != nil {
+				return err
+			}
+			w.Flush()
 			return nil
-		case v := <-subCh:
-			evt := Event{
-				Data: eventAsJSON(v),
+		case <-heartbeat.C:
+			extendWriteDeadline(rc)
+			if err := codec.writeHeartbeat(w, Heartbeat{LagMs: lag.Milliseconds(), Queued: len(sub.events)}); err != nil {
+				return err
+			}
+			w.Flush()
+			lag = 0
+		case f, ok := <-sub.events:
+			if !ok || sub.discarded(f) {
+				// The distributor has stopped, or has disconnected the client falling behind.
+				return nil
 			}
-			if err := evt.MarshalTo(w); err != nil {
+			events, controls = events[:0], controls[:0]
+			oldest := f.distributed
+			add(f)
+			// in batch mode, pack the events already waiting
+		drain:
+			for len(events) < batch {
+				select {
+				case f, ok := <-sub.events:
+					if !ok {
+						break drain
+					}
+					add(f)
+				default:
+					break drain
+				}
+			}
+			if len(events) == 0 {
+				continue
+			}
+
+			extendWriteDeadline(rc)
+			if err := codec.writeEvents(w, events); err != nil {
 				// Failed to write to client, probably disconnected.
 				return err
 			}
+			for _, evt := range controls {
+				if err := codec.writeControl(w, evt); err != nil {
+					return err
+				}
+			}
 			w.Flush()
+			lag = max(lag, time.Since(oldest))
 		}
 	}
 }
 
+// extendWriteDeadline gives the next writes writeTimeout to complete.
+// The deadline is left as is if the connection does not support it.
+func extendWriteDeadline(rc *http.ResponseController) {
+	_ = rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+}
+
 // Emit implements controller.Emitter.
 func (s *Server) Emit(v *controller.Event) {
-	gvk := v.Object.GetObjectKind().GroupVersionKind()
-	namespace := v.Object.GetNamespace()
-	name := v.Object.GetName()
-	key := fmt.Sprintf("%s/%s/%s/%s/%s", gvk.Group, gvk.Version, gvk.Kind, namespace, name)
+	key := v.Key()
 
+	s.rwmu.Lock()
+	_, existed := s.cache[key]
 	switch v.Type {
 	case controller.EventTypeCreate:
-		s.rwmu.Lock()
-		s.cache[key] = v.Object
-		s.rwmu.Unlock()
+		s.cache[key] = v
 	case controller.EventTypeDelete:
-		s.rwmu.Lock()
 		delete(s.cache, key)
-		s.rwmu.Unlock()
 	}
+	s.rwmu.Unlock()
+	eventsEmitted.WithLabelValues(kindOf(v)).Inc()
+	observeCached(v, existed)
 	// it must be sent after the cache is updated
 	s.evtCh <- v
 }
@@ -100,56 +219,73 @@ var bufferPool = sync.Pool{
 	},
 }
 
-func (s *Server) encodeEventsParallel(ctx context.Context, cache []*controller.Event) <-chan []byte {
-	ch := make(chan []byte, 10*runtime.NumCPU())
+// encodeChunkSize is the number of events encoded together by a worker and written at once.
+const encodeChunkSize = 256
 
-	q := make(chan *controller.Event, 10*runtime.NumCPU())
+// encodeEventsParallel encodes the events with the codec across NumCPU goroutines.
+// The encoded chunks are delivered in the order of the events, so the snapshot keeps its priority.
+func (s *Server) encodeEventsParallel(ctx context.Context, cache []*controller.Event, codec codec) <-chan []byte {
+	type job struct {
+		events []*controller.Event
+		result chan []byte
+	}
+	// results holds the pending chunks in order, bounding how far the workers run ahead of the writer
+	results := make(chan chan []byte, 4*runtime.NumCPU())
+	jobs := make(chan job, 4*runtime.NumCPU())
 	go func() {
-		defer close(q)
-		for _, v := range cache {
-			q <- v
+		defer close(results)
+		defer close(jobs)
+		for i := 0; i < len(cache); i += encodeChunkSize {
+			j := job{
+				events: cache[i:min(i+encodeChunkSize, len(cache))],
+				result: make(chan []byte, 1),
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case results <- j.result:
+			}
+			jobs <- j
 		}
 	}()
 
-	var wg sync.WaitGroup
 	for i := 0; i < runtime.NumCPU(); i++ {
-		wg.Add(1)
 		go func() {
-			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case v, ok := <-q:
-					if !ok {
-						return
-					}
-					if v == nil {
-						continue
-					}
-					evt := Event{Data: eventAsJSON(v)}
-					buf := bufferPool.Get().(*bytes.Buffer)
-					buf.Reset()
-
-					if err := evt.MarshalTo(buf); err != nil {
-						log.Error().Err(err).Msg("failed to marshal event to buffer")
-						bufferPool.Put(buf)
-						continue
-					}
-					result := buf.Bytes()
-					// Make a copy of the bytes before putting the buffer back
-					data := make([]byte, len(result))
-					copy(data, result)
-					bufferPool.Put(buf)
-					ch <- data
-				}
+			for j := range jobs {
+				j.result <- encodeChunk(j.events, codec)
 			}
 		}()
 	}
+
+	ch := make(chan []byte)
 	go func() {
-		wg.Wait()
-		close(ch)
+		defer close(ch)
+		for result := range results {
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- <-result:
+			}
+		}
 	}()
-
 	return ch
 }
+
+func encodeChunk(events []*controller.Event, codec codec) []byte {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	data := make([][]byte, 0, len(events))
+	for _, v := range events {
+		if v == nil {
+			continue
+		}
+		data = append(data, codec.encode(newFrame(v)))
+	}
+	if err := codec.writeEvents(buf, data); err != nil {
+		log.Error().Err(err).Msg("failed to marshal event to buffer")
+	}
+	// Make a copy of the bytes before putting the buffer back
+	return bytes.Clone(buf.Bytes())
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/types"
+)
+
+// filter selects the events of a subscription. An empty set matches everything.
+type filter struct {
+	clusters map[string]struct{}
+	// kinds in the "apiVersion/kind" notation, e.g. "v1/Pod"
+	kinds map[string]struct{}
+	// namespaces only match namespaced objects in them
+	namespaces map[string]struct{}
+	// objects are keyed by Event.Key
+	objects map[string]struct{}
+}
+
+func setOf(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+func contains(set map[string]struct{}, v string) bool {
+	if set == nil {
+		return true
+	}
+	_, ok := set[v]
+	return ok
+}
+
+func (f *filter) matches(v *controller.Event) bool {
+	obj := v.Object
+	// the keys are only built for the sets given
+	switch {
+	case f.clusters != nil && !contains(f.clusters, v.Cluster):
+		return false
+	case f.kinds != nil && !contains(f.kinds, types.FormatGVK(obj.GetObjectKind().GroupVersionKind())):
+		return false
+	case f.namespaces != nil && !contains(f.namespaces, obj.GetNamespace()):
+		return false
+	case f.objects != nil && !contains(f.objects, v.Key()):
+		return false
+	}
+	return true
+}
+
+// matchesKind reports whether the filter may match objects of the kind in the cluster.
+func (f *filter) matchesKind(cluster, gvk string) bool {
+	return contains(f.clusters, cluster) && contains(f.kinds, gvk)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+)
+
+// maxBatchSize caps the number of events packed into one batch message.
+const maxBatchSize = 500
+
+// frame is an event shared by every subscriber, encoded at most once per encoding.
+type frame struct {
+	event *controller.Event
+	// distributed is when the distributor received the event, to measure the lag of the subscribers
+	distributed time.Time
+
+	jsonOnce sync.Once
+	json     []byte
+	cborOnce sync.Once
+	cbor     []byte
+}
+
+func newFrame(v *controller.Event) *frame {
+	return &frame{event: v, distributed: time.Now()}
+}
+
+// JSON returns the JSON of the event.
+func (f *frame) JSON() []byte {
+	f.jsonOnce.Do(func() {
+		defer observeEncode("json", time.Now())
+		f.json = eventAsJSON(f.event)
+	})
+	return f.json
+}
+
+// CBOR returns the CBOR of the event.
+func (f *frame) CBOR() []byte {
+	f.cborOnce.Do(func() {
+		defer observeEncode("cbor", time.Now())
+		f.cbor = eventAsCBOR(f.event)
+	})
+	return f.cbor
+}
+
+// parseBatchSize parses the batch parameter of a subscription, the maximum number of events per message.
+// "true" selects the maximum. It returns 0 if events are not to be batched.
+func parseBatchSize(param string) (int, error) {
+	switch param {
+	case "", "false":
+		return 0, nil
+	case "true":
+		return maxBatchSize, nil
+	}
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid batch size %q", param)
+	}
+	if n <= 1 {
+		return 0, nil
+	}
+	return min(n, maxBatchSize), nil
+}
+
+// writeEvents writes the encoded events, in batch messages of at most batch events if batch is positive.
+func writeEvents(w io.Writer, events [][]byte, batch int) error {
+	if batch <= 0 {
+		for _, data := range events {
+			evt := Event{Data: data}
+			if err := evt.MarshalTo(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// events failed to be encoded are empty
+	events = slices.DeleteFunc(slices.Clone(events), func(data []byte) bool { return len(data) == 0 })
+	for len(events) > 0 {
+		n := min(batch, len(events))
+		evt := Event{
+			Event: []byte(EventBatch),
+			Data:  append(append([]byte{'['}, bytes.Join(events[:n], []byte{','})...), ']'),
+		}
+		if err := evt.MarshalTo(w); err != nil {
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/labstack/echo/v4"
+)
+
+// maxPendingKinds bounds the kinds listed by /readyz while the informers sync.
+const maxPendingKinds = 10
+
+// healthz answers 200 as long as the server serves.
+func (s *Server) healthz(c echo.Context) error {
+	return c.String(http.StatusOK, "ok")
+}
+
+// readyz answers 200 once every cluster reported its kinds and every watched kind has synced,
+// so subscribers get complete snapshots. It answers 503 again while shutting down.
+func (s *Server) readyz(c echo.Context) error {
+	if err := s.refuseWhileDraining(c); err != nil {
+		return err
+	}
+	if !s.synced.Load() {
+		pending := s.pendingKinds()
+		if len(pending) > 0 {
+			if len(pending) > maxPendingKinds {
+				pending = append(pending[:maxPendingKinds], fmt.Sprintf("and %d more", len(pending)-maxPendingKinds))
+			}
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "waiting for the informers to sync: "+strings.Join(pending, ", "))
+		}
+		// the informers do not unsync, so the cache is not inspected anymore
+		s.synced.Store(true)
+	}
+	return c.String(http.StatusOK, "ok")
+}
+
+// pendingKinds returns the kinds that have not synced yet, as "cluster kind",
+// and the clusters that have not reported any kind yet.
+func (s *Server) pendingKinds() []string {
+	reported := map[string]bool{}
+	pending := []string{}
+	s.rwmu.RLock()
+	for _, v := range s.cache {
+		status, ok := v.Object.(*types.SyncStatus)
+		if !ok {
+			continue
+		}
+		reported[v.Cluster] = true
+		if syncing(status.Spec) {
+			pending = append(pending, v.Cluster+" "+status.Spec.GVK)
+		}
+	}
+	s.rwmu.RUnlock()
+
+	for _, id := range s.clusters {
+		if !hasReported(reported, id) {
+			pending = append(pending, id)
+		}
+	}
+	slices.Sort(pending)
+	return pending
+}
+
+// hasReported reports whether the cluster reported its kinds.
+// The kinds of an upstream kuview server are reported by its nested clusters, e.g. "prod~inner".
+func hasReported(reportedClusters map[string]bool, id string) bool {
+	if reportedClusters[id] {
+		return true
+	}
+	for reported := range reportedClusters {
+		if upstream, _, nested := cluster.SplitNested(reported); nested && upstream == id {
+			return true
+		}
+	}
+	return false
+}
+
+// syncing reports whether a kind is still being listed. The kinds that are not watched, as they are not served
+// or are forbidden, and the ones failing to be listed are not waited for, not to stay unready forever.
+func syncing(status types.SyncStatusSpec) bool {
+	return !status.Synced && !status.Forbidden && !status.Erroring && status.LastError == ""
+}
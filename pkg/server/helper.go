@@ -35,7 +35,7 @@ type Event struct {
 // MarshalTo marshals Event to given Writer
 func (ev *Event) MarshalTo(w io.Writer) error {
 	// Marshalling part is taken from: https://github.com/r3labs/sse/blob/c6d5381ee3ca63828b321c16baa008fd6c0b4564/http.go#L16
-	if len(ev.Data) == 0 && len(ev.Comment) == 0 {
+	if len(ev.Data) == 0 && len(ev.Comment) == 0 && len(ev.Retry) == 0 {
 		return nil
 	}
 
@@ -57,10 +57,11 @@ func (ev *Event) MarshalTo(w io.Writer) error {
 			}
 		}
 
-		if len(ev.Retry) > 0 {
-			if _, err := fmt.Fprintf(w, "retry: %s\n", ev.Retry); err != nil {
-				return err
-			}
+	}
+
+	if len(ev.Retry) > 0 {
+		if _, err := fmt.Fprintf(w, "retry: %s\n", ev.Retry); err != nil {
+			return err
 		}
 	}
 
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/server/middleware"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterCacheSize bounds the rate limiters kept. The idle ones are dropped beyond it, then arbitrary ones.
+const rateLimiterCacheSize = 4096
+
+// Limits protect the server and the API servers from clients opening too many streams or requests.
+// A client is the authenticated user, or the IP address while authentication is disabled. Zero is unlimited.
+type Limits struct {
+	// MaxSubscribers bounds the event streams and WebSocket connections, each holding a buffer of events.
+	MaxSubscribers          int `json:"maxSubscribers"`
+	MaxSubscribersPerClient int `json:"maxSubscribersPerClient"`
+	// MaxLogStreams bounds the logs read at once, through the log proxy or the WebSocket.
+	MaxLogStreams          int `json:"maxLogStreams"`
+	MaxLogStreamsPerClient int `json:"maxLogStreamsPerClient"`
+	// ProxyRate is how many requests per second a client may send to the log proxy, in bursts of ProxyBurst.
+	ProxyRate  float64 `json:"proxyRate"`
+	ProxyBurst int     `json:"proxyBurst"`
+}
+
+// stream is a kind of long-lived request counted by the limiter.
+type stream int
+
+const (
+	streamSubscriber stream = iota
+	streamLogs
+)
+
+// usage counts the streams open at once.
+type usage [2]int
+
+// limiter enforces the limits, which may change on reload.
+type limiter struct {
+	mu      sync.Mutex
+	total   usage
+	clients map[string]*usage
+	rates   map[string]*rate.Limiter
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		clients: make(map[string]*usage),
+		rates:   make(map[string]*rate.Limiter),
+	}
+}
+
+func (u usage) exceeds(kind stream, maxTotal, maxPerClient int, client usage) bool {
+	return (maxTotal > 0 && u[kind] >= maxTotal) || (maxPerClient > 0 && client[kind] >= maxPerClient)
+}
+
+// acquire counts a stream of the client, and returns the function to call once it ends.
+// It returns false if a limit is reached.
+func (l *limiter) acquire(limits Limits, client string, kind stream) (func(), bool) {
+	maxTotal, maxPerClient := limits.MaxSubscribers, limits.MaxSubscribersPerClient
+	if kind == streamLogs {
+		maxTotal, maxPerClient = limits.MaxLogStreams, limits.MaxLogStreamsPerClient
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u, ok := l.clients[client]
+	if !ok {
+		u = &usage{}
+	}
+	if l.total.exceeds(kind, maxTotal, maxPerClient, *u) {
+		return nil, false
+	}
+	l.clients[client] = u
+	l.total[kind]++
+	u[kind]++
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.total[kind]--
+			u[kind]--
+			if *u == (usage{}) {
+				delete(l.clients, client)
+			}
+		})
+	}, true
+}
+
+// wait returns how long the client has to wait before sending another request to the proxy, zero if it may now.
+func (l *limiter) wait(limits Limits, client string) time.Duration {
+	if limits.ProxyRate <= 0 {
+		return 0
+	}
+	burst := max(limits.ProxyBurst, 1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.rates[client]
+	if !ok {
+		if len(l.rates) >= rateLimiterCacheSize {
+			for k, v := range l.rates {
+				// a full bucket behaves like a new one
+				if v.Tokens() >= float64(v.Burst()) {
+					delete(l.rates, k)
+				}
+			}
+		}
+		for k := range l.rates {
+			if len(l.rates) < rateLimiterCacheSize {
+				break
+			}
+			// too many clients are busy, the map is iterated in random order
+			delete(l.rates, k)
+		}
+		r = rate.NewLimiter(rate.Limit(limits.ProxyRate), burst)
+		l.rates[client] = r
+	}
+	if r.Limit() != rate.Limit(limits.ProxyRate) || r.Burst() != burst {
+		// the limits were reloaded
+		r.SetLimit(rate.Limit(limits.ProxyRate))
+		r.SetBurst(burst)
+	}
+	reservation := r.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay
+	}
+	return 0
+}
+
+// clientOf identifies the client of the request for the limits.
+// The address is taken from X-Forwarded-For only if sent by a trusted proxy, see Options.TrustedProxies.
+func clientOf(c echo.Context) string {
+	if u := middleware.UserOf(c); u != nil {
+		return "user:" + u.Name
+	}
+	return "ip:" + c.RealIP()
+}
+
+// acquireStream counts a stream of the request, or answers 429 if a limit is reached.
+func (s *Server) acquireStream(c echo.Context, kind stream) (func(), error) {
+	release, ok := s.limiter.acquire(s.opts.Load().Limits, clientOf(c), kind)
+	if !ok {
+		what := "subscribers"
+		if kind == streamLogs {
+			what = "log streams"
+		}
+		return nil, tooManyRequests(c, RetryInterval, "too many "+what)
+	}
+	return release, nil
+}
+
+// tooManyRequests answers 429, telling the client when to retry.
+func tooManyRequests(c echo.Context, retryAfter time.Duration, msg string) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+	return echo.NewHTTPError(http.StatusTooManyRequests, msg)
+}
+
+// Status is the response of GET /kuview/api/status.
+type Status struct {
+	Subscribers Usage `json:"subscribers"`
+	LogStreams  Usage `json:"logStreams"`
+	// Clients is the number of clients with open streams.
+	Clients int `json:"clients"`
+	// Client is the usage of the caller.
+	Client ClientStatus `json:"client"`
+	Limits Limits       `json:"limits"`
+}
+
+type Usage struct {
+	Current int `json:"current"`
+	// Max is zero if unlimited.
+	Max int `json:"max"`
+}
+
+type ClientStatus struct {
+	ID          string `json:"id"`
+	Subscribers Usage  `json:"subscribers"`
+	LogStreams  Usage  `json:"logStreams"`
+}
+
+// status returns the usage of the limits. Only the usage of the caller is detailed, not to tell who else is connected.
+func (s *Server) status(c echo.Context) error {
+	limits := s.opts.Load().Limits
+	client := clientOf(c)
+
+	s.limiter.mu.Lock()
+	total, clients := s.limiter.total, len(s.limiter.clients)
+	var own usage
+	if u, ok := s.limiter.clients[client]; ok {
+		own = *u
+	}
+	s.limiter.mu.Unlock()
+
+	return c.JSON(http.StatusOK, Status{
+		Subscribers: Usage{Current: total[streamSubscriber], Max: limits.MaxSubscribers},
+		LogStreams:  Usage{Current: total[streamLogs], Max: limits.MaxLogStreams},
+		Clients:     clients,
+		Client: ClientStatus{
+			ID:          client,
+			Subscribers: Usage{Current: own[streamSubscriber], Max: limits.MaxSubscribersPerClient},
+			LogStreams:  Usage{Current: own[streamLogs], Max: limits.MaxLogStreamsPerClient},
+		},
+		Limits: limits,
+	})
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/statemetrics"
+	"github.com/iwanhae/kuview/pkg/types"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The metrics are registered along with the ones of controller-runtime and client-go,
+// e.g. the workqueues and the requests to the API servers, and served at /metrics.
+var (
+	subscribersGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kuview_subscribers",
+		Help: "Number of open event streams, by transport.",
+	}, []string{"transport"})
+	eventsEmitted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kuview_events_emitted_total",
+		Help: "Number of events emitted by the controllers and analyzers, by kind.",
+	}, []string{"kind"})
+	eventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kuview_events_dropped_total",
+		Help: "Number of events discarded with the buffer of a subscriber disconnected for falling behind, by kind.",
+	}, []string{"kind"})
+	slowSubscribers = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kuview_slow_subscriber_disconnects_total",
+		Help: "Number of subscribers disconnected for falling behind.",
+	})
+	encodeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kuview_event_encode_duration_seconds",
+		Help:    "Time taken to encode an event, by encoding.",
+		Buckets: prometheus.ExponentialBuckets(0.00001, 4, 8),
+	}, []string{"encoding"})
+	cacheObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kuview_cache_objects",
+		Help: "Number of objects cached, by cluster and kind.",
+	}, []string{"cluster", "kind"})
+	informerSynced = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kuview_informer_synced",
+		Help: "Whether the initial list of a kind has been cached, by cluster and kind.",
+	}, []string{"cluster", "kind"})
+	informerErroring = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kuview_informer_erroring",
+		Help: "Whether listing or watching a kind fails, by cluster and kind.",
+	}, []string{"cluster", "kind"})
+	proxyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kuview_proxy_request_duration_seconds",
+		Help:    "Time taken by the API servers to answer the proxied requests, until the headers, by cluster and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"cluster", "code"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		subscribersGauge,
+		eventsEmitted,
+		eventsDropped,
+		slowSubscribers,
+		encodeDuration,
+		cacheObjects,
+		informerSynced,
+		informerErroring,
+		proxyDuration,
+	)
+}
+
+// metricsHandler serves the metrics. They are compressed by the gzip middleware.
+var metricsHandler = echo.WrapHandler(promhttp.HandlerFor(ctrlmetrics.Registry, promhttp.HandlerOpts{
+	DisableCompression: true,
+}))
+
+// kindOf returns the kind of the event as a label, e.g. "v1/Pod".
+func kindOf(v *controller.Event) string {
+	return types.FormatGVK(v.Object.GetObjectKind().GroupVersionKind())
+}
+
+// observeCached updates the metrics of the cache once the event is applied to it.
+// existed tells whether the object was cached before.
+func observeCached(v *controller.Event, existed bool) {
+	kind := kindOf(v)
+	switch {
+	case v.Type == controller.EventTypeCreate && !existed:
+		cacheObjects.WithLabelValues(v.Cluster, kind).Inc()
+	case v.Type == controller.EventTypeDelete && existed:
+		cacheObjects.WithLabelValues(v.Cluster, kind).Dec()
+	}
+
+	status, ok := v.Object.(*types.SyncStatus)
+	if !ok {
+		return
+	}
+	if v.Type == controller.EventTypeDelete {
+		informerSynced.DeleteLabelValues(v.Cluster, status.Spec.GVK)
+		informerErroring.DeleteLabelValues(v.Cluster, status.Spec.GVK)
+		return
+	}
+	informerSynced.WithLabelValues(v.Cluster, status.Spec.GVK).Set(gaugeOf(status.Spec.Synced))
+	informerErroring.WithLabelValues(v.Cluster, status.Spec.GVK).Set(gaugeOf(status.Spec.Erroring))
+}
+
+func gaugeOf(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// observeEncode records how long an event took to encode since start.
+func observeEncode(encoding string, start time.Time) {
+	encodeDuration.WithLabelValues(encoding).Observe(time.Since(start).Seconds())
+}
+
+// instrumentedTransport records the latency of the requests proxied to a cluster.
+// It sends them with http.DefaultTransport if next is nil.
+type instrumentedTransport struct {
+	cluster string
+	next    http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	proxyDuration.WithLabelValues(t.cluster, code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// clusterMetrics serves the cached objects as metrics, the ones the caller may see.
+// Unlike /metrics, it requires authentication, as it tells the names of the objects.
+func (s *Server) clusterMetrics(c echo.Context) error {
+	s.rwmu.RLock()
+	events := make([]*controller.Event, 0, len(s.cache))
+	for _, v := range s.cache {
+		if statemetrics.Supports(v.Object) {
+			events = append(events, v)
+		}
+	}
+	s.rwmu.RUnlock()
+	events = s.viewerOf(c).filter(c.Request().Context(), events)
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(statemetrics.New(events)); err != nil {
+		return err
+	}
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		DisableCompression: true,
+		// a broken object does not fail the whole scrape
+		ErrorHandling: promhttp.ContinueOnError,
+	}).ServeHTTP(c.Response(), c.Request())
+	return nil
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// User is an authenticated user.
+type User struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+	// Method is how the user authenticated, e.g. "oidc" or "token".
+	Method string `json:"method"`
+	// Share is the scope of the share link the user authenticated with.
+	Share *ShareScope `json:"share,omitempty"`
+}
+
+// Authenticator authenticates requests with one kind of credentials.
+type Authenticator interface {
+	// Authenticate returns the user of the request,
+	// or nil if the request carries no credentials the authenticator accepts.
+	// An error means the credentials could not be checked.
+	Authenticate(r *http.Request) (*User, error)
+}
+
+const userKey = "kuview_user"
+
+// UserOf returns the user authenticated by Authenticate, nil if authentication is disabled.
+func UserOf(c echo.Context) *User {
+	u, _ := c.Get(userKey).(*User)
+	return u
+}
+
+// isPublic reports whether the path is served without authentication:
+// the static files of the UI, the availability check, the probes, the metrics and the login flow.
+func isPublic(path string) bool {
+	switch path {
+	case "/", "/kuview/available", LoginPath, "/auth/callback", "/auth/logout", SharePath:
+		return true
+	}
+	if isProbe(path) {
+		return true
+	}
+	return strings.HasPrefix(path, "/static")
+}
+
+// isProbe reports whether the path is polled by the kubelet or Prometheus.
+func isProbe(path string) bool {
+	return path == "/healthz" || path == "/readyz" || path == "/metrics"
+}
+
+// Authenticate is a middleware that rejects requests none of the authenticators accepts,
+// trying them in order. Every request is allowed while there is no authenticator.
+// Rejected requests are told where to log in if loginPath is set, in the X-Kuview-Login header.
+func Authenticate(authenticators func() []Authenticator, loginPath string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if isPublic(c.Request().URL.Path) {
+				return next(c)
+			}
+			auths := authenticators()
+			if len(auths) == 0 {
+				return next(c)
+			}
+
+			for _, a := range auths {
+				u, err := a.Authenticate(c.Request())
+				if err != nil {
+					log.Ctx(c.Request().Context()).Warn().Err(err).Msg("failed to authenticate")
+					continue
+				}
+				if u != nil {
+					c.Set(userKey, u)
+					return next(c)
+				}
+			}
+			c.Response().Header().Set("WWW-Authenticate", `Bearer realm="kuview"`)
+			if loginPath != "" {
+				c.Response().Header().Set("X-Kuview-Login", loginPath)
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+		}
+	}
+}
+
+// bearerToken returns the bearer token of the request, empty if none.
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// ClientCertificate authenticates clients with a verified TLS certificate,
+// by its common name and organizations as groups.
+type ClientCertificate struct{}
+
+func (ClientCertificate) Authenticate(r *http.Request) (*User, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	return &User{Name: cert.Subject.CommonName, Groups: cert.Subject.Organization, Method: "x509"}, nil
+}
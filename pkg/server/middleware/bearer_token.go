@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// StaticToken is a bearer token of a user.
+type StaticToken struct {
+	Token string
+	User  User
+}
+
+// StaticTokens authenticates requests with one of the bearer tokens,
+// e.g. to only allow a relay to subscribe to the server.
+type StaticTokens []StaticToken
+
+func (t StaticTokens) Authenticate(r *http.Request) (*User, error) {
+	got := bearerToken(r)
+	if got == "" {
+		return nil, nil
+	}
+	for _, st := range t {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(st.Token)) == 1 {
+			u := st.User
+			u.Method = "token"
+			return &u, nil
+		}
+	}
+	return nil, nil
+}
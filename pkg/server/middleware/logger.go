@@ -40,11 +40,18 @@ func Logger() echo.MiddlewareFunc {
 					evt = log.Ctx(ctx).Error()
 				} else if statusCode >= 400 {
 					evt = log.Ctx(ctx).Warn()
+				} else if isProbe(req.URL.Path) {
+					// not to flood the logs every few seconds
+					evt = log.Ctx(ctx).Debug()
 				} else {
 					evt = log.Ctx(ctx).Info()
 				}
 			}
 
+			if u := UserOf(c); u != nil {
+				evt = evt.Str("user", u.Name)
+			}
+
 			// Log the request details
 			evt.
 				Str("method", req.Method).
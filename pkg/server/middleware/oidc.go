@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie = "kuview_session"
+	// stateCookie holds the state of a login in progress
+	stateCookie = "kuview_oidc"
+	// loginTimeout is how long the user has to log in at the provider
+	loginTimeout = 10 * time.Minute
+)
+
+// LoginPath starts the login flow, e.g. /auth/login?redirect=/static/pods
+const LoginPath = "/auth/login"
+
+// OIDCConfig configures the login with an OpenID Connect provider.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered at the provider, e.g. https://kuview.example.com/auth/callback
+	RedirectURL string
+	// Scopes default to openid, email and profile.
+	Scopes []string
+	// UsernameClaim defaults to email, and GroupsClaim to groups.
+	UsernameClaim string
+	GroupsClaim   string
+	// CookieSecret signs the session cookies, at least 32 bytes.
+	// A random one is generated if empty, so sessions do not survive restarts and are not shared by replicas.
+	CookieSecret []byte
+	// SessionTTL defaults to 12h.
+	SessionTTL time.Duration
+}
+
+// OIDC logs users in with the authorization code flow and keeps them logged in with a signed session cookie.
+type OIDC struct {
+	cfg      OIDCConfig
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	signer   *cookieSigner
+}
+
+// loginState is the payload of the state cookie.
+type loginState struct {
+	State    string `json:"s"`
+	Verifier string `json:"v"`
+	Nonce    string `json:"n"`
+	Redirect string `json:"r"`
+	Expires  int64  `json:"e"`
+}
+
+// NewOIDC discovers the provider.
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OIDC, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider %s: %w", cfg.IssuerURL, err)
+	}
+	signer, err := newCookieSigner(cfg.CookieSecret)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.CookieSecret) == 0 {
+		log.Warn().Msg("no OIDC cookie secret is configured, sessions end on restart and are not shared by replicas")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "email"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.SessionTTL == 0 {
+		cfg.SessionTTL = 12 * time.Hour
+	}
+	return &OIDC{
+		cfg: cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		signer:   signer,
+	}, nil
+}
+
+// Authenticate accepts the session cookie.
+func (o *OIDC) Authenticate(r *http.Request) (*User, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, nil
+	}
+	var s session
+	if err := o.signer.verify(purposeSession, cookie.Value, &s); err != nil || time.Now().Unix() > s.Expires {
+		return nil, nil
+	}
+	return &User{Name: s.Name, Groups: s.Groups, Method: "oidc"}, nil
+}
+
+// Register adds the routes of the login flow.
+func (o *OIDC) Register(e *echo.Echo) {
+	e.GET(LoginPath, o.login)
+	e.GET("/auth/callback", o.callback)
+	e.GET("/auth/logout", o.logout)
+}
+
+func (o *OIDC) login(c echo.Context) error {
+	state := loginState{
+		State:    randomString(),
+		Verifier: oauth2.GenerateVerifier(),
+		Nonce:    randomString(),
+		Redirect: safeRedirect(c.QueryParam("redirect")),
+		Expires:  time.Now().Add(loginTimeout).Unix(),
+	}
+	value, err := o.signer.sign(purposeLoginState, state)
+	if err != nil {
+		return err
+	}
+	setCookie(c.Response(), c.Request(), stateCookie, value, time.Unix(state.Expires, 0))
+	url := o.oauth2.AuthCodeURL(state.State, oauth2.S256ChallengeOption(state.Verifier), oidc.Nonce(state.Nonce))
+	return c.Redirect(http.StatusFound, url)
+}
+
+func (o *OIDC) callback(c echo.Context) error {
+	r := c.Request()
+	if msg := c.QueryParam("error"); msg != "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "login failed: "+msg+" "+c.QueryParam("error_description"))
+	}
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "no login in progress")
+	}
+	var state loginState
+	if err := o.signer.verify(purposeLoginState, cookie.Value, &state); err != nil || time.Now().Unix() > state.Expires {
+		return echo.NewHTTPError(http.StatusBadRequest, "the login expired, try again")
+	}
+	if c.QueryParam("state") != state.State {
+		return echo.NewHTTPError(http.StatusBadRequest, "state mismatch")
+	}
+	clearCookie(c.Response(), stateCookie)
+
+	token, err := o.oauth2.Exchange(r.Context(), c.QueryParam("code"), oauth2.VerifierOption(state.Verifier))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "failed to exchange the code").SetInternal(err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "no id_token in the token response")
+	}
+	idToken, err := o.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid id_token").SetInternal(err)
+	}
+	if idToken.Nonce != state.Nonce {
+		return echo.NewHTTPError(http.StatusUnauthorized, "nonce mismatch")
+	}
+	user, err := o.userOf(idToken)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+
+	expires := time.Now().Add(o.cfg.SessionTTL)
+	value, err := o.signer.sign(purposeSession, session{Name: user.Name, Groups: user.Groups, Expires: expires.Unix()})
+	if err != nil {
+		return err
+	}
+	setCookie(c.Response(), r, sessionCookie, value, expires)
+	log.Ctx(r.Context()).Info().Str("user", user.Name).Strs("groups", user.Groups).Msg("logged in")
+	return c.Redirect(http.StatusFound, state.Redirect)
+}
+
+func (o *OIDC) userOf(idToken *oidc.IDToken) (*User, error) {
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %w", err)
+	}
+	name, _ := claims[o.cfg.UsernameClaim].(string)
+	if name == "" {
+		return nil, fmt.Errorf("the id_token has no %s claim", o.cfg.UsernameClaim)
+	}
+	user := &User{Name: name, Method: "oidc"}
+	if groups, ok := claims[o.cfg.GroupsClaim].([]any); ok {
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				user.Groups = append(user.Groups, s)
+			}
+		}
+	}
+	return user, nil
+}
+
+func (o *OIDC) logout(c echo.Context) error {
+	clearCookie(c.Response(), sessionCookie)
+	return c.Redirect(http.StatusFound, "/")
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// safeRedirect only allows paths of this server, so the login can not redirect elsewhere.
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+	return redirect
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cookieSigner signs the values kept in cookies, so the server keeps no session state
+// and every replica sharing the secret accepts them.
+type cookieSigner struct {
+	key []byte
+}
+
+// session is the payload of the session cookie.
+type session struct {
+	Name    string   `json:"n"`
+	Groups  []string `json:"g,omitempty"`
+	Expires int64    `json:"e"`
+}
+
+// purpose is what a signed value is for. It is signed along with the value,
+// so a value signed for one purpose, e.g. the state of a login, is rejected for another, e.g. as a session.
+type purpose string
+
+const (
+	purposeSession    purpose = "session"
+	purposeLoginState purpose = "login-state"
+	purposeShareLink  purpose = "share-link"
+	purposeShareID    purpose = "share-id"
+)
+
+var errInvalidCookie = errors.New("invalid cookie")
+
+func newCookieSigner(secret []byte) (*cookieSigner, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate cookie secret: %w", err)
+		}
+	}
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("cookie secret must be at least 32 bytes, got %d", len(secret))
+	}
+	return &cookieSigner{key: secret}, nil
+}
+
+func (s *cookieSigner) mac(p purpose, payload string) string {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(p))
+	h.Write([]byte{0})
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sign encodes v as payload.signature, for the purpose.
+func (s *cookieSigner) sign(p purpose, v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cookie: %w", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + s.mac(p, payload), nil
+}
+
+// verify decodes a value signed by sign for the purpose into v.
+// Payloads with fields v does not have are rejected too.
+func (s *cookieSigner) verify(p purpose, value string, v any) error {
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.mac(p, payload))) {
+		return errInvalidCookie
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return errInvalidCookie
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errInvalidCookie
+	}
+	return nil
+}
+
+// setCookie sets an HttpOnly cookie, secure if the request came over HTTPS.
+func setCookie(w http.ResponseWriter, r *http.Request, name, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		// sent on the redirect back from the provider, which is a top-level navigation
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newTestOIDC(t *testing.T) *OIDC {
+	t.Helper()
+	signer, err := newCookieSigner(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &OIDC{signer: signer}
+}
+
+// authenticateWith authenticates a request carrying the session cookie.
+func authenticateWith(t *testing.T, o *OIDC, value string) *User {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/kuview/api/objects/v1/Pod", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: value})
+	u, err := o.Authenticate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestSessionCookie(t *testing.T) {
+	o := newTestOIDC(t)
+	value, err := o.signer.sign(purposeSession, session{Name: "alice", Expires: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := authenticateWith(t, o, value); u == nil || u.Name != "alice" {
+		t.Fatalf("got user %+v, want alice", u)
+	}
+}
+
+// TestStateCookieAsSession presents the state cookie handed out by the public login route as a session cookie.
+func TestStateCookieAsSession(t *testing.T) {
+	o := newTestOIDC(t)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, LoginPath, nil), rec)
+	if err := o.login(c); err != nil {
+		t.Fatal(err)
+	}
+	var state string
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == stateCookie {
+			state = cookie.Value
+		}
+	}
+	if state == "" {
+		t.Fatal("no state cookie was set")
+	}
+
+	if u := authenticateWith(t, o, state); u != nil {
+		t.Fatalf("the state cookie authenticated %+v", u)
+	}
+}
+
+func TestShareLinkPurpose(t *testing.T) {
+	l, err := NewShareLinks(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := &ShareScope{Namespaces: []string{"shop"}, Expires: time.Now().Add(time.Hour).Unix(), CreatedBy: User{Name: "alice"}}
+	token, err := l.Mint(scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Parse(token) == nil {
+		t.Fatal("the share link was rejected")
+	}
+	value, err := l.signer.sign(purposeSession, scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Parse(shareTokenPrefix+value) != nil {
+		t.Fatal("a value signed for another purpose was accepted as a share link")
+	}
+	if !l.CreatedBy(scope.ID, "alice") || l.CreatedBy(scope.ID, "bob") {
+		t.Fatal("the share link is not bound to its creator")
+	}
+}
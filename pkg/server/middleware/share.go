@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// shareCookie holds the token of a share link opened in a browser
+	shareCookie = "kuview_share"
+	// shareTokenPrefix tells share tokens from the other bearer tokens
+	shareTokenPrefix = "kvs."
+	// shareIDMACLength is the length of the part of a link ID binding it to its creator
+	shareIDMACLength = 16
+)
+
+// SharePath opens a share link, e.g. /auth/share?token=kvs.xxx&redirect=/static/pods
+const SharePath = "/auth/share"
+
+// ShareScope is what a share link gives access to.
+// An object is in the scope if it is in the cluster and either in one of the namespaces or one of the objects.
+type ShareScope struct {
+	ID      string `json:"id"`
+	Cluster string `json:"cluster,omitempty"`
+	// Namespaces give access to every object in them.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Objects are keyed like the events, e.g. "/v1/Pod/default/web-0" or "prod//v1/Pod/default/web-0".
+	Objects []string `json:"objects,omitempty"`
+	// Logs allows reading the logs of the pods in the scope.
+	Logs    bool  `json:"logs,omitempty"`
+	Expires int64 `json:"expires"`
+	// CreatedBy is the user who created the link, whose access the link never exceeds.
+	CreatedBy User `json:"createdBy"`
+}
+
+// ShareLinks authenticates requests with signed share links, as the bearer token or the cookie set by SharePath.
+// The links are stateless, except for the revoked ones kept until they expire.
+type ShareLinks struct {
+	signer *cookieSigner
+	// denyListFile keeps the revoked links over restarts, if set
+	denyListFile string
+
+	mu sync.RWMutex
+	// revoked are the expiries of the revoked links, keyed by their ID
+	revoked map[string]int64
+	// revocation is closed on the next revocation
+	revocation chan struct{}
+}
+
+// NewShareLinks signs the links with the secret, a random one if empty.
+// The revoked links are loaded from and saved to the deny list file, if set.
+func NewShareLinks(secret []byte, denyListFile string) (*ShareLinks, error) {
+	signer, err := newCookieSigner(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		log.Warn().Msg("no share link secret is configured, share links end on restart and are not shared by replicas")
+	}
+	l := &ShareLinks{signer: signer, denyListFile: denyListFile, revoked: make(map[string]int64), revocation: make(chan struct{})}
+	if denyListFile != "" {
+		b, err := os.ReadFile(denyListFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read share link deny list: %w", err)
+		default:
+			if err := json.Unmarshal(b, &l.revoked); err != nil {
+				return nil, fmt.Errorf("failed to parse share link deny list %s: %w", denyListFile, err)
+			}
+		}
+	}
+	return l, nil
+}
+
+// Mint returns the token of a link to the scope, given a random ID bound to its creator.
+func (l *ShareLinks) Mint(scope *ShareScope) (string, error) {
+	scope.ID = l.linkID(randomString(), scope.CreatedBy.Name)
+	token, err := l.signer.sign(purposeShareLink, scope)
+	if err != nil {
+		return "", err
+	}
+	return shareTokenPrefix + token, nil
+}
+
+// linkID signs the nonce of a link along with its creator, so the links do not have to be kept to know who created them.
+func (l *ShareLinks) linkID(nonce, creator string) string {
+	return nonce + "." + l.signer.mac(purposeShareID, nonce+"\n"+creator)[:shareIDMACLength]
+}
+
+// CreatedBy reports whether the link of the ID was created by the user.
+func (l *ShareLinks) CreatedBy(id, user string) bool {
+	nonce, _, ok := strings.Cut(id, ".")
+	return ok && hmac.Equal([]byte(id), []byte(l.linkID(nonce, user)))
+}
+
+// Revoke denies the link until it expires.
+func (l *ShareLinks) Revoke(id string, expires int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now().Unix()
+	for k, e := range l.revoked {
+		if now > e {
+			delete(l.revoked, k)
+		}
+	}
+	l.revoked[id] = expires
+	close(l.revocation)
+	l.revocation = make(chan struct{})
+	if l.denyListFile == "" {
+		return nil
+	}
+	b, err := json.Marshal(l.revoked)
+	if err != nil {
+		return fmt.Errorf("failed to marshal share link deny list: %w", err)
+	}
+	if err := os.WriteFile(l.denyListFile, b, 0o600); err != nil {
+		return fmt.Errorf("failed to save share link deny list: %w", err)
+	}
+	return nil
+}
+
+// Revocation returns a channel closed once a link is revoked, so the streams check whether theirs was.
+func (l *ShareLinks) Revocation() <-chan struct{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.revocation
+}
+
+// Valid reports whether the link is neither expired nor revoked.
+func (l *ShareLinks) Valid(scope *ShareScope) bool {
+	if time.Now().Unix() > scope.Expires {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, revoked := l.revoked[scope.ID]
+	return !revoked
+}
+
+// Parse returns the scope of a valid link token, nil otherwise.
+func (l *ShareLinks) Parse(token string) *ShareScope {
+	signed, ok := strings.CutPrefix(token, shareTokenPrefix)
+	if !ok {
+		return nil
+	}
+	scope := &ShareScope{}
+	if err := l.signer.verify(purposeShareLink, signed, scope); err != nil || !l.Valid(scope) {
+		return nil
+	}
+	return scope
+}
+
+func (l *ShareLinks) Authenticate(r *http.Request) (*User, error) {
+	token := bearerToken(r)
+	if !strings.HasPrefix(token, shareTokenPrefix) {
+		cookie, err := r.Cookie(shareCookie)
+		if err != nil {
+			return nil, nil
+		}
+		token = cookie.Value
+	}
+	scope := l.Parse(token)
+	if scope == nil {
+		return nil, nil
+	}
+	return &User{Name: "share:" + scope.ID, Method: "share", Share: scope}, nil
+}
+
+// Register adds the route opening the links in a browser.
+func (l *ShareLinks) Register(e *echo.Echo) {
+	e.GET(SharePath, l.open)
+}
+
+// open keeps the token of the link in a cookie, so the UI is served with it, and redirects to the UI.
+func (l *ShareLinks) open(c echo.Context) error {
+	token := c.QueryParam("token")
+	scope := l.Parse(token)
+	if scope == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "the link is invalid, expired or revoked")
+	}
+	setCookie(c.Response(), c.Request(), shareCookie, token, time.Unix(scope.Expires, 0))
+	return c.Redirect(http.StatusFound, safeRedirect(c.QueryParam("redirect")))
+}
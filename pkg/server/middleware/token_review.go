@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// tokenReviewTTL is how long the review of an accepted token is reused.
+	tokenReviewTTL = time.Minute
+	// tokenReviewNegativeTTL is how long a rejected token stays rejected without asking again.
+	tokenReviewNegativeTTL = 10 * time.Second
+	// tokenReviewCacheSize bounds the reviews kept, expired ones are dropped beyond it.
+	tokenReviewCacheSize = 1024
+)
+
+// TokenReview authenticates bearer tokens, e.g. of service accounts, with the TokenReview API of a cluster.
+// The service account of kuview needs the system:auth-delegator cluster role.
+type TokenReview struct {
+	client    kubernetes.Interface
+	audiences []string
+
+	mu      sync.Mutex
+	reviews map[[sha256.Size]byte]tokenReviewResult
+}
+
+type tokenReviewResult struct {
+	user    *User
+	expires time.Time
+}
+
+// NewTokenReview reviews tokens with the cluster, for the audiences if any.
+func NewTokenReview(cfg *rest.Config, audiences []string) (*TokenReview, error) {
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a client for token reviews: %w", err)
+	}
+	return &TokenReview{
+		client:    client,
+		audiences: audiences,
+		reviews:   make(map[[sha256.Size]byte]tokenReviewResult),
+	}, nil
+}
+
+func (t *TokenReview) Authenticate(r *http.Request) (*User, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, nil
+	}
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	t.mu.Lock()
+	cached, ok := t.reviews[key]
+	t.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.user, nil
+	}
+
+	review, err := t.client.AuthenticationV1().TokenReviews().Create(r.Context(), &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: t.audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to review token: %w", err)
+	}
+	result := tokenReviewResult{expires: now.Add(tokenReviewNegativeTTL)}
+	if review.Status.Authenticated {
+		result = tokenReviewResult{
+			user: &User{
+				Name:   review.Status.User.Username,
+				Groups: review.Status.User.Groups,
+				Method: "tokenreview",
+			},
+			expires: now.Add(tokenReviewTTL),
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.reviews) >= tokenReviewCacheSize {
+		for k, v := range t.reviews {
+			if now.After(v.expires) {
+				delete(t.reviews, k)
+			}
+		}
+	}
+	if len(t.reviews) < tokenReviewCacheSize {
+		t.reviews[key] = result
+	}
+	return result.user, nil
+}
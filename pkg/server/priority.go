@@ -0,0 +1,124 @@
+package server
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/types"
+)
+
+// kindPriorities orders the kinds of the snapshot, the ones the UI needs first at the top.
+// Kinds not listed come after them, and events last.
+var kindPriorities = []string{
+	"v1/Namespace",
+	"v1/Node",
+	"v1/Pod",
+	"apps/v1/Deployment",
+	"apps/v1/StatefulSet",
+	"apps/v1/DaemonSet",
+	"apps/v1/ReplicaSet",
+	"batch/v1/Job",
+	"batch/v1/CronJob",
+	"v1/Service",
+	"discovery.k8s.io/v1/EndpointSlice",
+	"v1/PersistentVolumeClaim",
+	"v1/PersistentVolume",
+	"storage.k8s.io/v1/StorageClass",
+	"v1/ConfigMap",
+	"v1/ServiceAccount",
+}
+
+var kindRanks = func() map[string]int {
+	ranks := make(map[string]int, len(kindPriorities))
+	for i, gvk := range kindPriorities {
+		ranks[gvk] = i
+	}
+	return ranks
+}()
+
+// Ranks of the kinds not listed in kindPriorities.
+var (
+	rankOther   = len(kindPriorities)
+	rankKuview  = rankOther + 1
+	rankEvent   = rankOther + 2
+	ranksInTier = rankEvent + 1
+)
+
+// Tiers of the snapshot. Objects of a lower tier are sent first, ordered by the rank of their kind.
+const (
+	// tierCore holds the kinds every page needs, e.g. to populate the namespace selector.
+	tierCore = iota
+	// tierView holds the objects of the kinds the client views, in the namespace it views if any.
+	tierView
+	// tierNamespace holds the other objects of the namespace the client views.
+	tierNamespace
+	tierRest
+)
+
+// viewHint is what the client is about to show, given as the query parameters of the subscription.
+// e.g. "/kuview?namespace=default&kinds=v1/Pod,v1/Service"
+type viewHint struct {
+	namespace string
+	kinds     map[string]struct{}
+}
+
+func parseViewHint(namespace, kinds string) viewHint {
+	hint := viewHint{namespace: namespace}
+	for _, gvk := range strings.Split(kinds, ",") {
+		if gvk == "" {
+			continue
+		}
+		if hint.kinds == nil {
+			hint.kinds = make(map[string]struct{})
+		}
+		hint.kinds[gvk] = struct{}{}
+	}
+	return hint
+}
+
+// priority returns the position of an object in the snapshot, lower first.
+func (h viewHint) priority(v *controller.Event) int {
+	gvk := types.FormatGVK(v.Object.GetObjectKind().GroupVersionKind())
+	rank, ok := kindRanks[gvk]
+	switch {
+	case ok:
+	case strings.HasSuffix(gvk, "/Event"):
+		rank = rankEvent
+	case strings.HasPrefix(gvk, types.KuviewGroupVersion.Group+"/"):
+		rank = rankKuview
+	default:
+		rank = rankOther
+	}
+
+	tier := tierRest
+	_, viewed := h.kinds[gvk]
+	inNamespace := h.namespace != "" && v.Object.GetNamespace() == h.namespace
+	switch {
+	case gvk == "v1/Namespace" || gvk == "v1/Node":
+		tier = tierCore
+	case viewed && (h.namespace == "" || inNamespace):
+		tier = tierView
+	case inNamespace:
+		tier = tierNamespace
+	}
+	return tier*ranksInTier + rank
+}
+
+// sort orders the snapshot by priority. Objects of the same priority keep no particular order.
+func (h viewHint) sort(cache []*controller.Event) {
+	type ranked struct {
+		priority int
+		v        *controller.Event
+	}
+	objs := make([]ranked, len(cache))
+	for i, v := range cache {
+		objs[i] = ranked{priority: h.priority(v), v: v}
+	}
+	slices.SortFunc(objs, func(a, b ranked) int {
+		return a.priority - b.priority
+	})
+	for i := range objs {
+		cache[i] = objs[i].v
+	}
+}
@@ -1,27 +1,95 @@
 package server
 
 import (
+	"fmt"
+	"net/http"
 	"net/http/httputil"
 	"net/url"
 
+	"github.com/iwanhae/kuview/pkg/audit"
+	"github.com/iwanhae/kuview/pkg/cluster"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog/log"
 )
 
 func (s *Server) proxy(c echo.Context) error {
+	if wait := s.limiter.wait(s.opts.Load().Limits, clientOf(c)); wait > 0 {
+		return tooManyRequests(c, wait, "too many requests")
+	}
+	release, err := s.acquireStream(c, streamLogs)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	evt := log.Ctx(c.Request().Context()).Info()
 	for _, name := range c.ParamNames() {
 		value := c.Param(name)
 		evt.Str(name, value)
 	}
+	// the cluster parameter selects the upstream and is only forwarded to upstream kuview servers
+	req := c.Request()
+	query := req.URL.Query()
+	id := s.clusters[0]
+	if query.Has("cluster") {
+		id = query.Get("cluster")
+		query.Del("cluster")
+	}
+	up, inner, err := s.upstreamOf(id)
+	if err != nil {
+		return err
+	}
+	if inner != "" {
+		query.Set("cluster", inner)
+	}
+	req.URL.RawQuery = query.Encode()
+	evt.Str("cluster", id)
 	evt.Msg("proxy request")
 
-	proxyURL, err := url.Parse(s.cfg.Host + s.cfg.APIPath)
+	started := auditEvent(c, audit.ActionLogs, audit.StageStarted)
+	started.Cluster, started.Namespace, started.Pod = id, c.Param("namespace"), c.Param("pod")
+	started.Container = query.Get("container")
+	started.Filters = auditFilters(query)
+
+	viewer := s.viewerOf(c)
+	if !viewer.mayReadLogs(id, c.Param("namespace"), c.Param("pod")) {
+		err := echo.NewHTTPError(http.StatusForbidden, "the share link does not allow reading these logs")
+		s.audit(completed(started, err))
+		return err
+	}
+
+	proxyURL, err := url.Parse(up.cfg.Host + up.cfg.APIPath)
 	if err != nil {
 		return err
 	}
+	// the request is sent with the credentials of kuview, as the viewer if authorization is enabled
+	stripCredentials(req.Header)
 	proxy := httputil.NewSingleHostReverseProxy(proxyURL)
-	proxy.Transport = s.cl.Transport
-	proxy.ServeHTTP(c.Response(), c.Request())
+	proxy.Transport = &instrumentedTransport{cluster: id, next: viewer.impersonate(up.cl.Transport)}
+	// a followed log stream ends with the share link it was opened with
+	ctx, cancel := viewer.withShare(req.Context())
+	defer cancel()
+	s.audit(started)
+	proxy.ServeHTTP(c.Response(), req.WithContext(ctx))
+	var status error
+	if code := c.Response().Status; code >= http.StatusBadRequest {
+		status = fmt.Errorf("the cluster answered %d %s", code, http.StatusText(code))
+	}
+	s.audit(completed(started, status))
 	return nil
 }
+
+// upstreamOf returns the upstream of the cluster.
+// A cluster of an upstream kuview server, as in "prod~inner", is served by the upstream "prod",
+// and inner is the cluster to ask it for.
+func (s *Server) upstreamOf(id string) (*upstream, string, error) {
+	if up, ok := s.upstreams[id]; ok {
+		return up, "", nil
+	}
+	if parent, inner, nested := cluster.SplitNested(id); nested {
+		if up, ok := s.upstreams[parent]; ok {
+			return up, inner, nil
+		}
+	}
+	return nil, "", echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown cluster %q", id))
+}
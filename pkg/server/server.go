@@ -3,30 +3,87 @@ package server
 import (
 	"compress/gzip"
 	"fmt"
+	"net"
 	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/iwanhae/kuview"
+	"github.com/iwanhae/kuview/pkg/audit"
+	"github.com/iwanhae/kuview/pkg/cluster"
 	"github.com/iwanhae/kuview/pkg/controller"
 	"github.com/iwanhae/kuview/pkg/server/middleware"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog/log"
 	"k8s.io/client-go/rest"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Server struct {
 	*echo.Echo
 
-	// for caching the objects
-	cache map[string]client.Object
+	// for caching the objects, keyed by Event.Key
+	cache map[string]*controller.Event
 	rwmu  *sync.RWMutex
 
 	// for event distribution
-	subscribers map[chan *controller.Event]struct{}
+	subscribers map[*subscriber]struct{}
 	evtCh       chan *controller.Event
 
-	// for proxy-ing the request to kubernetes api server
+	// for proxy-ing the request to the kubernetes api servers, keyed by the cluster ID
+	upstreams map[string]*upstream
+	// clusters are the IDs of the clusters in the given order. The first one is the default.
+	clusters []string
+
+	// for graceful shutdown, see ListenAndServe
+	draining atomic.Bool
+	// shutdown is closed to tell the subscribers to reconnect
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	// sessions tracks the WebSocket connections, which the http.Server does not once hijacked
+	sessions sync.WaitGroup
+	// synced is set once every watched kind has synced, see readyz
+	synced atomic.Bool
+
+	opts atomic.Pointer[Options]
+	// authz restricts what the users see, nil if authorization is disabled
+	authz *authorizer
+	// limiter counts the streams of the clients, see Options.Limits
+	limiter *limiter
+}
+
+// Options are the settings of the server. AllowOrigins and Authenticators may be changed with Reload.
+type Options struct {
+	// GzipLevel is the compression level of the responses. Defaults to gzip.BestCompression.
+	GzipLevel int
+	// AllowOrigins are the origins allowed to make cross-origin requests. Every origin is allowed if empty.
+	AllowOrigins []string
+	// Authenticators authenticate the requests to everything but the UI, in order.
+	// Authentication is disabled if there is none.
+	Authenticators []middleware.Authenticator
+	// OIDC serves the login flow, if set. It is expected to be one of the Authenticators.
+	OIDC *middleware.OIDC
+	// H2C serves HTTP/2 without TLS to clients with prior knowledge, e.g. a proxy terminating TLS.
+	H2C bool
+	// TrustedProxies tell the address of the clients in X-Forwarded-For. The address of the peer is used if empty.
+	TrustedProxies []*net.IPNet
+	// Authorization shows the authenticated users only what they may list in the clusters themselves, if set.
+	Authorization *AuthorizationOptions
+	// ShareLinks serves share links, if set. It is expected to be one of the Authenticators.
+	ShareLinks *middleware.ShareLinks
+	// ShareLinkMaxTTL bounds how long share links may be valid. Defaults to DefaultShareLinkMaxTTL.
+	ShareLinkMaxTTL time.Duration
+	// Audit records the subscriptions, listings, log streams and share links, if set.
+	Audit *audit.Logger
+	// Limits bound the streams and proxy requests of the clients. It may be changed with Reload.
+	Limits Limits
+	// ClusterMetrics serves the cached objects as metrics in the style of kube-state-metrics at /metrics/cluster.
+	ClusterMetrics bool
+}
+
+type upstream struct {
 	cfg *rest.Config
 	cl  *http.Client
 }
@@ -34,27 +91,63 @@ type Server struct {
 var _ http.Handler = (*Server)(nil)
 var _ controller.Emitter = (*Server)(nil)
 
-func New(cfg *rest.Config) (*Server, error) {
-	cl, err := rest.HTTPClientFor(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create a rest client: %w", err)
+func New(opts Options, clusters ...cluster.Cluster) (*Server, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no cluster is given")
 	}
+	upstreams := make(map[string]*upstream, len(clusters))
+	ids := make([]string, 0, len(clusters))
+	for _, c := range clusters {
+		cl, err := rest.HTTPClientFor(c.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a rest client for cluster %q: %w", c.ID, err)
+		}
+		upstreams[c.ID] = &upstream{cfg: c.Config, cl: cl}
+		ids = append(ids, c.ID)
+	}
+
 	evtCh := make(chan *controller.Event)
 	s := &Server{
 		Echo:        echo.New(),
-		cache:       make(map[string]client.Object),
+		cache:       make(map[string]*controller.Event),
 		rwmu:        &sync.RWMutex{},
-		subscribers: make(map[chan *controller.Event]struct{}),
+		subscribers: make(map[*subscriber]struct{}),
 		evtCh:       evtCh,
-		cfg:         cfg,
-		cl:          cl,
+		upstreams:   upstreams,
+		clusters:    ids,
+		shutdown:    make(chan struct{}),
+		limiter:     newLimiter(),
+	}
+
+	s.opts.Store(&opts)
+	if opts.Authorization != nil {
+		authz, err := newAuthorizer(*opts.Authorization, upstreams)
+		if err != nil {
+			return nil, err
+		}
+		s.authz = authz
 	}
 
 	go s.runDistributor()
 
+	// the address of the clients is audited and limited, so it is only taken from the headers set by trusted proxies
+	s.IPExtractor = echo.ExtractIPDirect()
+	if len(opts.TrustedProxies) > 0 {
+		trust := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+		for _, proxy := range opts.TrustedProxies {
+			trust = append(trust, echo.TrustIPRange(proxy))
+		}
+		s.IPExtractor = echo.ExtractIPFromXFFHeader(trust...)
+	}
+
+	gzipLevel := opts.GzipLevel
+	if gzipLevel == 0 {
+		gzipLevel = gzip.BestCompression
+	}
+
 	// Register middleware
 	s.Use(echomiddleware.GzipWithConfig(echomiddleware.GzipConfig{
-		Level: gzip.BestCompression,
+		Level: gzipLevel,
 	}))
 	static := s.Group("/static")
 	static.Use(echomiddleware.RewriteWithConfig(echomiddleware.RewriteConfig{
@@ -75,39 +168,92 @@ func New(cfg *rest.Config) (*Server, error) {
 	s.Use(echomiddleware.RecoverWithConfig(echomiddleware.RecoverConfig{
 		DisableErrorHandler: true,
 	}))
-	s.Use(echomiddleware.CORS())
+	s.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
+		AllowOriginFunc: s.allowOrigin,
+		ExposeHeaders:   []string{"X-Kuview-Login"},
+	}))
+	loginPath := ""
+	if opts.OIDC != nil {
+		opts.OIDC.Register(s.Echo)
+		loginPath = middleware.LoginPath
+	}
+	if opts.ShareLinks != nil {
+		opts.ShareLinks.Register(s.Echo)
+	}
+	s.Use(middleware.Authenticate(func() []middleware.Authenticator {
+		return s.opts.Load().Authenticators
+	}, loginPath))
 
 	s.GET("/", func(c echo.Context) error {
 		return c.Redirect(http.StatusTemporaryRedirect, "/static")
 	})
 	s.GET("/kuview", s.subscribe)
+	s.GET("/kuview/ws", s.webSocket)
 	s.GET("/kuview/available", func(c echo.Context) error {
 		return c.String(http.StatusOK, "yes")
 	})
+	s.GET("/healthz", s.healthz)
+	s.GET("/readyz", s.readyz)
+	s.GET("/metrics", metricsHandler)
+	if opts.ClusterMetrics {
+		s.GET("/metrics/cluster", s.clusterMetrics)
+	}
+	s.GET("/auth/me", s.whoami)
+	s.GET("/kuview/api/clusters", s.listClusters)
+	s.GET("/kuview/api/status", s.status)
+	s.GET("/kuview/api/objects/*", s.listObjects)
+	if opts.ShareLinks != nil {
+		s.POST("/kuview/api/shares", s.createShare)
+		s.DELETE("/kuview/api/shares/:id", s.revokeShare)
+	}
 
-	// /api/v1/namespaces/default/pods/minio-0/log
+	// /api/v1/namespaces/default/pods/minio-0/log?cluster=prod
 	s.GET("/api/v1/namespaces/:namespace/pods/:pod/log", s.proxy)
 
 	return s, nil
 }
 
+// Reload applies the settings that may change while serving, AllowOrigins, Authenticators and Limits.
+func (s *Server) Reload(opts Options) {
+	next := *s.opts.Load()
+	next.AllowOrigins = opts.AllowOrigins
+	next.Authenticators = opts.Authenticators
+	next.Limits = opts.Limits
+	s.opts.Store(&next)
+}
+
+func (s *Server) allowOrigin(origin string) (bool, error) {
+	origins := s.opts.Load().AllowOrigins
+	return len(origins) == 0 || slices.Contains(origins, "*") || slices.Contains(origins, origin), nil
+}
+
 func (s *Server) runDistributor() {
 	for evt := range s.evtCh {
 		s.rwmu.RLock()
-		// We copy the subscriber channels to a slice under a read lock
+		// We copy the subscribers to a slice under a read lock
 		// to avoid holding the lock for a long time during the send operations.
-		subs := make([]chan *controller.Event, 0, len(s.subscribers))
+		subs := make([]*subscriber, 0, len(s.subscribers))
 		for sub := range s.subscribers {
 			subs = append(subs, sub)
 		}
 		s.rwmu.RUnlock()
+		if len(subs) == 0 {
+			continue
+		}
 
+		// The event is encoded once per encoding and the bytes are shared by every subscriber.
+		f := newFrame(evt)
 		for _, sub := range subs {
 			// Non-blocking send to prevent a slow consumer from halting distribution.
 			select {
-			case sub <- evt:
+			case sub.events <- f:
 			default:
-				// The subscriber's buffer is full. The message is dropped for this subscriber.
+				// The subscriber's buffer is full. Rather than missing events, it is disconnected
+				// to reconnect and receive a consistent snapshot again.
+				log.Warn().Int("buffer", cap(sub.events)).Int("queued", len(sub.events)).Msg("disconnecting a subscriber falling behind")
+				s.dropSubscriber(sub)
+				slowSubscribers.Inc()
+				eventsDropped.WithLabelValues(kindOf(evt)).Inc()
 			}
 		}
 	}
@@ -117,6 +263,65 @@ func (s *Server) runDistributor() {
 	defer s.rwmu.Unlock()
 	for sub := range s.subscribers {
 		delete(s.subscribers, sub)
-		close(sub)
+		close(sub.events)
+	}
+}
+
+// subscriber receives the events distributed while subscribed.
+type subscriber struct {
+	events chan *frame
+	// dropped is closed once the subscriber is disconnected for falling behind,
+	// so it ends without writing the events left in its buffer, which it gets with the next snapshot
+	dropped chan struct{}
+}
+
+// discarded reports whether the subscriber was disconnected for falling behind.
+// The received event and those left in the buffer are then counted as dropped,
+// by the subscriber itself not to stall the distributor.
+func (sub *subscriber) discarded(received *frame) bool {
+	select {
+	case <-sub.dropped:
+	default:
+		return false
+	}
+	eventsDropped.WithLabelValues(kindOf(received.event)).Inc()
+	// the events are closed before dropped
+	for f := range sub.events {
+		eventsDropped.WithLabelValues(kindOf(f.event)).Inc()
+	}
+	return true
+}
+
+// addSubscriber registers a subscriber receiving the events distributed from now on.
+// If set, snapshot is called with the cache at the same time, so no event is missed in between.
+func (s *Server) addSubscriber(snapshot func(cache map[string]*controller.Event)) *subscriber {
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+	sub := &subscriber{events: make(chan *frame, subscriberBuffer), dropped: make(chan struct{})}
+	s.subscribers[sub] = struct{}{}
+	if snapshot != nil {
+		snapshot(s.cache)
+	}
+	return sub
+}
+
+// removeSubscriber unregisters the subscriber and closes its channel, unless the distributor already has.
+func (s *Server) removeSubscriber(sub *subscriber) {
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// dropSubscriber removes a subscriber falling behind.
+func (s *Server) dropSubscriber(sub *subscriber) {
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.events)
+		close(sub.dropped)
 	}
 }
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/cluster"
+	"github.com/iwanhae/kuview/pkg/controller"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/client-go/rest"
+)
+
+func droppedEvents(t *testing.T, kind string) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := eventsDropped.WithLabelValues(kind).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+// TestSlowSubscriber checks that a subscriber falling behind is dropped without stalling the distributor,
+// and that the events discarded with its buffer are counted once it notices.
+func TestSlowSubscriber(t *testing.T) {
+	s, err := New(Options{}, cluster.Cluster{ID: "test", Config: &rest.Config{Host: "https://127.0.0.1:6443"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := droppedEvents(t, "v1/Pod")
+	slow := s.addSubscriber(nil)
+	defer s.removeSubscriber(slow)
+	for i := range subscriberBuffer {
+		slow.events <- newFrame(&controller.Event{Type: controller.EventTypeCreate, Object: benchmarkPod(i)})
+	}
+
+	emitted := make(chan struct{})
+	go func() {
+		defer close(emitted)
+		s.Emit(&controller.Event{Type: controller.EventTypeCreate, Object: benchmarkPod(subscriberBuffer)})
+		// the distributor takes the next event once done with the previous one
+		s.Emit(&controller.Event{Type: controller.EventTypeCreate, Object: benchmarkPod(subscriberBuffer + 1)})
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the distributor stalled on a slow subscriber")
+	}
+	if got, want := droppedEvents(t, "v1/Pod")-before, 1.0; got != want {
+		t.Fatalf("counted %v events dropped by the distributor, want %v", got, want)
+	}
+
+	f := <-slow.events
+	if !slow.discarded(f) {
+		t.Fatal("the slow subscriber was not dropped")
+	}
+	if got, want := droppedEvents(t, "v1/Pod")-before, float64(subscriberBuffer+1); got != want {
+		t.Fatalf("counted %v events dropped, want %v", got, want)
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/audit"
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/iwanhae/kuview/pkg/server/middleware"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// DefaultShareLinkTTL is how long a share link is valid if not given.
+	DefaultShareLinkTTL = time.Hour
+	// DefaultShareLinkMaxTTL bounds how long a share link may be valid.
+	DefaultShareLinkMaxTTL = 24 * time.Hour
+)
+
+// ShareRequest is the body of POST /kuview/api/shares.
+type ShareRequest struct {
+	// Cluster defaults to the first cluster.
+	Cluster    string   `json:"cluster,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Objects are keyed like the events.
+	Objects []string `json:"objects,omitempty"`
+	Logs    bool     `json:"logs,omitempty"`
+	// TTL is how long the link is valid, e.g. "30m". Defaults to an hour.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// ShareLink is the response of POST /kuview/api/shares.
+type ShareLink struct {
+	ID string `json:"id"`
+	// Token is sent as a bearer token to the API.
+	Token string `json:"token"`
+	// Path opens the UI with the link in a browser.
+	Path    string    `json:"path"`
+	Expires time.Time `json:"expires"`
+}
+
+// createShare mints a share link, e.g.
+// POST /kuview/api/shares {"namespaces":["shop"],"logs":true,"ttl":"2h"}
+// Share links may not create other links.
+func (s *Server) createShare(c echo.Context) error {
+	opts := s.opts.Load()
+	u := middleware.UserOf(c)
+	if u == nil || u.Share != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "share links may only be created by authenticated users")
+	}
+	req := &ShareRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	if req.Cluster == "" {
+		req.Cluster = s.clusters[0]
+	}
+	if _, _, err := s.upstreamOf(req.Cluster); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if len(req.Namespaces) == 0 && len(req.Objects) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "namespaces or objects are required")
+	}
+	ttl := DefaultShareLinkTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid ttl %q", req.TTL))
+		}
+	}
+	if maxTTL := shareLinkMaxTTL(opts); ttl > maxTTL {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("ttl must not exceed %s", maxTTL))
+	}
+
+	expires := time.Now().Add(ttl)
+	scope := &middleware.ShareScope{
+		Cluster:    req.Cluster,
+		Namespaces: req.Namespaces,
+		Objects:    req.Objects,
+		Logs:       req.Logs,
+		Expires:    expires.Unix(),
+		CreatedBy:  middleware.User{Name: u.Name, Groups: u.Groups, Method: u.Method},
+	}
+	token, err := opts.ShareLinks.Mint(scope)
+	if err != nil {
+		return err
+	}
+	log.Ctx(c.Request().Context()).Info().Str("share", scope.ID).Str("cluster", scope.Cluster).
+		Strs("namespaces", scope.Namespaces).Strs("objects", scope.Objects).Bool("logs", scope.Logs).
+		Time("expires", expires).Msg("share link created")
+	evt := auditEvent(c, audit.ActionShareCreate, audit.StageCompleted)
+	evt.ShareID, evt.Cluster, evt.Objects = scope.ID, scope.Cluster, scope.Objects
+	evt.Filters = auditFilters(map[string][]string{
+		"namespaces": scope.Namespaces,
+		"logs":       {strconv.FormatBool(scope.Logs)},
+		"expires":    {expires.UTC().Format(time.RFC3339)},
+	})
+	s.audit(evt)
+	return c.JSON(http.StatusCreated, ShareLink{
+		ID:      scope.ID,
+		Token:   token,
+		Path:    middleware.SharePath + "?" + url.Values{"token": {token}}.Encode(),
+		Expires: expires,
+	})
+}
+
+// revokeShare denies a share link from now on, e.g. DELETE /kuview/api/shares/:id
+// The streams opened with it end at once. Links are revoked by their creator or an exempt user.
+func (s *Server) revokeShare(c echo.Context) error {
+	opts := s.opts.Load()
+	u := middleware.UserOf(c)
+	if u == nil || u.Share != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "share links may only be revoked by authenticated users")
+	}
+	id := c.Param("id")
+	if !opts.ShareLinks.CreatedBy(id, u.Name) && (s.authz == nil || !s.authz.exempt(u)) {
+		return echo.NewHTTPError(http.StatusForbidden, "share links may only be revoked by their creator")
+	}
+	// no link outlives the longest TTL
+	if err := opts.ShareLinks.Revoke(id, time.Now().Add(shareLinkMaxTTL(opts)).Unix()); err != nil {
+		return err
+	}
+	log.Ctx(c.Request().Context()).Info().Str("share", id).Msg("share link revoked")
+	evt := auditEvent(c, audit.ActionShareRevoke, audit.StageCompleted)
+	evt.ShareID = id
+	s.audit(evt)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func shareLinkMaxTTL(opts *Options) time.Duration {
+	if opts.ShareLinkMaxTTL > 0 {
+		return opts.ShareLinkMaxTTL
+	}
+	return DefaultShareLinkMaxTTL
+}
+
+// expired reports whether the share link of the viewer has expired or was revoked.
+func (v *viewer) expired() bool {
+	return v != nil && v.share != nil && !v.links.Valid(v.share)
+}
+
+// withShare returns a context canceled once the share link of the viewer expires or is revoked,
+// so the streams opened with it do not outlive the link.
+func (v *viewer) withShare(ctx context.Context) (context.Context, context.CancelFunc) {
+	if v == nil || v.share == nil {
+		return context.WithCancel(ctx)
+	}
+	ctx, cancel := context.WithDeadline(ctx, time.Unix(v.share.Expires, 0))
+	go func() {
+		for {
+			// taken before checking, not to miss a revocation meanwhile
+			revocation := v.links.Revocation()
+			if v.expired() {
+				cancel()
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-revocation:
+			}
+		}
+	}()
+	return ctx, cancel
+}
+
+// inScope reports whether the object of the event is in the scope of the share link.
+// A finding is in the scope of the object it is about.
+func (v *viewer) inScope(evt *controller.Event) bool {
+	scope := v.share
+	if v.expired() || evt.Cluster != scope.Cluster {
+		return false
+	}
+	obj := evt.Object
+	if ns := obj.GetNamespace(); ns != "" && slices.Contains(scope.Namespaces, ns) {
+		return true
+	}
+	if slices.Contains(scope.Objects, evt.Key()) {
+		return true
+	}
+	if ref, ok := findingSubject(obj); ok {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return false
+		}
+		target := &metav1.PartialObjectMetadata{
+			TypeMeta:   metav1.TypeMeta{APIVersion: gv.String(), Kind: ref.Kind},
+			ObjectMeta: metav1.ObjectMeta{Namespace: ref.Namespace, Name: ref.Name},
+		}
+		return slices.Contains(scope.Objects, controller.ClusterObjectKey(evt.Cluster, target))
+	}
+	return false
+}
+
+// mayReadLogs reports whether the viewer may read the logs of the pod.
+// Share links only allow it if they were created so, for the pods in their scope.
+func (v *viewer) mayReadLogs(cluster, namespace, pod string) bool {
+	if v == nil || v.share == nil {
+		return true
+	}
+	if !v.share.Logs {
+		return false
+	}
+	return v.inScope(&controller.Event{Cluster: cluster, Object: &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: pod},
+	}})
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/server/middleware"
+)
+
+// TestWithShareRevoked checks that the streams of a share link end as soon as it is revoked.
+func TestWithShareRevoked(t *testing.T) {
+	links, err := middleware.NewShareLinks(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := &middleware.ShareScope{Namespaces: []string{"shop"}, Expires: time.Now().Add(time.Hour).Unix()}
+	if _, err := links.Mint(scope); err != nil {
+		t.Fatal(err)
+	}
+	v := &viewer{share: scope, links: links}
+	ctx, cancel := v.withShare(context.Background())
+	defer cancel()
+
+	if err := links.Revoke(scope.ID, scope.Expires); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the stream outlived the revoked share link")
+	}
+}
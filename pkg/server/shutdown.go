@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownTimeout bounds the graceful shutdown, within the default termination grace period of pods, 30s.
+const shutdownTimeout = 25 * time.Second
+
+// ListenAndServe serves on the address until the context is done, then shuts down gracefully:
+// new subscriptions are refused, subscribers are told to reconnect,
+// and in-flight requests like log proxies are given shutdownTimeout to finish.
+// It serves HTTPS if tlsConfig is not nil, and fails if the address can not be bound.
+// HTTP/2 is negotiated over TLS, so the streams of a browser share one connection
+// instead of being limited to six per host. Plain HTTP/2 is served if Options.H2C is set.
+func (s *Server) ListenAndServe(ctx context.Context, addr string, tlsConfig *tls.Config) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{
+		Handler:   s,
+		TLSConfig: tlsConfig,
+		Protocols: new(http.Protocols),
+	}
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(true)
+	srv.Protocols.SetUnencryptedHTTP2(s.opts.Load().H2C)
+	errCh := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			// the certificate is provided by tlsConfig
+			errCh <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		errCh <- srv.Serve(ln)
+	}()
+	log.Info().Str("addr", ln.Addr().String()).Bool("tls", tlsConfig != nil).Msg("serving")
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Info().Msg("shutting down the server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	s.drain()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// e.g. log proxies following a container
+		log.Warn().Err(err).Msg("closing the connections still open")
+		if err := srv.Close(); err != nil {
+			return fmt.Errorf("failed to close the server: %w", err)
+		}
+	}
+
+	sessions := make(chan struct{})
+	go func() {
+		s.sessions.Wait()
+		close(sessions)
+	}()
+	select {
+	case <-sessions:
+	case <-shutdownCtx.Done():
+		log.Warn().Msg("WebSocket sessions are still open")
+	}
+	log.Info().Msg("server stopped")
+	return nil
+}
+
+// drain refuses new subscriptions and tells the subscribers to reconnect.
+func (s *Server) drain() {
+	s.shutdownOnce.Do(func() {
+		s.draining.Store(true)
+		close(s.shutdown)
+	})
+}
+
+// refuseWhileDraining answers 503 with a retry hint while the server is shutting down.
+// The client is expected to reconnect to another replica.
+func (s *Server) refuseWhileDraining(c echo.Context) error {
+	if !s.draining.Load() {
+		return nil
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(RetryInterval.Seconds())))
+	return echo.NewHTTPError(http.StatusServiceUnavailable, "the server is shutting down")
+}
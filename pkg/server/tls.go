@@ -0,0 +1,194 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// certCheckInterval is how often the certificate files are checked for rotation, at most.
+// Mounted secrets are updated by the kubelet within about a minute.
+const certCheckInterval = 10 * time.Second
+
+// TLSOptions configure HTTPS.
+type TLSOptions struct {
+	// CertFile and KeyFile are reloaded when they change, e.g. when cert-manager rotates the certificate.
+	CertFile string
+	KeyFile  string
+	// SelfSigned serves a certificate generated on startup for localhost instead, for development.
+	SelfSigned bool
+	// ClientCAFile verifies client certificates. Clients with a verified certificate need no bearer token.
+	ClientCAFile string
+	// RequireClientCert rejects clients without a verified certificate. Otherwise it is verified if given.
+	RequireClientCert bool
+}
+
+// TLSConfig returns the TLS config serving HTTP/2 and HTTP/1.1 with the options.
+func (o TLSOptions) TLSConfig() (*tls.Config, error) {
+	r := &certReloader{certFile: o.CertFile, keyFile: o.KeyFile, caFile: o.ClientCAFile}
+	if o.SelfSigned {
+		cert, err := selfSignedCert()
+		if err != nil {
+			return nil, err
+		}
+		r.cert = cert
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	clientAuth := tls.NoClientCert
+	switch {
+	case o.ClientCAFile != "" && o.RequireClientCert:
+		clientAuth = tls.RequireAndVerifyClientCert
+	case o.ClientCAFile != "":
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		ClientAuth: clientAuth,
+	}
+	return &tls.Config{
+		MinVersion: base.MinVersion,
+		NextProtos: base.NextProtos,
+		// every handshake gets the current certificate and client CAs
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := r.current()
+			cfg := base.Clone()
+			cfg.Certificates = []tls.Certificate{*cert}
+			cfg.ClientCAs = clientCAs
+			return cfg, nil
+		},
+	}, nil
+}
+
+// certReloader holds the certificate and client CAs, reloaded from their files when modified.
+type certReloader struct {
+	certFile, keyFile, caFile string
+
+	mu        sync.Mutex
+	checked   time.Time
+	modTimes  [3]time.Time
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+func (r *certReloader) load() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	if r.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		r.cert = &cert
+	}
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in client CA file %s", r.caFile)
+		}
+		r.clientCAs = pool
+	}
+	r.modTimes = modTimes
+	r.checked = time.Now()
+	return nil
+}
+
+func (r *certReloader) stat() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for i, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return modTimes, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		modTimes[i] = fi.ModTime()
+	}
+	return modTimes, nil
+}
+
+// current returns the certificate and client CAs, reloading them if their files changed.
+// The previous ones are kept if the files are invalid, e.g. while being rotated.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < certCheckInterval {
+		return r.cert, r.clientCAs
+	}
+	r.checked = time.Now()
+	modTimes, err := r.stat()
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to check the TLS certificate for rotation")
+		return r.cert, r.clientCAs
+	}
+	if modTimes == r.modTimes {
+		return r.cert, r.clientCAs
+	}
+	if err := r.load(); err != nil {
+		log.Warn().Err(err).Msg("failed to reload the TLS certificate, keeping the current one")
+		return r.cert, r.clientCAs
+	}
+	log.Info().Str("cert", r.certFile).Str("clientCA", r.caFile).Msg("reloaded the TLS certificate")
+	return r.cert, r.clientCAs
+}
+
+// selfSignedCert generates a certificate for localhost valid for a year.
+func selfSignedCert() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	dnsNames := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		dnsNames = append(dnsNames, hostname)
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"kuview"}, CommonName: "kuview self-signed"},
+		DNSNames:              dnsNames,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create self-signed certificate: %w", err)
+	}
+	fingerprint := sha256.Sum256(der)
+	log.Warn().
+		Strs("names", dnsNames).
+		Str("sha256", hex.EncodeToString(fingerprint[:])).
+		Msg("serving a self-signed certificate, for development only")
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
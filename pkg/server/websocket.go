@@ -0,0 +1,540 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/iwanhae/kuview/pkg/audit"
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+)
+
+// Operations of the requests clients send over /kuview/ws.
+const (
+	// OpSubscribe adds a subscription, or replaces the one of the same ID, and sends its snapshot.
+	OpSubscribe = "subscribe"
+	// OpUnsubscribe removes a subscription or stops a log tail.
+	OpUnsubscribe = "unsubscribe"
+	// OpResync sends the snapshot of a subscription again.
+	OpResync = "resync"
+	// OpLogs tails the logs of a container.
+	OpLogs = "logs"
+)
+
+// Types of the messages sent over /kuview/ws, besides the control events of the stream.
+const (
+	// MessageEvents carries object events of a subscription.
+	MessageEvents = "events"
+	// MessageLog carries a line of a log tail.
+	MessageLog = "log"
+	// MessageEnd tells that a subscription or log tail has ended.
+	MessageEnd = "end"
+	// MessageError tells that a request failed.
+	MessageError = "error"
+)
+
+// WebSocketRequest is a request of a client over /kuview/ws.
+type WebSocketRequest struct {
+	Op string `json:"op"`
+	// ID names the subscription or log tail, and tags the messages sent for it.
+	ID string `json:"id"`
+
+	// Filter of a subscription. Every given field must match, and an empty one matches everything.
+	Clusters []string `json:"clusters,omitempty"`
+	// Kinds in the "apiVersion/kind" notation, e.g. "v1/Pod"
+	Kinds []string `json:"kinds,omitempty"`
+	// Namespaces only match namespaced objects in them.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Objects follows single objects by their keys, e.g. "/v1/Pod/default/nginx", or "prod//v1/Pod/default/nginx" with clusters.
+	Objects []string `json:"objects,omitempty"`
+
+	// Log selects the container of a log tail.
+	Log *LogRequest `json:"log,omitempty"`
+}
+
+type LogRequest struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container,omitempty"`
+	// TailLines is the number of past lines to send first, all of them if zero.
+	TailLines int64 `json:"tailLines,omitempty"`
+}
+
+// WebSocketMessage is a message sent to a client over /kuview/ws.
+type WebSocketMessage struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Events of a MessageEvents message, encoded like the data of the server-sent events.
+	Events []json.RawMessage `json:"events,omitempty"`
+	// Data of a control event, or the line of a log.
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// webSocket serves /kuview/ws, multiplexing subscriptions and log tails over one connection.
+func (s *Server) webSocket(c echo.Context) error {
+	if err := s.refuseWhileDraining(c); err != nil {
+		return err
+	}
+	if err := s.checkWebSocketOrigin(c); err != nil {
+		return err
+	}
+	// a connection holds a single buffer of events, whatever the number of its subscriptions
+	release, err := s.acquireStream(c, streamSubscriber)
+	if err != nil {
+		return err
+	}
+	defer release()
+	logger := log.Ctx(c.Request().Context())
+	s.sessions.Add(1)
+	defer s.sessions.Done()
+	websocket.Handler(func(ws *websocket.Conn) {
+		logger.Info().Msg("websocket connected")
+		defer logger.Info().Msg("websocket disconnected")
+
+		ctx, cancel := context.WithCancel(c.Request().Context())
+		defer cancel()
+		conn := auditEvent(c, audit.ActionWebSocket, audit.StageStarted)
+		s.audit(conn)
+		session := &wsSession{
+			Server: s,
+			ws:     ws,
+			subs:   make(map[string]*wsSubscription),
+			tails:  make(map[string]context.CancelFunc),
+			out:    make(chan []byte, 256),
+			viewer: s.viewerOf(c),
+			conn:   conn,
+			client: clientOf(c),
+		}
+		err := session.run(ctx)
+		if err != nil && !errors.Is(err, io.EOF) {
+			logger.Warn().Err(err).Msg("websocket failed")
+		} else {
+			err = nil
+		}
+		s.audit(completed(conn, err))
+	}).ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+type wsSession struct {
+	*Server
+	ws *websocket.Conn
+	// viewer restricts what the session sees, nil to see everything
+	viewer *viewer
+	// conn is the audit event of the connection, which the events of the streams are derived from
+	conn *audit.Event
+	// client identifies the session for the limits
+	client string
+
+	subs  map[string]*wsSubscription
+	tails map[string]context.CancelFunc
+	// out queues the messages of the log tails
+	out chan []byte
+	// lag is the longest delay of the events sent since the last heartbeat
+	lag time.Duration
+}
+
+type wsSubscription struct {
+	// started is the audit event of the subscription
+	started *audit.Event
+	filter  *filter
+	synced  syncedKinds
+	codec   wsCodec
+}
+
+func (w *wsSession) run(ctx context.Context) error {
+	// the session, its subscriptions and log streams end with the share link it was opened with
+	ctx, cancel := w.viewer.withShare(ctx)
+	defer cancel()
+	sub := w.addSubscriber(nil)
+	defer w.removeSubscriber(sub)
+	subscribersGauge.WithLabelValues("websocket").Inc()
+	defer subscribersGauge.WithLabelValues("websocket").Dec()
+	defer func() {
+		for _, cancel := range w.tails {
+			cancel()
+		}
+		for id := range w.subs {
+			w.endSubscription(id)
+		}
+	}()
+
+	reqCh := make(chan *WebSocketRequest)
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			req := &WebSocketRequest{}
+			if err := websocket.JSON.Receive(w.ws, req); err != nil {
+				errCh <- err
+				return
+			}
+			select {
+			case reqCh <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errCh:
+			return err
+		case <-w.shutdown:
+			return w.sendMessage(&WebSocketMessage{Type: EventReconnect, Data: Reconnect{Reason: "shutdown"}})
+		case <-heartbeat.C:
+			buf := &bytes.Buffer{}
+			if err := (wsCodec{}).writeHeartbeat(buf, Heartbeat{LagMs: w.lag.Milliseconds(), Queued: len(sub.events)}); err != nil {
+				return err
+			}
+			if err := w.send(buf.Bytes()); err != nil {
+				return err
+			}
+			w.lag = 0
+		case req := <-reqCh:
+			if err := w.handle(ctx, req); err != nil {
+				return err
+			}
+		case msg := <-w.out:
+			if err := w.send(msg); err != nil {
+				return err
+			}
+		case f, ok := <-sub.events:
+			if !ok || sub.discarded(f) {
+				// The distributor has stopped, or has disconnected the client falling behind.
+				return nil
+			}
+			if err := w.dispatch(ctx, f); err != nil {
+				return err
+			}
+			w.lag = max(w.lag, time.Since(f.distributed))
+		}
+	}
+}
+
+// send writes a message, which must complete in time or the peer is considered gone.
+func (w *wsSession) send(msg []byte) error {
+	if err := w.ws.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return websocket.Message.Send(w.ws, string(msg))
+}
+
+func (w *wsSession) sendMessage(msg *WebSocketMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return w.send(b)
+}
+
+func (w *wsSession) handle(ctx context.Context, req *WebSocketRequest) error {
+	switch req.Op {
+	case OpSubscribe:
+		if cancel, ok := w.tails[req.ID]; ok {
+			cancel()
+			delete(w.tails, req.ID)
+		}
+		w.endSubscription(req.ID)
+		started := w.auditEvent(audit.ActionSubscribe, req.ID)
+		started.Filters = auditFilters(map[string][]string{
+			"clusters":   req.Clusters,
+			"kinds":      req.Kinds,
+			"namespaces": req.Namespaces,
+			"objects":    req.Objects,
+		})
+		w.audit(started)
+		sub := &wsSubscription{
+			started: started,
+			filter: &filter{
+				clusters:   setOf(req.Clusters),
+				kinds:      setOf(req.Kinds),
+				namespaces: setOf(req.Namespaces),
+				objects:    setOf(req.Objects),
+			},
+			codec: wsCodec{id: req.ID},
+		}
+		w.subs[req.ID] = sub
+		return w.sendSnapshot(ctx, sub, viewHint{kinds: sub.filter.kinds})
+	case OpResync:
+		sub, ok := w.subs[req.ID]
+		if !ok {
+			return w.sendMessage(&WebSocketMessage{ID: req.ID, Type: MessageError, Error: "unknown subscription"})
+		}
+		return w.sendSnapshot(ctx, sub, viewHint{kinds: sub.filter.kinds})
+	case OpUnsubscribe:
+		if cancel, ok := w.tails[req.ID]; ok {
+			// the tail sends the end message once stopped
+			cancel()
+			delete(w.tails, req.ID)
+			return nil
+		}
+		w.endSubscription(req.ID)
+		return w.sendMessage(&WebSocketMessage{ID: req.ID, Type: MessageEnd})
+	case OpLogs:
+		if req.Log == nil {
+			return w.sendMessage(&WebSocketMessage{ID: req.ID, Type: MessageError, Error: "log is required"})
+		}
+		w.endSubscription(req.ID)
+		if cancel, ok := w.tails[req.ID]; ok {
+			cancel()
+			delete(w.tails, req.ID)
+		}
+		release, ok := w.limiter.acquire(w.opts.Load().Limits, w.client, streamLogs)
+		if !ok {
+			return w.sendMessage(&WebSocketMessage{ID: req.ID, Type: MessageError, Error: "too many log streams"})
+		}
+		tailCtx, cancel := context.WithCancel(ctx)
+		w.tails[req.ID] = cancel
+		go func() {
+			defer release()
+			w.tail(tailCtx, req.ID, req.Log)
+		}()
+		return nil
+	}
+	return w.sendMessage(&WebSocketMessage{ID: req.ID, Type: MessageError, Error: fmt.Sprintf("unknown op %q", req.Op)})
+}
+
+// sendSnapshot sends the cached objects matching the subscription, framed by control events.
+func (w *wsSession) sendSnapshot(ctx context.Context, sub *wsSubscription, hint viewHint) error {
+	w.rwmu.RLock()
+	cache := make([]*controller.Event, 0, len(w.cache))
+	statuses := []*controller.Event{}
+	for _, v := range w.cache {
+		if sub.filter.matches(v) {
+			cache = append(cache, v)
+		}
+		if _, ok := syncStatusOf(v.Object); ok {
+			statuses = append(statuses, v)
+		}
+	}
+	w.rwmu.RUnlock()
+	cache = w.viewer.filter(ctx, cache)
+	hint.sort(cache)
+
+	buf := &bytes.Buffer{}
+	if err := sub.codec.writeControl(buf, controlEvent(EventSnapshotBegin, newSnapshotBegin(cache))); err != nil {
+		return err
+	}
+	if err := w.send(buf.Bytes()); err != nil {
+		return err
+	}
+	// every chunk is a message
+	for msg := range w.encodeEventsParallel(ctx, cache, sub.codec) {
+		if err := w.send(msg); err != nil {
+			return err
+		}
+	}
+	buf.Reset()
+	if err := sub.codec.writeControl(buf, controlEvent(EventSnapshotEnd, SnapshotEnd{Total: len(cache)})); err != nil {
+		return err
+	}
+	if err := w.send(buf.Bytes()); err != nil {
+		return err
+	}
+
+	sub.synced = syncedKinds{}
+	for _, v := range statuses {
+		if err := w.sendSynced(sub, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendSynced sends the synced event of the kind reported by the event, if the subscription may match the kind.
+func (w *wsSession) sendSynced(sub *wsSubscription, v *controller.Event) error {
+	spec, ok := syncStatusOf(v.Object)
+	if !ok || !sub.filter.matchesKind(v.Cluster, spec.GVK) {
+		return nil
+	}
+	evt := sub.synced.observe(v)
+	if evt == nil {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	if err := sub.codec.writeControl(buf, evt); err != nil {
+		return err
+	}
+	return w.send(buf.Bytes())
+}
+
+// dispatch sends a live event to the subscriptions it matches.
+func (w *wsSession) dispatch(ctx context.Context, f *frame) error {
+	for _, sub := range w.subs {
+		if sub.filter.matches(f.event) && w.viewer.allowedNow(ctx, f.event) {
+			buf := &bytes.Buffer{}
+			if err := sub.codec.writeEvents(buf, [][]byte{sub.codec.encode(f)}); err != nil {
+				return err
+			}
+			if err := w.send(buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		if err := w.sendSynced(sub, f.event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endSubscription removes the subscription, if any, and records its end.
+func (w *wsSession) endSubscription(id string) {
+	if sub, ok := w.subs[id]; ok {
+		delete(w.subs, id)
+		w.audit(completed(sub.started, nil))
+	}
+}
+
+// auditEvent returns a Started event of a stream of the connection.
+func (w *wsSession) auditEvent(action audit.Action, stream string) *audit.Event {
+	evt := *w.conn
+	evt.Time = time.Now()
+	evt.Action = action
+	evt.Stream = stream
+	return &evt
+}
+
+// tail streams the logs of a container until the context is canceled or the logs end.
+func (w *wsSession) tail(ctx context.Context, id string, req *LogRequest) {
+	started := w.auditEvent(audit.ActionLogs, id)
+	started.Cluster, started.Namespace, started.Pod, started.Container = req.Cluster, req.Namespace, req.Pod, req.Container
+	w.audit(started)
+
+	msg := &WebSocketMessage{ID: id, Type: MessageEnd}
+	err := w.streamLogs(ctx, id, req)
+	if err != nil && ctx.Err() == nil {
+		msg = &WebSocketMessage{ID: id, Type: MessageError, Error: err.Error()}
+	} else {
+		err = nil
+	}
+	w.audit(completed(started, err))
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	// the session may have ended
+	select {
+	case w.out <- b:
+	case <-time.After(time.Second):
+	}
+}
+
+func (w *wsSession) streamLogs(ctx context.Context, id string, req *LogRequest) error {
+	cluster := req.Cluster
+	if cluster == "" {
+		cluster = w.clusters[0]
+	}
+	if !w.viewer.mayReadLogs(cluster, req.Namespace, req.Pod) {
+		return fmt.Errorf("the share link does not allow reading these logs")
+	}
+	up, inner, err := w.upstreamOf(cluster)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"follow": {"true"}}
+	if req.Container != "" {
+		query.Set("container", req.Container)
+	}
+	if req.TailLines > 0 {
+		query.Set("tailLines", strconv.FormatInt(req.TailLines, 10))
+	}
+	if inner != "" {
+		query.Set("cluster", inner)
+	}
+	u := fmt.Sprintf("%s%s/api/v1/namespaces/%s/pods/%s/log?%s",
+		up.cfg.Host, up.cfg.APIPath, url.PathEscape(req.Namespace), url.PathEscape(req.Pod), query.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	// the logs are read as the viewer, who only gets those they could with kubectl
+	cl := *up.cl
+	cl.Transport = w.viewer.impersonate(up.cl.Transport)
+	res, err := cl.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to request logs: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("failed to request logs: %s: %s", res.Status, bytes.TrimSpace(body))
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		b, err := json.Marshal(&WebSocketMessage{ID: id, Type: MessageLog, Data: scanner.Text()})
+		if err != nil {
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
+		select {
+		case w.out <- b:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// wsCodec writes the messages of a subscription. Every call writes one message.
+type wsCodec struct {
+	id string
+}
+
+func (wsCodec) contentType() string { return echo.MIMEApplicationJSON }
+
+func (wsCodec) encode(f *frame) []byte { return f.JSON() }
+
+func (c wsCodec) writeEvents(w io.Writer, events [][]byte) error {
+	// The message is assembled from the encoded events, which are already valid JSON.
+	id, err := json.Marshal(c.id)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 64+len(events)*1024))
+	buf.WriteString(`{"id":`)
+	buf.Write(id)
+	buf.WriteString(`,"type":"` + MessageEvents + `","events":[`)
+	first := true
+	for _, data := range events {
+		if len(data) == 0 {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.Write(data)
+	}
+	buf.WriteString("]}")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (c wsCodec) writeControl(w io.Writer, evt *control) error {
+	return json.NewEncoder(w).Encode(&WebSocketMessage{ID: c.id, Type: evt.name, Data: evt.data})
+}
+
+func (c wsCodec) writeHeartbeat(w io.Writer, hb Heartbeat) error {
+	return c.writeControl(w, controlEvent(EventHeartbeat, hb))
+}
+
+func (wsCodec) writeRetry(io.Writer, time.Duration) error { return nil }
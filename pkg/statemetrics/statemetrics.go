@@ -0,0 +1,288 @@
+// Package statemetrics renders the cached objects as Prometheus metrics in the style of kube-state-metrics,
+// e.g. the phases of the pods, the restarts of their containers and the conditions of the nodes.
+// The metrics are prefixed with kuview_ instead of kube_ and labeled with the cluster.
+package statemetrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	podInfo = newDesc("kuview_pod_info", "Information about the pod.",
+		"namespace", "pod", "uid", "node", "host_ip", "pod_ip", "created_by_kind", "created_by_name")
+	podCreated = newDesc("kuview_pod_created", "Unix creation timestamp of the pod.",
+		"namespace", "pod")
+	podPhase = newDesc("kuview_pod_status_phase", "The current phase of the pod.",
+		"namespace", "pod", "phase")
+	podReady = newDesc("kuview_pod_status_ready", "Whether the pod is ready to serve requests.",
+		"namespace", "pod", "condition")
+	containerReady = newDesc("kuview_pod_container_status_ready", "Whether the container is ready.",
+		"namespace", "pod", "container")
+	containerRestarts = newDesc("kuview_pod_container_status_restarts_total", "Number of restarts of the container.",
+		"namespace", "pod", "container")
+	containerWaiting = newDesc("kuview_pod_container_status_waiting_reason", "Why the container is waiting.",
+		"namespace", "pod", "container", "reason")
+	containerTerminated = newDesc("kuview_pod_container_status_last_terminated_reason", "Why the container last terminated.",
+		"namespace", "pod", "container", "reason")
+	containerRequests = newDesc("kuview_pod_container_resource_requests", "Resources requested by the container.",
+		"namespace", "pod", "container", "node", "resource", "unit")
+	containerLimits = newDesc("kuview_pod_container_resource_limits", "Resource limits of the container.",
+		"namespace", "pod", "container", "node", "resource", "unit")
+
+	nodeInfo = newDesc("kuview_node_info", "Information about the node.",
+		"node", "kernel_version", "os_image", "container_runtime_version", "kubelet_version", "internal_ip")
+	nodeCondition = newDesc("kuview_node_status_condition", "The condition of the node.",
+		"node", "condition", "status")
+	nodeUnschedulable = newDesc("kuview_node_spec_unschedulable", "Whether the node accepts new pods.",
+		"node")
+	nodeCapacity = newDesc("kuview_node_status_capacity", "The capacity of the node.",
+		"node", "resource", "unit")
+	nodeAllocatable = newDesc("kuview_node_status_allocatable", "The resources of the node available to pods.",
+		"node", "resource", "unit")
+
+	namespacePhase = newDesc("kuview_namespace_status_phase", "The phase of the namespace.",
+		"namespace", "phase")
+
+	serviceInfo = newDesc("kuview_service_info", "Information about the service.",
+		"namespace", "service", "cluster_ip", "type")
+
+	pvcInfo = newDesc("kuview_persistentvolumeclaim_info", "Information about the persistent volume claim.",
+		"namespace", "persistentvolumeclaim", "storageclass", "volumename")
+	pvcPhase = newDesc("kuview_persistentvolumeclaim_status_phase", "The phase of the persistent volume claim.",
+		"namespace", "persistentvolumeclaim", "phase")
+	pvcRequest = newDesc("kuview_persistentvolumeclaim_resource_requests_storage_bytes", "The storage requested by the persistent volume claim.",
+		"namespace", "persistentvolumeclaim")
+
+	pvPhase = newDesc("kuview_persistentvolume_status_phase", "The phase of the persistent volume.",
+		"persistentvolume", "phase")
+	pvCapacity = newDesc("kuview_persistentvolume_capacity_bytes", "The capacity of the persistent volume.",
+		"persistentvolume")
+)
+
+var (
+	podPhases       = []v1.PodPhase{v1.PodPending, v1.PodRunning, v1.PodSucceeded, v1.PodFailed, v1.PodUnknown}
+	namespacePhases = []v1.NamespacePhase{v1.NamespaceActive, v1.NamespaceTerminating}
+	pvcPhases       = []v1.PersistentVolumeClaimPhase{v1.ClaimPending, v1.ClaimBound, v1.ClaimLost}
+	pvPhases        = []v1.PersistentVolumePhase{v1.VolumePending, v1.VolumeAvailable, v1.VolumeBound, v1.VolumeReleased, v1.VolumeFailed}
+	conditions      = []v1.ConditionStatus{v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown}
+)
+
+// newDesc describes a gauge labeled with the cluster and the labels.
+func newDesc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(name, help, append([]string{"cluster"}, labels...), nil)
+}
+
+// Supports reports whether metrics are rendered for the object.
+func Supports(obj runtime.Object) bool {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		_, ok := supported[u.GroupVersionKind()]
+		return ok
+	}
+	switch obj.(type) {
+	case *v1.Pod, *v1.Node, *v1.Namespace, *v1.Service, *v1.PersistentVolumeClaim, *v1.PersistentVolume:
+		return true
+	}
+	return false
+}
+
+// supported are the kinds metrics are rendered for, returning a new object of the kind.
+var supported = map[schema.GroupVersionKind]func() runtime.Object{
+	v1.SchemeGroupVersion.WithKind("Pod"):                   func() runtime.Object { return &v1.Pod{} },
+	v1.SchemeGroupVersion.WithKind("Node"):                  func() runtime.Object { return &v1.Node{} },
+	v1.SchemeGroupVersion.WithKind("Namespace"):             func() runtime.Object { return &v1.Namespace{} },
+	v1.SchemeGroupVersion.WithKind("Service"):               func() runtime.Object { return &v1.Service{} },
+	v1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"): func() runtime.Object { return &v1.PersistentVolumeClaim{} },
+	v1.SchemeGroupVersion.WithKind("PersistentVolume"):      func() runtime.Object { return &v1.PersistentVolume{} },
+}
+
+// typed returns the object as its type, converting the unstructured objects of a relay.
+func typed(obj runtime.Object) (runtime.Object, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+	newObject, ok := supported[u.GroupVersionKind()]
+	if !ok {
+		return obj, nil
+	}
+	out := newObject()
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, out); err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s: %w", u.GetKind(), u.GetName(), err)
+	}
+	return out, nil
+}
+
+// Collector renders a snapshot of the objects. It is unchecked, as the metrics depend on the objects.
+type Collector struct {
+	events []*controller.Event
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// New returns a collector of the objects of the events, the unsupported ones are skipped.
+func New(events []*controller.Event) *Collector {
+	return &Collector{events: events}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range c.events {
+		m := &metrics{ch: ch, cluster: v.Cluster}
+		obj, err := typed(v.Object)
+		if err != nil {
+			// a broken object does not fail the whole scrape
+			ch <- prometheus.NewInvalidMetric(prometheus.NewInvalidDesc(err), err)
+			continue
+		}
+		switch obj := obj.(type) {
+		case *v1.Pod:
+			m.pod(obj)
+		case *v1.Node:
+			m.node(obj)
+		case *v1.Namespace:
+			for _, phase := range namespacePhases {
+				m.gauge(namespacePhase, boolValue(obj.Status.Phase == phase), obj.Name, string(phase))
+			}
+		case *v1.Service:
+			m.gauge(serviceInfo, 1, obj.Namespace, obj.Name, obj.Spec.ClusterIP, string(obj.Spec.Type))
+		case *v1.PersistentVolumeClaim:
+			m.pvc(obj)
+		case *v1.PersistentVolume:
+			for _, phase := range pvPhases {
+				m.gauge(pvPhase, boolValue(obj.Status.Phase == phase), obj.Name, string(phase))
+			}
+			if q, ok := obj.Spec.Capacity[v1.ResourceStorage]; ok {
+				m.gauge(pvCapacity, q.AsApproximateFloat64(), obj.Name)
+			}
+		}
+	}
+}
+
+// metrics sends the metrics of an object of the cluster.
+type metrics struct {
+	ch      chan<- prometheus.Metric
+	cluster string
+}
+
+func (m *metrics) gauge(desc *prometheus.Desc, value float64, labels ...string) {
+	m.ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, append([]string{m.cluster}, labels...)...)
+}
+
+func (m *metrics) counter(desc *prometheus.Desc, value float64, labels ...string) {
+	m.ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, append([]string{m.cluster}, labels...)...)
+}
+
+func (m *metrics) pod(pod *v1.Pod) {
+	createdByKind, createdByName := "", ""
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			createdByKind, createdByName = ref.Kind, ref.Name
+		}
+	}
+	m.gauge(podInfo, 1, pod.Namespace, pod.Name, string(pod.UID), pod.Spec.NodeName, pod.Status.HostIP, pod.Status.PodIP, createdByKind, createdByName)
+	if !pod.CreationTimestamp.IsZero() {
+		m.gauge(podCreated, float64(pod.CreationTimestamp.Unix()), pod.Namespace, pod.Name)
+	}
+	for _, phase := range podPhases {
+		m.gauge(podPhase, boolValue(pod.Status.Phase == phase), pod.Namespace, pod.Name, string(phase))
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			m.conditions(podReady, cond.Status, pod.Namespace, pod.Name)
+		}
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		m.gauge(containerReady, boolValue(status.Ready), pod.Namespace, pod.Name, status.Name)
+		m.counter(containerRestarts, float64(status.RestartCount), pod.Namespace, pod.Name, status.Name)
+		if w := status.State.Waiting; w != nil && w.Reason != "" {
+			m.gauge(containerWaiting, 1, pod.Namespace, pod.Name, status.Name, w.Reason)
+		}
+		if t := status.LastTerminationState.Terminated; t != nil && t.Reason != "" {
+			m.gauge(containerTerminated, 1, pod.Namespace, pod.Name, status.Name, t.Reason)
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		m.resources(containerRequests, c.Resources.Requests, pod.Namespace, pod.Name, c.Name, pod.Spec.NodeName)
+		m.resources(containerLimits, c.Resources.Limits, pod.Namespace, pod.Name, c.Name, pod.Spec.NodeName)
+	}
+}
+
+func (m *metrics) node(node *v1.Node) {
+	info := node.Status.NodeInfo
+	internalIP := ""
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == v1.NodeInternalIP {
+			internalIP = addr.Address
+			break
+		}
+	}
+	m.gauge(nodeInfo, 1, node.Name, info.KernelVersion, info.OSImage, info.ContainerRuntimeVersion, info.KubeletVersion, internalIP)
+	m.gauge(nodeUnschedulable, boolValue(node.Spec.Unschedulable), node.Name)
+	for _, cond := range node.Status.Conditions {
+		m.conditions(nodeCondition, cond.Status, node.Name, string(cond.Type))
+	}
+	m.resources(nodeCapacity, node.Status.Capacity, node.Name)
+	m.resources(nodeAllocatable, node.Status.Allocatable, node.Name)
+}
+
+func (m *metrics) pvc(pvc *v1.PersistentVolumeClaim) {
+	storageClass := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClass = *pvc.Spec.StorageClassName
+	}
+	m.gauge(pvcInfo, 1, pvc.Namespace, pvc.Name, storageClass, pvc.Spec.VolumeName)
+	for _, phase := range pvcPhases {
+		m.gauge(pvcPhase, boolValue(pvc.Status.Phase == phase), pvc.Namespace, pvc.Name, string(phase))
+	}
+	if q, ok := pvc.Spec.Resources.Requests[v1.ResourceStorage]; ok {
+		m.gauge(pvcRequest, q.AsApproximateFloat64(), pvc.Namespace, pvc.Name)
+	}
+}
+
+// conditions sends a metric per status of a condition, 1 for the current one, labeled last with the status.
+func (m *metrics) conditions(desc *prometheus.Desc, current v1.ConditionStatus, labels ...string) {
+	for _, status := range conditions {
+		m.gauge(desc, boolValue(current == status), append(labels, strings.ToLower(string(status)))...)
+	}
+}
+
+// resources sends a metric per resource, labeled last with the resource and its unit.
+func (m *metrics) resources(desc *prometheus.Desc, list v1.ResourceList, labels ...string) {
+	for name, q := range list {
+		res, unit := resourceLabels(name)
+		// the CPU is in cores, e.g. 0.1 for 100m
+		m.gauge(desc, q.AsApproximateFloat64(), append(labels, res, unit)...)
+	}
+}
+
+// resourceLabels returns the resource and unit labels of a resource, e.g. "nvidia_com_gpu" and "integer".
+func resourceLabels(name v1.ResourceName) (string, string) {
+	res := strings.NewReplacer(".", "_", "/", "_", "-", "_").Replace(string(name))
+	switch {
+	case name == v1.ResourceCPU:
+		return res, "core"
+	case name == v1.ResourceMemory, name == v1.ResourceStorage, name == v1.ResourceEphemeralStorage,
+		strings.HasPrefix(string(name), v1.ResourceHugePagesPrefix):
+		return res, "byte"
+	}
+	return res, "integer"
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package statemetrics
+
+import (
+	"testing"
+
+	"github.com/iwanhae/kuview/pkg/controller"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestRelayedObjects checks that the unstructured objects of a relay render the same metrics as the typed ones.
+func TestRelayedObjects(t *testing.T) {
+	pod := &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-0"},
+		Spec:       v1.PodSpec{NodeName: "node-1", Containers: []v1.Container{{Name: "web"}}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayed := &unstructured.Unstructured{Object: content}
+	if !Supports(relayed) {
+		t.Fatal("the relayed pod is not supported")
+	}
+
+	want, got := gather(t, pod), gather(t, relayed)
+	if want == 0 || got != want {
+		t.Fatalf("the relayed pod rendered %d metrics, the typed one %d", got, want)
+	}
+}
+
+// gather returns the number of metrics rendered for the object.
+func gather(t *testing.T, obj client.Object) int {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(New([]*controller.Event{{Object: obj}})); err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, f := range families {
+		n += len(f.GetMetric())
+	}
+	return n
+}
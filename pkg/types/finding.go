@@ -0,0 +1,35 @@
+package types
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Finding is a problem detected by a diagnostics rule on a single object.
+// It is emitted as kuview.iwanhae.kr/v1, Kind=Finding, in the namespace of the affected object.
+// The creation timestamp is the time the problem was first observed.
+type Finding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec FindingSpec `json:"spec"`
+}
+
+type FindingSpec struct {
+	// Rule is the name of the diagnostics rule that produced the finding. e.g. "crash-loop-back-off"
+	Rule     string          `json:"rule"`
+	Severity Severity        `json:"severity"`
+	Object   ObjectReference `json:"object"`
+	Message  string          `json:"message"`
+	// Remediation is a hint on how to resolve the problem.
+	Remediation string `json:"remediation,omitempty"`
+}
+
+func (in *Finding) DeepCopyObject() runtime.Object {
+	out := &Finding{
+		TypeMeta: in.TypeMeta,
+		Spec:     in.Spec,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return out
+}
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ParseGVK parses the "apiVersion/kind" notation used by the frontend.
+// e.g. "v1/Pod", "apps/v1/Deployment", "kuview.iwanhae.kr/v1/Finding"
+func ParseGVK(s string) (schema.GroupVersionKind, error) {
+	i := strings.LastIndex(s, "/")
+	if i <= 0 || i == len(s)-1 {
+		return schema.GroupVersionKind{}, fmt.Errorf("invalid kind %q: expected apiVersion/kind", s)
+	}
+	gv, err := schema.ParseGroupVersion(s[:i])
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("invalid kind %q: %w", s, err)
+	}
+	return gv.WithKind(s[i+1:]), nil
+}
+
+// AnalyzableGVK reports whether the analyzers observe objects of the kind: one of the watched kinds,
+// the metrics of metrics.k8s.io or the SyncStatus of the controllers. The other virtual resources
+// are produced by the analyzers and only streamed, so no analyzer observes them.
+func AnalyzableGVK(gvk schema.GroupVersionKind, watched []client.Object) bool {
+	switch gvk.GroupVersion() {
+	case KuviewGroupVersion:
+		return gvk.Kind == "SyncStatus"
+	case metricsGroupVersion:
+		return gvk.Kind == "NodeMetrics" || gvk.Kind == "PodMetrics"
+	}
+	return slices.ContainsFunc(watched, func(obj client.Object) bool {
+		return obj.GetObjectKind().GroupVersionKind() == gvk
+	})
+}
+
+var metricsGroupVersion = schema.GroupVersion{Group: "metrics.k8s.io", Version: "v1beta1"}
+
+// FormatGVK is the inverse of ParseGVK.
+func FormatGVK(gvk schema.GroupVersionKind) string {
+	return gvk.GroupVersion().String() + "/" + gvk.Kind
+}
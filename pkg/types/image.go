@@ -0,0 +1,66 @@
+package types
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ContainerImage lists where an image is used and its hygiene issues.
+// It is emitted as kuview.iwanhae.kr/v1, Kind=ContainerImage, named after the repository and a hash of the
+// normalized reference of the image, as references are not valid object names. e.g. nginx-3f2a9c1b7e
+type ContainerImage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ContainerImageSpec `json:"spec"`
+}
+
+type ContainerImageSpec struct {
+	// Reference is the normalized reference of the image. e.g. docker.io/library/nginx:1.27
+	Reference  string `json:"reference"`
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	// Digest is the digest the image is pinned to in the pod specs.
+	Digest string `json:"digest,omitempty"`
+	// References are the spellings of the image in the pod specs. e.g. "nginx" and "docker.io/library/nginx:latest"
+	References []string `json:"references"`
+	// ImageIDs are the digests the nodes actually run, as reported by the container statuses.
+	ImageIDs     []string              `json:"imageIDs,omitempty"`
+	PullPolicies []string              `json:"pullPolicies"`
+	Namespaces   []string              `json:"namespaces"`
+	Nodes        []string              `json:"nodes"`
+	Usages       []ContainerImageUsage `json:"usages"`
+	Issues       []ContainerImageIssue `json:"issues,omitempty"`
+}
+
+type ContainerImageUsage struct {
+	Namespace  string `json:"namespace"`
+	Pod        string `json:"pod"`
+	Container  string `json:"container"`
+	Node       string `json:"node,omitempty"`
+	PullPolicy string `json:"pullPolicy,omitempty"`
+}
+
+type ContainerImageIssue struct {
+	// Check identifies the issue. e.g. "latest-tag"
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (in *ContainerImage) DeepCopyObject() runtime.Object {
+	out := &ContainerImage{
+		TypeMeta: in.TypeMeta,
+		Spec:     in.Spec,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.References = append([]string(nil), in.Spec.References...)
+	out.Spec.ImageIDs = append([]string(nil), in.Spec.ImageIDs...)
+	out.Spec.PullPolicies = append([]string(nil), in.Spec.PullPolicies...)
+	out.Spec.Namespaces = append([]string(nil), in.Spec.Namespaces...)
+	out.Spec.Nodes = append([]string(nil), in.Spec.Nodes...)
+	out.Spec.Usages = append([]ContainerImageUsage(nil), in.Spec.Usages...)
+	out.Spec.Issues = append([]ContainerImageIssue(nil), in.Spec.Issues...)
+	return out
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KuviewGroupVersion is the group version of the virtual resources
+// synthesized by kuview itself. They never exist in the API server.
+var KuviewGroupVersion = schema.GroupVersion{Group: "kuview.iwanhae.kr", Version: "v1"}
+
+// KuviewKinds are the kinds of the virtual resources.
+var KuviewKinds = []string{
+	"Finding", "RBACSubjectRisk", "PodSecurityViolation", "PodSecuritySummary",
+	"ContainerImage", "SyncStatus", "UpstreamStatus",
+}
+
+// Severity describes how urgent a finding produced by kuview is.
+type Severity string
+
+const (
+	SeverityCritical Severity = "Critical"
+	SeverityHigh     Severity = "High"
+	SeverityMedium   Severity = "Medium"
+	SeverityLow      Severity = "Low"
+)
+
+// Weight returns a score used to rank findings by severity.
+func (s Severity) Weight() int {
+	switch s {
+	case SeverityCritical:
+		return 100
+	case SeverityHigh:
+		return 30
+	case SeverityMedium:
+		return 10
+	case SeverityLow:
+		return 1
+	}
+	return 0
+}
+
+// ObjectReference points to the object a finding is about.
+type ObjectReference struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
@@ -0,0 +1,113 @@
+package types
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PodSecurityLevel is a profile of the Pod Security Standards.
+// https://kubernetes.io/docs/concepts/security/pod-security-standards/
+type PodSecurityLevel string
+
+const (
+	PodSecurityPrivileged PodSecurityLevel = "privileged"
+	PodSecurityBaseline   PodSecurityLevel = "baseline"
+	PodSecurityRestricted PodSecurityLevel = "restricted"
+)
+
+// Allows reports whether a pod satisfying the level l is admitted by a namespace requiring the level required.
+// An empty required level allows everything, as the admission controller does.
+func (l PodSecurityLevel) Allows(required PodSecurityLevel) bool {
+	rank := func(l PodSecurityLevel) int {
+		switch l {
+		case PodSecurityBaseline:
+			return 1
+		case PodSecurityRestricted:
+			return 2
+		}
+		return 0
+	}
+	return rank(l) >= rank(required)
+}
+
+// PodSecurityViolation lists the controls of the Pod Security Standards a running pod fails.
+// It is emitted as kuview.iwanhae.kr/v1, Kind=PodSecurityViolation, with the namespace and name of the pod.
+type PodSecurityViolation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PodSecurityViolationSpec `json:"spec"`
+}
+
+type PodSecurityViolationSpec struct {
+	// Level is the most restrictive profile the pod satisfies.
+	Level  PodSecurityLevel   `json:"level"`
+	Checks []PodSecurityCheck `json:"checks"`
+	Modes  PodSecurityModes   `json:"namespaceModes"`
+	// Violates lists the modes of the namespace whose level the pod does not satisfy.
+	Violates []string `json:"violates,omitempty"`
+}
+
+// PodSecurityCheck is a failed control of a profile.
+type PodSecurityCheck struct {
+	// Check is the name of the control. e.g. "host-namespaces"
+	Check   string           `json:"check"`
+	Profile PodSecurityLevel `json:"profile"`
+	// Container is empty when the control applies to the pod.
+	Container string `json:"container,omitempty"`
+	Message   string `json:"message"`
+}
+
+// PodSecurityModes are the levels set by the pod-security.kubernetes.io/* labels of a namespace.
+type PodSecurityModes struct {
+	Enforce PodSecurityLevel `json:"enforce,omitempty"`
+	Audit   PodSecurityLevel `json:"audit,omitempty"`
+	Warn    PodSecurityLevel `json:"warn,omitempty"`
+}
+
+func (in *PodSecurityViolation) DeepCopyObject() runtime.Object {
+	out := &PodSecurityViolation{
+		TypeMeta: in.TypeMeta,
+		Spec:     in.Spec,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.Checks = append([]PodSecurityCheck(nil), in.Spec.Checks...)
+	out.Spec.Violates = append([]string(nil), in.Spec.Violates...)
+	return out
+}
+
+// PodSecuritySummary summarizes the compliance of the running pods of a namespace.
+// It is emitted as kuview.iwanhae.kr/v1, Kind=PodSecuritySummary, named after the namespace.
+type PodSecuritySummary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PodSecuritySummarySpec `json:"spec"`
+}
+
+type PodSecuritySummarySpec struct {
+	Modes PodSecurityModes `json:"namespaceModes"`
+	// Pods is the number of running pods. The other counts break it down.
+	Pods int `json:"pods"`
+	// Levels counts the pods by the most restrictive profile they satisfy.
+	Levels map[PodSecurityLevel]int `json:"levels"`
+	// Violations counts the pods that do not satisfy the level of each mode of the namespace.
+	Violations map[string]int `json:"violations"`
+}
+
+func (in *PodSecuritySummary) DeepCopyObject() runtime.Object {
+	out := &PodSecuritySummary{
+		TypeMeta: in.TypeMeta,
+		Spec:     in.Spec,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.Levels = make(map[PodSecurityLevel]int, len(in.Spec.Levels))
+	for k, v := range in.Spec.Levels {
+		out.Spec.Levels[k] = v
+	}
+	out.Spec.Violations = make(map[string]int, len(in.Spec.Violations))
+	for k, v := range in.Spec.Violations {
+		out.Spec.Violations[k] = v
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RBACSubjectRisk summarizes the dangerous grants held by a single RBAC subject.
+// It is emitted as kuview.iwanhae.kr/v1, Kind=RBACSubjectRisk.
+type RBACSubjectRisk struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RBACSubjectRiskSpec `json:"spec"`
+}
+
+type RBACSubjectRiskSpec struct {
+	Subject rbacv1.Subject `json:"subject"`
+	// Score is the sum of the weights of all findings.
+	Score int `json:"score"`
+	// Rank is the position of the subject when all subjects are sorted by score, starting from 1.
+	Rank int `json:"rank"`
+	// Severity is the highest severity among the findings.
+	Severity Severity          `json:"severity"`
+	Findings []RBACRiskFinding `json:"findings"`
+}
+
+type RBACRiskFinding struct {
+	// Rule is a stable identifier of the check that produced the finding. e.g. "wildcard-verbs"
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	// Scope is the namespace the grant applies to. Empty means cluster-wide.
+	Scope   string          `json:"scope,omitempty"`
+	Binding ObjectReference `json:"binding"`
+	Role    ObjectReference `json:"role"`
+}
+
+func (in *RBACSubjectRisk) DeepCopyObject() runtime.Object {
+	out := &RBACSubjectRisk{
+		TypeMeta: in.TypeMeta,
+		Spec:     in.Spec,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.Findings = append([]RBACRiskFinding(nil), in.Spec.Findings...)
+	return out
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// SyncStatus describes whether kuview can watch a kind.
+// It is emitted as kuview.iwanhae.kr/v1, Kind=SyncStatus, named after the watched kind. e.g. "pod.v1"
+type SyncStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SyncStatusSpec `json:"spec"`
+}
+
+type SyncStatusSpec struct {
+	// GVK is the watched kind in the notation of the UI. e.g. "v1/Pod"
+	GVK string `json:"gvk"`
+	// Synced is set once the initial list of the kind has been cached.
+	Synced bool `json:"synced"`
+	// Forbidden is set if the user may not list or watch the kind. The kind is not watched at all
+	// if it is found forbidden at startup.
+	Forbidden bool `json:"forbidden"`
+	// Erroring is set while listing or watching the kind fails.
+	Erroring bool `json:"erroring"`
+	// Objects is the number of cached objects of the kind.
+	Objects       int          `json:"objects"`
+	LastError     string       `json:"lastError,omitempty"`
+	LastErrorTime *metav1.Time `json:"lastErrorTime,omitempty"`
+}
+
+func (in *SyncStatus) DeepCopyObject() runtime.Object {
+	out := &SyncStatus{
+		TypeMeta: in.TypeMeta,
+		Spec:     in.Spec,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Spec.LastErrorTime != nil {
+		out.Spec.LastErrorTime = in.Spec.LastErrorTime.DeepCopy()
+	}
+	return out
+}
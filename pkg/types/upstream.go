@@ -0,0 +1,40 @@
+package types
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// UpstreamStatus describes the connection of a relay to an upstream kuview server.
+// It is emitted as kuview.iwanhae.kr/v1, Kind=UpstreamStatus, named after the upstream.
+type UpstreamStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec UpstreamStatusSpec `json:"spec"`
+}
+
+type UpstreamStatusSpec struct {
+	URL       string `json:"url"`
+	Connected bool   `json:"connected"`
+	// LastConnected is when the current or the last connection was established.
+	LastConnected *metav1.Time `json:"lastConnected,omitempty"`
+	// LastError is the reason the last connection attempt failed or the last connection was lost.
+	LastError string `json:"lastError,omitempty"`
+	// Reconnects counts the connections established after the first one.
+	Reconnects int `json:"reconnects"`
+	// Objects is the number of objects relayed from the upstream.
+	Objects int `json:"objects"`
+}
+
+func (in *UpstreamStatus) DeepCopyObject() runtime.Object {
+	out := &UpstreamStatus{
+		TypeMeta: in.TypeMeta,
+		Spec:     in.Spec,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Spec.LastConnected != nil {
+		out.Spec.LastConnected = in.Spec.LastConnected.DeepCopy()
+	}
+	return out
+}
@@ -1,4 +1,5 @@
-import type { KuviewEvent } from "@/lib/kuview";
+import { streamCBOR } from "@/lib/cbor";
+import type { KuviewControlEvent, KuviewEvent } from "@/lib/kuview";
 import {
   handleEvent,
   kubernetesAtom,
@@ -6,6 +7,7 @@ import {
   useKubernetesAtomSyncHook,
   useServiceEndpointSliceSyncHook,
   usePodIndexSyncHook,
+  useStreamStatusSyncHook,
 } from "@/lib/kuviewAtom";
 
 import { useAtomValue } from "jotai";
@@ -13,11 +15,38 @@ import { useEffect } from "react";
 import { SyncUserGroup } from "./userGroup";
 
 interface WindowWithKuview extends Window {
-  kuview: (event: KuviewEvent) => void;
+  kuview: (event: KuviewEvent | KuviewControlEvent) => void;
 }
 
 interface WindowWithKuviewQueue extends Window {
-  kuviewEventQueue?: KuviewEvent[];
+  kuviewEventQueue?: (KuviewEvent | KuviewControlEvent)[];
+}
+
+interface WindowWithKuviewStream extends Window {
+  // URL of the binary stream to read, set when opened with ?encoding=cbor
+  kuviewStream?: string;
+}
+
+let streaming = false;
+
+// STREAM_RETRY_MS matches the retry hint of the server-sent events
+const STREAM_RETRY_MS = 3000;
+
+function startBinaryStream() {
+  const url = (window as WindowWithKuviewStream).kuviewStream;
+  if (!url || streaming) {
+    return;
+  }
+  streaming = true;
+  streamCBOR(url, (event) =>
+    (window as unknown as WindowWithKuview).kuview(event),
+  )
+    .catch((e) => console.error("[REACT] Error from the binary stream:", e))
+    .finally(() => {
+      // the stream ends when the server shuts down, reconnect like EventSource does
+      streaming = false;
+      setTimeout(startBinaryStream, STREAM_RETRY_MS);
+    });
 }
 
 export default function KuviewBackground() {
@@ -40,13 +69,18 @@ export default function KuviewBackground() {
 
     // Set the actual event handler
     console.log("[REACT] Setting event handler");
-    (window as unknown as WindowWithKuview).kuview = (event: KuviewEvent) => {
+    (window as unknown as WindowWithKuview).kuview = (
+      event: KuviewEvent | KuviewControlEvent,
+    ) => {
       handleEvent(event);
     };
+    startBinaryStream();
 
     // Cleanup function to remove the event handler if the component unmounts
     return () => {
-      (window as unknown as WindowWithKuview).kuview = (event: KuviewEvent) => {
+      (window as unknown as WindowWithKuview).kuview = (
+        event: KuviewEvent | KuviewControlEvent,
+      ) => {
         // Optionally, re-instate queueing or log if events are received after unmount
         console.log(
           "KuviewBackground unmounted, event received but not handled:",
@@ -62,6 +96,7 @@ export default function KuviewBackground() {
 function SyncKubernetes() {
   useKubernetesAtomSyncHook();
   usePodIndexSyncHook(); // Added for Pod index synchronization
+  useStreamStatusSyncHook();
   const kubernetes = useAtomValue(kubernetesAtom);
   const gvks = Object.keys(kubernetes);
   const normalGVKs = gvks.filter(
@@ -30,10 +30,17 @@ export function SyncUserGroup() {
       let role: RoleObject | ClusterRoleObject | undefined = undefined;
       if (binding.roleRef.kind === "Role") {
         role =
-          r[`${binding.metadata.namespace}/${binding.roleRef.name}`] ??
-          undefined;
+          r[
+            toNN(
+              binding.roleRef.name,
+              binding.metadata.namespace,
+              binding.kuviewCluster,
+            )
+          ] ?? undefined;
       } else if (binding.roleRef.kind === "ClusterRole") {
-        role = cr[binding.roleRef.name] ?? undefined;
+        role =
+          cr[toNN(binding.roleRef.name, undefined, binding.kuviewCluster)] ??
+          undefined;
       }
 
       if (!role) {
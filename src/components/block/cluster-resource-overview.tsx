@@ -14,6 +14,7 @@ import { useState, useMemo, useEffect } from "react";
 import { RefreshCw } from "lucide-react";
 import dayjs from "dayjs";
 import { cpuChartConfig, memoryChartConfig } from "@/config/charts";
+import { getNN } from "@/lib/kuviewAtom";
 
 interface ClusterResourceUsage {
   cpu: {
@@ -55,7 +56,7 @@ function calculateClusterResourceUsage(
     totalCpuCapacity += parseCpu(capacity.cpu || "0");
     totalMemoryCapacity += parseMemory(capacity.memory || "0");
 
-    const metrics = nodeMetrics[node.metadata.name];
+    const metrics = nodeMetrics[getNN(node)];
     if (metrics) {
       totalCpuUsage += parseCpu(metrics.usage.cpu || "0");
       totalMemoryUsage += parseMemory(metrics.usage.memory || "0");
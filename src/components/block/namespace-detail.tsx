@@ -29,11 +29,15 @@ export default function NamespaceDetail({
 
   // Filter resources belonging to this namespace
   const namespacePods = Object.values(pods).filter(
-    (pod) => pod.metadata.namespace === namespace.metadata.name,
+    (pod) =>
+      pod.metadata.namespace === namespace.metadata.name &&
+      pod.kuviewCluster === namespace.kuviewCluster,
   );
 
   const namespaceServices = Object.values(services).filter(
-    (service) => service.metadata.namespace === namespace.metadata.name,
+    (service) =>
+      service.metadata.namespace === namespace.metadata.name &&
+      service.kuviewCluster === namespace.kuviewCluster,
   );
 
   return (
@@ -16,6 +16,7 @@ import NodePodList from "./node-pod-list";
 import { usePodsByNode } from "@/hooks/usePodsByNode";
 import PodsVolumeList from "./pods-volume-list";
 import MetadataHeader from "./metadata-header";
+import { getNN } from "@/lib/kuviewAtom";
 
 interface NodeDetailProps {
   node: NodeObject;
@@ -26,7 +27,7 @@ export default function NodeDetail({ node, className }: NodeDetailProps) {
   const [jsonExpanded, setJsonExpanded] = useState(false);
 
   // Use optimized hook to get pods for this node
-  const nodePods = usePodsByNode(node.metadata.name);
+  const nodePods = usePodsByNode(getNN(node));
 
   return (
     <div className={cn("space-y-6", className)}>
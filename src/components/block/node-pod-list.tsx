@@ -12,6 +12,7 @@ import { PREFIX } from "@/lib/const";
 import { useMemo, useState } from "react";
 import Chart from "react-apexcharts";
 import type { ApexOptions } from "apexcharts";
+import { getNN } from "@/lib/kuviewAtom";
 
 const MAX_PODS = 50;
 
@@ -97,15 +98,14 @@ export default function NodePodList({ pods, node }: NodePodListProps) {
     useState<MetricType>("memory-usage");
   const podMetricsData = useKuview("metrics.k8s.io/v1beta1/PodMetrics");
 
-  const handlePodClick = (podName: string, namespace: string) => {
-    const podId = `${namespace}/${podName}`;
+  const handlePodClick = (podId: string) => {
     setLocation(`${PREFIX}/pods?pod=${encodeURIComponent(podId)}`);
   };
 
   const { chartData, chartOptions, nodeCapacity, usedValue } = useMemo(() => {
     const podsWithResourceInfo = pods.map((pod) => {
       const podMetrics =
-        podMetricsData[`${pod.metadata.namespace}/${pod.metadata.name}`];
+        podMetricsData[getNN(pod)];
       const resourceInfo = calculatePodResourceInfo(pod, podMetrics);
       return {
         pod,
@@ -183,6 +183,7 @@ export default function NodePodList({ pods, node }: NodePodListProps) {
         x: `${pod.metadata.name}`,
         y: value,
         namespace: pod.metadata.namespace,
+        id: getNN(pod),
         status: getStatus(pod).status,
         type: "pod",
       }));
@@ -192,6 +193,7 @@ export default function NodePodList({ pods, node }: NodePodListProps) {
           x: "Others",
           y: otherPodsValue,
           namespace: "multiple",
+          id: "",
           status: Status.Running,
           type: "other",
         });
@@ -201,6 +203,7 @@ export default function NodePodList({ pods, node }: NodePodListProps) {
         x: `${pod.metadata.name}`,
         y: value,
         namespace: pod.metadata.namespace,
+        id: getNN(pod),
         status: getStatus(pod).status,
         type: "pod",
       }));
@@ -216,6 +219,7 @@ export default function NodePodList({ pods, node }: NodePodListProps) {
         x: "Available",
         y: availableValue,
         namespace: "",
+        id: "",
         status: Status.Running,
         type: "available",
       });
@@ -233,10 +237,10 @@ export default function NodePodList({ pods, node }: NodePodListProps) {
             if (
               selectedData &&
               typeof selectedData.x === "string" &&
-              selectedData.namespace &&
+              selectedData.id &&
               selectedData.type === "pod"
             ) {
-              handlePodClick(selectedData.x, selectedData.namespace);
+              handlePodClick(selectedData.id);
             }
           },
         },
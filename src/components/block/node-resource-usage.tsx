@@ -12,6 +12,7 @@ import {
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { ResourceRadialChart } from "./resource-radial-chart";
 import { cpuChartConfig, memoryChartConfig } from "@/config/charts";
+import { getNN } from "@/lib/kuviewAtom";
 
 interface NodeResourceUsageProps {
   node: NodeObject;
@@ -93,10 +94,12 @@ export default function NodeResourceUsage({ node }: NodeResourceUsageProps) {
   const nodeMetricsData = useKuview("metrics.k8s.io/v1beta1/NodeMetrics");
 
   const nodePods = Object.values(podsData).filter(
-    (pod) => pod.spec.nodeName === node.metadata.name,
+    (pod) =>
+      pod.spec.nodeName === node.metadata.name &&
+      pod.kuviewCluster === node.kuviewCluster,
   );
 
-  const nodeMetrics = nodeMetricsData[node.metadata.name];
+  const nodeMetrics = nodeMetricsData[getNN(node)];
 
   const resourceUsage = calculateResourceUsage(node, nodePods, nodeMetrics);
 
@@ -25,6 +25,7 @@ import {
   ChevronDown,
 } from "lucide-react";
 import dayjs from "dayjs";
+import { getNN, toNN } from "@/lib/kuviewAtom";
 
 interface NodeResourceData {
   node: NodeObject;
@@ -63,7 +64,7 @@ function calculateNodeResourceData(
     const cpuCapacity = parseCpu(capacity.cpu || "0");
     const memoryCapacity = parseMemory(capacity.memory || "0");
 
-    nodeResourceMap[node.metadata.name] = {
+    nodeResourceMap[getNN(node)] = {
       node,
       podCount: 0,
       cpu: {
@@ -92,7 +93,9 @@ function calculateNodeResourceData(
   Object.values(pods)
     .filter((pod) => pod.kuviewExtra?.status === "Running")
     .forEach((pod) => {
-      const nodeName = pod.spec.nodeName;
+      const nodeName =
+        pod.spec.nodeName &&
+        toNN(pod.spec.nodeName, undefined, pod.kuviewCluster);
       if (nodeName && nodeResourceMap[nodeName]) {
         const nodeData = nodeResourceMap[nodeName];
         nodeData.podCount += 1;
@@ -117,7 +120,7 @@ function calculateNodeResourceData(
 
   // Iterate over node metrics to aggregate actual usage (O(N) time)
   Object.values(nodeMetrics).forEach((nodeMetric) => {
-    const nodeName = nodeMetric.metadata.name;
+    const nodeName = getNN(nodeMetric);
     if (nodeName && nodeResourceMap[nodeName]) {
       const nodeData = nodeResourceMap[nodeName];
       nodeData.cpu.usage = parseCpu(nodeMetric.usage.cpu || "0");
@@ -524,7 +527,9 @@ export default function NodesResourceTable() {
               <TableRow
                 key={data.node.metadata.name}
                 onClick={() =>
-                  setLocation(`${PREFIX}/nodes?node=${data.node.metadata.name}`)
+                  setLocation(
+                    `${PREFIX}/nodes?node=${encodeURIComponent(getNN(data.node))}`,
+                  )
                 }
                 className="cursor-pointer hover:bg-muted/50"
               >
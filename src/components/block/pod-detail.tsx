@@ -17,6 +17,7 @@ import PodResourceUsage from "./pod-resource-usage";
 import { PREFIX } from "@/lib/const";
 import PodsVolumeList from "./pods-volume-list";
 import MetadataHeader from "./metadata-header";
+import { toNN } from "@/lib/kuviewAtom";
 
 interface PodDetailProps {
   pod: PodObject;
@@ -26,6 +27,8 @@ interface PodDetailProps {
 export default function PodDetail({ pod, className }: PodDetailProps) {
   const pods = useKuview("v1/Pod");
   const [jsonExpanded, setJsonExpanded] = useState(false);
+  const nodeId =
+    pod.spec.nodeName && toNN(pod.spec.nodeName, undefined, pod.kuviewCluster);
 
   return (
     <div className={cn("space-y-6", className)}>
@@ -41,21 +44,25 @@ export default function PodDetail({ pod, className }: PodDetailProps) {
       <PodsGrid
         title={`Node "${pod.spec.nodeName}"`}
         href={
-          pod.spec.nodeName
-            ? `${PREFIX}/nodes?node=${encodeURIComponent(pod.spec.nodeName)}`
+          nodeId
+            ? `${PREFIX}/nodes?node=${encodeURIComponent(nodeId)}`
             : undefined
         }
         pods={Object.values(pods).filter(
-          (p) => p.spec.nodeName === pod.spec.nodeName,
+          (p) =>
+            p.spec.nodeName === pod.spec.nodeName &&
+            p.kuviewCluster === pod.kuviewCluster,
         )}
       />
 
       {/* Pods in the same namespace */}
       <PodsGrid
         title={`Namespace "${pod.metadata.namespace}"`}
-        href={`${PREFIX}/namespaces?namespace=${encodeURIComponent(pod.metadata.namespace ?? "")}`}
+        href={`${PREFIX}/namespaces?namespace=${encodeURIComponent(toNN(pod.metadata.namespace ?? "", undefined, pod.kuviewCluster))}`}
         pods={Object.values(pods).filter(
-          (p) => p.metadata.namespace === pod.metadata.namespace,
+          (p) =>
+            p.metadata.namespace === pod.metadata.namespace &&
+            p.kuviewCluster === pod.kuviewCluster,
         )}
       />
 
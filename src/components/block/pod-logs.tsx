@@ -129,7 +129,9 @@ export default function PodLogs({ pod }: PodLogsProps) {
     const name = pod.metadata.name;
     const follow = option === "live";
     const previous = option === "previous";
-    return `/api/v1/namespaces/${namespace}/pods/${name}/log?container=${containerName}&follow=${follow}&previous=${previous}`;
+    const cluster = pod.kuviewCluster;
+    const url = `/api/v1/namespaces/${namespace}/pods/${name}/log?container=${containerName}&follow=${follow}&previous=${previous}`;
+    return cluster ? `${url}&cluster=${encodeURIComponent(cluster)}` : url;
   };
 
   const containers = pod.spec.containers || [];
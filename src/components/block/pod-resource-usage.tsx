@@ -12,6 +12,7 @@ import {
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { ResourceRadialChart } from "./resource-radial-chart";
 import { cpuChartConfig, memoryChartConfig } from "@/config/charts";
+import { getNN } from "@/lib/kuviewAtom";
 
 interface PodResourceUsageProps {
   pod: PodObject;
@@ -88,7 +89,7 @@ export default function PodResourceUsage({ pod }: PodResourceUsageProps) {
 
   // Find metrics for the current pod
   const podMetrics =
-    podMetricsData[`${pod.metadata.namespace}/${pod.metadata.name}`];
+    podMetricsData[getNN(pod)];
 
   // Find the node this pod is running on
   const node = Object.values(nodesData).find(
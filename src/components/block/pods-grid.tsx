@@ -12,6 +12,7 @@ import {
 } from "@/components/ui/tooltip";
 import { PREFIX } from "@/lib/const";
 import { ChevronLeft, ChevronRight } from "lucide-react";
+import { getNN } from "@/lib/kuviewAtom";
 
 interface PodsGridProps {
   title?: string;
@@ -39,7 +40,7 @@ export default function PodsGrid({ title, href, pods }: PodsGridProps) {
   const shouldShowPagination = pods.length > PODS_PER_PAGE;
 
   const internalHandlePodClick = (pod: PodObject) => {
-    const podId = `${pod.metadata.namespace}/${pod.metadata.name}`;
+    const podId = getNN(pod);
     setLocation(`${PREFIX}/pods?pod=${encodeURIComponent(podId)}`);
   };
 
@@ -11,6 +11,7 @@ import {
 } from "@/components/ui/table";
 import { Link } from "wouter";
 import { PREFIX } from "@/lib/const";
+import { getNN } from "@/lib/kuviewAtom";
 
 interface NodeVolumeListProps {
   pods: PodObject[];
@@ -97,6 +98,7 @@ export default function PodsVolumeList({ pods }: NodeVolumeListProps) {
         pod.spec.volumes?.map((v) => ({
           ...v,
           nickName: `${pod.metadata.namespace}/${pod.metadata.name}`,
+          podId: getNN(pod),
         })) ?? [],
     ) ?? [];
 
@@ -146,7 +148,7 @@ export default function PodsVolumeList({ pods }: NodeVolumeListProps) {
                   <TableCell>
                     <div className="space-y-1 max-w-[200px] overflow-scroll">
                       <Link
-                        to={`${PREFIX}/pods?pod=${volume.nickName}`}
+                        to={`${PREFIX}/pods?pod=${encodeURIComponent(volume.podId)}`}
                         className="hover:underline font-medium text-sm"
                       >
                         {volume.nickName}
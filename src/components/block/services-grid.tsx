@@ -12,6 +12,7 @@ import { ChevronLeft, ChevronRight } from "lucide-react";
 import { getStatusColor, getStatus } from "@/lib/status";
 import { PREFIX } from "@/lib/const";
 import { useLocation } from "wouter";
+import { getNN } from "@/lib/kuviewAtom";
 
 interface ServicesGridProps {
   title?: string;
@@ -92,7 +93,7 @@ export default function ServicesGrid({ title, services }: ServicesGridProps) {
                       <div
                         className={`w-3 h-3 cursor-pointer border border-gray-300 hover:border-gray-600 ${getServiceColor(service)}`}
                         onClick={() => {
-                          const serviceId = `${service.metadata.namespace}/${service.metadata.name}`;
+                          const serviceId = getNN(service);
                           setLocation(
                             `${PREFIX}/services?service=${encodeURIComponent(serviceId)}`,
                           );
@@ -4,6 +4,7 @@ import { useMemo } from "react";
 import type { PodObject } from "@/lib/kuview";
 import { useKuview } from "@/hooks/useKuview";
 
+// usePodsByNode returns the pods of a node, given by its nn as in getNN
 export function usePodsByNode(nodeName: string | undefined): PodObject[] {
   const allPods = useKuview("v1/Pod");
   const podsByNodeIndex = useAtomValue(podsByNodeNameIndexAtom);
@@ -0,0 +1,213 @@
+import type { KuviewControlEvent, KuviewEvent } from "./kuview";
+
+// Media type of the binary stream of /kuview.
+// Every frame is a CBOR data item prefixed with its length as a big-endian uint32.
+export const CONTENT_TYPE_CBOR_STREAM = "application/vnd.kuview.cbor-stream";
+
+// Tag of the byte strings that are base64 strings in JSON, e.g. binaryData of ConfigMaps
+const TAG_BASE64 = 22;
+const BREAK = 0xff;
+
+const textDecoder = new TextDecoder();
+
+class Reader {
+  private view: DataView;
+  private offset = 0;
+
+  constructor(private bytes: Uint8Array) {
+    this.view = new DataView(bytes.buffer, bytes.byteOffset, bytes.byteLength);
+  }
+
+  item(): unknown {
+    const initial = this.bytes[this.offset++];
+    const major = initial >> 5;
+    const info = initial & 0x1f;
+    if (major === 7) {
+      return this.simple(info);
+    }
+    // -1 for indefinite lengths
+    const length = info === 31 ? -1 : this.argument(info);
+    switch (major) {
+      case 0:
+        return length;
+      case 1:
+        return -1 - length;
+      // The server encodes strings as byte strings, so both are decoded as text.
+      case 2:
+      case 3:
+        return textDecoder.decode(this.raw(length));
+      case 4: {
+        const array: unknown[] = [];
+        while (length < 0 ? !this.isBreak() : array.length < length) {
+          array.push(this.item());
+        }
+        return array;
+      }
+      case 5: {
+        const map: Record<string, unknown> = {};
+        for (let i = 0; length < 0 ? !this.isBreak() : i < length; i++) {
+          const key = String(this.item());
+          map[key] = this.item();
+        }
+        return map;
+      }
+      case 6: {
+        if (length === TAG_BASE64 && this.bytes[this.offset] >> 5 === 2) {
+          const initial = this.bytes[this.offset++];
+          return toBase64(this.raw(this.argument(initial & 0x1f)));
+        }
+        // other tags are ignored
+        return this.item();
+      }
+    }
+    throw new Error(`unsupported CBOR major type ${major}`);
+  }
+
+  private argument(info: number): number {
+    if (info < 24) {
+      return info;
+    }
+    const offset = this.offset;
+    switch (info) {
+      case 24:
+        this.offset += 1;
+        return this.view.getUint8(offset);
+      case 25:
+        this.offset += 2;
+        return this.view.getUint16(offset);
+      case 26:
+        this.offset += 4;
+        return this.view.getUint32(offset);
+      case 27:
+        this.offset += 8;
+        return Number(this.view.getBigUint64(offset));
+    }
+    throw new Error(`invalid CBOR argument ${info}`);
+  }
+
+  private simple(info: number): unknown {
+    const offset = this.offset;
+    switch (info) {
+      case 20:
+        return false;
+      case 21:
+        return true;
+      case 22:
+        return null;
+      case 23:
+        return undefined;
+      case 25:
+        this.offset += 2;
+        return halfToNumber(this.view.getUint16(offset));
+      case 26:
+        this.offset += 4;
+        return this.view.getFloat32(offset);
+      case 27:
+        this.offset += 8;
+        return this.view.getFloat64(offset);
+    }
+    throw new Error(`unsupported CBOR simple value ${info}`);
+  }
+
+  private raw(length: number): Uint8Array {
+    if (length < 0) {
+      throw new Error("indefinite length strings are not supported");
+    }
+    const bytes = this.bytes.subarray(this.offset, this.offset + length);
+    this.offset += length;
+    return bytes;
+  }
+
+  private isBreak(): boolean {
+    if (this.bytes[this.offset] === BREAK) {
+      this.offset++;
+      return true;
+    }
+    return false;
+  }
+}
+
+function halfToNumber(half: number): number {
+  const exponent = (half >> 10) & 0x1f;
+  const fraction = half & 0x3ff;
+  const sign = half & 0x8000 ? -1 : 1;
+  if (exponent === 0) {
+    return sign * 2 ** -14 * (fraction / 1024);
+  }
+  if (exponent === 0x1f) {
+    return fraction ? NaN : sign * Infinity;
+  }
+  return sign * 2 ** (exponent - 15) * (1 + fraction / 1024);
+}
+
+function toBase64(bytes: Uint8Array): string {
+  let binary = "";
+  for (const b of bytes) {
+    binary += String.fromCharCode(b);
+  }
+  return btoa(binary);
+}
+
+// decodeCBOR decodes a single CBOR data item.
+export function decodeCBOR(bytes: Uint8Array): unknown {
+  return new Reader(bytes).item();
+}
+
+// readFrames decodes the length-prefixed frames of a stream as they arrive.
+export async function* readFrames(
+  stream: ReadableStream<Uint8Array>,
+): AsyncGenerator<unknown> {
+  const reader = stream.getReader();
+  let buffer = new Uint8Array(0);
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) {
+      return;
+    }
+    if (buffer.length === 0) {
+      buffer = value;
+    } else {
+      const joined = new Uint8Array(buffer.length + value.length);
+      joined.set(buffer);
+      joined.set(value, buffer.length);
+      buffer = joined;
+    }
+
+    let offset = 0;
+    while (buffer.length - offset >= 4) {
+      const size = new DataView(
+        buffer.buffer,
+        buffer.byteOffset + offset,
+        4,
+      ).getUint32(0);
+      if (buffer.length - offset - 4 < size) {
+        break;
+      }
+      yield decodeCBOR(buffer.subarray(offset + 4, offset + 4 + size));
+      offset += 4 + size;
+    }
+    buffer = buffer.subarray(offset);
+  }
+}
+
+// streamCBOR subscribes to the binary stream of the kuview server, e.g. "/kuview?cluster=prod".
+// Control events are passed in the same shape as the ones of the SSE stream.
+export async function streamCBOR(
+  url: string,
+  onEvent: (event: KuviewEvent | KuviewControlEvent) => void,
+) {
+  const res = await fetch(url, {
+    headers: { Accept: CONTENT_TYPE_CBOR_STREAM },
+  });
+  if (!res.ok || !res.body) {
+    throw new Error(`failed to subscribe to ${url}: ${res.status}`);
+  }
+  for await (const frame of readFrames(res.body)) {
+    const item = frame as Record<string, unknown>;
+    if (typeof item.event === "string") {
+      onEvent({ type: item.event, data: item.data } as KuviewControlEvent);
+    } else {
+      onEvent(item as unknown as KuviewEvent);
+    }
+  }
+}
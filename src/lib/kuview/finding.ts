@@ -0,0 +1,17 @@
+import type { Metadata, ObjectReference, Severity } from "./types";
+
+// Virtual resource synthesized by the diagnostics engine of the kuview server
+export interface FindingObject {
+  kind: "Finding";
+  apiVersion: "kuview.iwanhae.kr/v1";
+  metadata: Metadata;
+  spec: FindingSpec;
+}
+
+export interface FindingSpec {
+  rule: string;
+  severity: Severity;
+  object: ObjectReference;
+  message: string;
+  remediation?: string;
+}
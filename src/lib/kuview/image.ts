@@ -0,0 +1,36 @@
+import type { Metadata, Severity } from "./types";
+
+// Virtual resource synthesized by the kuview server for every image used by the pods
+export interface ContainerImageObject {
+  kind: "ContainerImage";
+  apiVersion: "kuview.iwanhae.kr/v1";
+  metadata: Metadata;
+  spec: {
+    reference: string;
+    registry: string;
+    repository: string;
+    tag?: string;
+    digest?: string;
+    references: string[];
+    imageIDs?: string[];
+    pullPolicies: string[];
+    namespaces: string[];
+    nodes: string[];
+    usages: ContainerImageUsage[];
+    issues?: ContainerImageIssue[];
+  };
+}
+
+export interface ContainerImageUsage {
+  namespace: string;
+  pod: string;
+  container: string;
+  node?: string;
+  pullPolicy?: string;
+}
+
+export interface ContainerImageIssue {
+  check: "latest-tag" | "not-pinned" | "registry-not-allowed";
+  severity: Severity;
+  message: string;
+}
@@ -9,6 +9,11 @@ export * from "./metrics";
 export * from "./rbac";
 export * from "./usergroup";
 export * from "./volume";
+export * from "./finding";
+export * from "./podsecurity";
+export * from "./image";
+export * from "./upstream";
+export * from "./sync";
 
 // Import specific types for the object map
 import type { PodObject } from "./pod";
@@ -22,8 +27,17 @@ import type {
   RoleBindingObject,
   ClusterRoleObject,
   ClusterRoleBindingObject,
+  RBACSubjectRiskObject,
 } from "./rbac";
 import type { UserGroupObject } from "./usergroup";
+import type { FindingObject } from "./finding";
+import type {
+  PodSecurityViolationObject,
+  PodSecuritySummaryObject,
+} from "./podsecurity";
+import type { ContainerImageObject } from "./image";
+import type { UpstreamStatusObject } from "./upstream";
+import type { SyncStatusObject } from "./sync";
 import type {
   PersistentVolumeObject,
   PersistentVolumeClaimObject,
@@ -44,6 +58,13 @@ export interface KuviewObjectMap {
   "rbac.authorization.k8s.io/v1/ClusterRole": ClusterRoleObject;
   "rbac.authorization.k8s.io/v1/ClusterRoleBinding": ClusterRoleBindingObject;
   "kuview.iwanhae.kr/v1/UserGroup": UserGroupObject;
+  "kuview.iwanhae.kr/v1/RBACSubjectRisk": RBACSubjectRiskObject;
+  "kuview.iwanhae.kr/v1/Finding": FindingObject;
+  "kuview.iwanhae.kr/v1/PodSecurityViolation": PodSecurityViolationObject;
+  "kuview.iwanhae.kr/v1/PodSecuritySummary": PodSecuritySummaryObject;
+  "kuview.iwanhae.kr/v1/ContainerImage": ContainerImageObject;
+  "kuview.iwanhae.kr/v1/UpstreamStatus": UpstreamStatusObject;
+  "kuview.iwanhae.kr/v1/SyncStatus": SyncStatusObject;
 }
 
 export type GVK = keyof KuviewObjectMap;
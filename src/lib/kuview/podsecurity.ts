@@ -0,0 +1,44 @@
+import type { Metadata } from "./types";
+
+export type PodSecurityLevel = "privileged" | "baseline" | "restricted";
+
+export type PodSecurityMode = "enforce" | "audit" | "warn";
+
+export interface PodSecurityModes {
+  enforce?: PodSecurityLevel;
+  audit?: PodSecurityLevel;
+  warn?: PodSecurityLevel;
+}
+
+// Virtual resource synthesized by the kuview server for every running pod failing a Pod Security Standard
+export interface PodSecurityViolationObject {
+  kind: "PodSecurityViolation";
+  apiVersion: "kuview.iwanhae.kr/v1";
+  metadata: Metadata;
+  spec: {
+    level: PodSecurityLevel;
+    checks: PodSecurityCheck[];
+    namespaceModes: PodSecurityModes;
+    violates?: PodSecurityMode[];
+  };
+}
+
+export interface PodSecurityCheck {
+  check: string;
+  profile: PodSecurityLevel;
+  container?: string;
+  message: string;
+}
+
+// Virtual resource synthesized by the kuview server for every namespace
+export interface PodSecuritySummaryObject {
+  kind: "PodSecuritySummary";
+  apiVersion: "kuview.iwanhae.kr/v1";
+  metadata: Metadata;
+  spec: {
+    namespaceModes: PodSecurityModes;
+    pods: number;
+    levels: Record<PodSecurityLevel, number>;
+    violations: Record<PodSecurityMode, number>;
+  };
+}
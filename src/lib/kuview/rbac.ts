@@ -1,4 +1,9 @@
-import type { LabelSelector, Metadata } from "./types";
+import type {
+  LabelSelector,
+  Metadata,
+  ObjectReference,
+  Severity,
+} from "./types";
 
 export interface PolicyRule {
   verbs: string[];
@@ -55,3 +60,28 @@ export interface ClusterRoleBindingObject {
   subjects?: Subject[];
   roleRef: RoleRef;
 }
+
+// Virtual resource synthesized by the RBAC analyzer of the kuview server
+export interface RBACSubjectRiskObject {
+  kind: "RBACSubjectRisk";
+  apiVersion: "kuview.iwanhae.kr/v1";
+  metadata: Metadata;
+  spec: RBACSubjectRiskSpec;
+}
+
+export interface RBACSubjectRiskSpec {
+  subject: Subject;
+  score: number;
+  rank: number;
+  severity: Severity;
+  findings: RBACRiskFinding[];
+}
+
+export interface RBACRiskFinding {
+  rule: string;
+  severity: Severity;
+  message: string;
+  scope?: string;
+  binding: ObjectReference;
+  role: ObjectReference;
+}
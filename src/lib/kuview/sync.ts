@@ -0,0 +1,18 @@
+import type { Metadata } from "./types";
+
+// Virtual resource synthesized by the kuview server for every watched kind
+export interface SyncStatusObject {
+  kind: "SyncStatus";
+  apiVersion: "kuview.iwanhae.kr/v1";
+  metadata: Metadata;
+  spec: {
+    // e.g. "v1/Pod"
+    gvk: string;
+    synced: boolean;
+    forbidden: boolean;
+    erroring: boolean;
+    objects: number;
+    lastError?: string;
+    lastErrorTime?: string;
+  };
+}
@@ -3,8 +3,20 @@ import type { Condition } from "../status";
 export type KuviewEvent = {
   type: "create" | "update" | "delete" | "generic";
   object: KubernetesObject;
+  // ID of the cluster, set when the server watches several clusters
+  cluster?: string;
 };
 
+// Control events of the stream of the kuview server
+export type KuviewControlEvent =
+  | {
+      type: "snapshot-begin";
+      data: { total: number; kinds: Record<string, number> };
+    }
+  | { type: "snapshot-end"; data: { total: number } }
+  | { type: "synced"; data: { cluster?: string; gvk: string; objects: number } }
+  | { type: "reconnect"; data: { reason: string } };
+
 export interface KuviewExtra extends Condition {
   [key: string]: unknown;
 }
@@ -18,6 +30,8 @@ export interface KubernetesObject {
   status: unknown;
 
   kuviewExtra?: KuviewExtra;
+  // ID of the cluster of the object, copied from its event
+  kuviewCluster?: string;
 }
 
 export interface Metadata {
@@ -94,3 +108,6 @@ export interface LabelSelector {
   matchLabels?: Labels;
   matchExpressions?: LabelSelectorRequirement[];
 }
+
+// Severity of the findings synthesized by kuview (kuview.iwanhae.kr/v1)
+export type Severity = "Critical" | "High" | "Medium" | "Low";
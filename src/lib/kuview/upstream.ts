@@ -0,0 +1,16 @@
+import type { Metadata } from "./types";
+
+// Virtual resource synthesized by a kuview relay for every upstream kuview server
+export interface UpstreamStatusObject {
+  kind: "UpstreamStatus";
+  apiVersion: "kuview.iwanhae.kr/v1";
+  metadata: Metadata;
+  spec: {
+    url: string;
+    connected: boolean;
+    lastConnected?: string;
+    lastError?: string;
+    reconnects: number;
+    objects: number;
+  };
+}
@@ -2,6 +2,7 @@ import { atom, useAtom, useAtomValue, type PrimitiveAtom } from "jotai";
 import type {
   EndpointSliceObject,
   KubernetesObject,
+  KuviewControlEvent,
   KuviewEvent,
   KuviewExtra,
   ServiceObject,
@@ -22,7 +23,7 @@ export const kubernetesAtom = atom<
   "kuview.iwanhae.kr/v1/UserGroup": atom<Record<string, KubernetesObject>>({}), // virtual resource
 });
 
-// Pod index atom: nodeName -> pod nn -> true
+// Pod index atom: node nn -> pod nn -> true
 export const podsByNodeNameIndexAtom = atom<
   Record<string, Record<string, true>>
 >({});
@@ -48,7 +49,28 @@ const POD_INDEX_CHANGES = new Map<string, PodIndexChange>();
 
 function getObjectKey(object: KubernetesObject): string {
   // it is suprising that sometimes the uid is not unique, so we need to check the apiVersion and kind as well
-  return `${object.apiVersion}/${object.kind}:${object.metadata.namespace}/${object.metadata.name}:${object.metadata.uid}`;
+  return `${object.kuviewCluster ?? ""}:${object.apiVersion}/${object.kind}:${object.metadata.namespace}/${object.metadata.name}:${object.metadata.uid}`;
+}
+
+// toNN returns the key of an object in the atom of its GVK, its namespace and name,
+// prefixed with its cluster when the server watches several clusters. e.g. "prod/default/nginx"
+export function toNN(
+  name: string,
+  namespace?: string,
+  cluster?: string,
+): string {
+  const nn = namespace ? `${namespace}/${name}` : name;
+  return cluster ? `${cluster}/${nn}` : nn;
+}
+
+export function getNN(
+  object: Pick<KubernetesObject, "metadata" | "kuviewCluster">,
+): string {
+  return toNN(
+    object.metadata.name,
+    object.metadata.namespace,
+    object.kuviewCluster,
+  );
 }
 
 // Function to update the Pod index
@@ -59,9 +81,7 @@ function updatePodIndex(event: KuviewEvent) {
 
   const pod = event.object as PodObject;
   const nodeName = pod.spec?.nodeName;
-  const nn = pod.metadata.namespace
-    ? `${pod.metadata.namespace}/${pod.metadata.name}`
-    : pod.metadata.name;
+  const nn = getNN(pod);
 
   // If nodeName doesn't exist, no need to index, so drop it here
   if (!nodeName || !nn) {
@@ -74,7 +94,77 @@ function updatePodIndex(event: KuviewEvent) {
   });
 }
 
-export function handleEvent(event: KuviewEvent) {
+// Progress of the stream of the kuview server. It stays empty in WASM mode.
+export type StreamStatus = {
+  // expected number of objects per GVK, set when the snapshot begins
+  expected: Record<string, number>;
+  snapshotComplete: boolean;
+  // GVKs whose informers have synced
+  synced: Record<string, true>;
+};
+
+export const streamStatusAtom = atom<StreamStatus>({
+  expected: {},
+  snapshotComplete: false,
+  synced: {},
+});
+
+// the last status, kept across flushes so the later events build on it
+let STREAM_STATUS: StreamStatus = {
+  expected: {},
+  snapshotComplete: false,
+  synced: {},
+};
+// set when STREAM_STATUS changed since the last flush
+let STREAM_STATUS_DIRTY = false;
+
+function handleControlEvent(event: KuviewControlEvent) {
+  const status = STREAM_STATUS;
+  switch (event.type) {
+    case "snapshot-begin":
+      STREAM_STATUS = {
+        expected: event.data.kinds,
+        snapshotComplete: false,
+        synced: {},
+      };
+      break;
+    case "snapshot-end":
+      STREAM_STATUS = { ...status, snapshotComplete: true };
+      break;
+    case "synced":
+      STREAM_STATUS = {
+        ...status,
+        synced: { ...status.synced, [event.data.gvk]: true },
+      };
+      break;
+    case "reconnect":
+      // the stream is about to end, the snapshot of the next one resets the status
+      console.log("[Main] Reconnecting to /kuview:", event.data.reason);
+      return;
+  }
+  STREAM_STATUS_DIRTY = true;
+}
+
+export function useStreamStatusSyncHook() {
+  const [, setStreamStatus] = useAtom(streamStatusAtom);
+  useEffect(() => {
+    const interval = setInterval(() => {
+      if (!STREAM_STATUS_DIRTY) return;
+      setStreamStatus(STREAM_STATUS);
+      STREAM_STATUS_DIRTY = false;
+    }, DEBOUNCE_MS);
+    return () => clearInterval(interval);
+  }, [setStreamStatus]);
+}
+
+export function handleEvent(event: KuviewEvent | KuviewControlEvent) {
+  if (!("object" in event)) {
+    handleControlEvent(event);
+    return;
+  }
+
+  if (event.cluster) event.object.kuviewCluster = event.cluster;
+
   // Update Pod index (handled separately from the main logic)
   updatePodIndex(event);
 
@@ -112,10 +202,7 @@ export function useGVKSyncHook(gvk: string) {
     operations.forEach((operation) => {
       updated = true;
       const { type, object } = operation;
-      const { metadata } = object;
-      const nn = metadata.namespace
-        ? `${metadata.namespace}/${metadata.name}`
-        : metadata.name;
+      const nn = getNN(object);
 
       if (type === "UPSERT") object.kuviewExtra = { ...calcStatus(object) };
 
@@ -194,8 +281,7 @@ export function useServiceEndpointSliceSyncHook() {
     );
     serviceOperations.forEach((operation) => {
       const { object } = operation;
-      const { metadata } = object;
-      const nn = `${metadata.namespace}/${metadata.name}`;
+      const nn = getNN(object);
 
       if (operation.type === "UPSERT") {
         if (!services[nn]) services[nn] = object;
@@ -215,8 +301,7 @@ export function useServiceEndpointSliceSyncHook() {
     );
     endpointSliceOperations.forEach((operation) => {
       const { object } = operation;
-      const { metadata } = object;
-      const nn = `${metadata.namespace}/${metadata.name}`;
+      const nn = getNN(object);
 
       if (operation.type === "UPSERT") {
         endpointSlices[nn] = object;
@@ -234,7 +319,7 @@ export function useServiceEndpointSliceSyncHook() {
         (o) => o.kind === "Endpoints" || o.kind === "Service",
       );
       if (!ownerRef) continue;
-      const nn = `${ep.metadata.namespace}/${ownerRef.name}`;
+      const nn = toNN(ownerRef.name, ep.metadata.namespace, ep.kuviewCluster);
 
       // find owner service
       const ownerService = services[nn] as ServiceObject;
@@ -292,10 +377,9 @@ export function usePodIndexSyncHook() {
 
     changes.forEach((change) => {
       const { type, pod } = change;
-      const nodeName = pod.spec.nodeName!; // From here, we assume nodeName always exists
-      const nn = pod.metadata.namespace
-        ? `${pod.metadata.namespace}/${pod.metadata.name}`
-        : pod.metadata.name;
+      // From here, we assume nodeName always exists
+      const nodeName = toNN(pod.spec.nodeName!, undefined, pod.kuviewCluster);
+      const nn = getNN(pod);
 
       if (type === "DELETE") {
         if (podIndex[nodeName] && podIndex[nodeName][nn]) {
@@ -4,6 +4,7 @@ import SearchComponent from "@/components/block/search";
 import NamespaceDetail from "@/components/block/namespace-detail";
 import type { NamespaceObject } from "@/lib/kuview";
 import { getStatus } from "@/lib/status";
+import { getNN } from "@/lib/kuviewAtom";
 
 export default function NamespacePage() {
   const namespacesData = useKuview("v1/Namespace");
@@ -21,12 +22,12 @@ export default function NamespacePage() {
         {/* Search */}
         <SearchComponent<NamespaceObject>
           resources={Object.values(namespacesData)}
-          getResourceId={(ns) => ns.metadata.name}
+          getResourceId={getNN}
           getResourceStatus={getStatus}
           onResourceSelect={(id) =>
             setSelectedNamespace(namespacesData[id] || null)
           }
-          selectedResourceId={selectedNamespace?.metadata.name}
+          selectedResourceId={selectedNamespace ? getNN(selectedNamespace) : undefined}
           resourceTypeName="namespace"
           urlResourceParam="namespace"
           urlFilterParam="namespaceFilter"
@@ -4,6 +4,7 @@ import SearchComponent from "@/components/block/search";
 import NodeDetail from "@/components/block/node-detail";
 import type { NodeObject } from "@/lib/kuview";
 import { getStatus } from "@/lib/status";
+import { getNN } from "@/lib/kuviewAtom";
 
 export default function NodePage() {
   const nodes = useKuview("v1/Node");
@@ -20,10 +21,10 @@ export default function NodePage() {
         {/* Search */}
         <SearchComponent<NodeObject>
           resources={Object.values(nodes)}
-          getResourceId={(node) => node.metadata.name}
+          getResourceId={getNN}
           getResourceStatus={getStatus}
           onResourceSelect={(id) => setSelectedNode(nodes[id] || null)}
-          selectedResourceId={selectedNode?.metadata.name}
+          selectedResourceId={selectedNode ? getNN(selectedNode) : undefined}
           resourceTypeName="node"
           urlResourceParam="node"
           urlFilterParam="nodeFilter"
@@ -4,6 +4,7 @@ import SearchComponent from "@/components/block/search";
 import PodDetail from "@/components/block/pod-detail";
 import type { PodObject } from "@/lib/kuview";
 import { getStatus } from "@/lib/status";
+import { getNN } from "@/lib/kuviewAtom";
 
 export default function PodPage() {
   const podsData = useKuview("v1/Pod");
@@ -20,10 +21,10 @@ export default function PodPage() {
         {/* Search */}
         <SearchComponent<PodObject>
           resources={Object.values(podsData)}
-          getResourceId={(po) => `${po.metadata.namespace}/${po.metadata.name}`}
+          getResourceId={getNN}
           getResourceStatus={getStatus}
           onResourceSelect={(id) => setSelectedPod(podsData[id] || null)}
-          selectedResourceId={`${selectedPod?.metadata.namespace}/${selectedPod?.metadata.name}`}
+          selectedResourceId={selectedPod ? getNN(selectedPod) : undefined}
           resourceTypeName="pod"
           urlResourceParam="pod"
           urlFilterParam="podFilter"
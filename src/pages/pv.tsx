@@ -4,6 +4,7 @@ import SearchComponent from "@/components/block/search";
 import PVDetail from "@/components/block/pv-detail";
 import type { PersistentVolumeObject } from "@/lib/kuview";
 import { getStatus } from "@/lib/status";
+import { getNN } from "@/lib/kuviewAtom";
 
 export default function PVPage() {
   const pvs = useKuview("v1/PersistentVolume");
@@ -22,10 +23,10 @@ export default function PVPage() {
         {/* Search */}
         <SearchComponent<PersistentVolumeObject>
           resources={Object.values(pvs)}
-          getResourceId={(pv) => pv.metadata.name}
+          getResourceId={getNN}
           getResourceStatus={getStatus}
           onResourceSelect={(id) => setSelectedPV(pvs[id] || null)}
-          selectedResourceId={selectedPV?.metadata.name}
+          selectedResourceId={selectedPV ? getNN(selectedPV) : undefined}
           resourceTypeName="persistent volume"
           urlResourceParam="pv"
           urlFilterParam="pvFilter"
@@ -4,6 +4,7 @@ import SearchComponent from "@/components/block/search";
 import PVCDetail from "@/components/block/pvc-detail";
 import type { PersistentVolumeClaimObject } from "@/lib/kuview";
 import { getStatus } from "@/lib/status";
+import { getNN } from "@/lib/kuviewAtom";
 
 export default function PVCPage() {
   const pvcs = useKuview("v1/PersistentVolumeClaim");
@@ -21,12 +22,10 @@ export default function PVCPage() {
         {/* Search */}
         <SearchComponent<PersistentVolumeClaimObject>
           resources={Object.values(pvcs)}
-          getResourceId={(pvc) =>
-            `${pvc.metadata.namespace}/${pvc.metadata.name}`
-          }
+          getResourceId={getNN}
           getResourceStatus={(pvc) => getStatus(pvc)}
           onResourceSelect={(id) => setSelectedPVC(pvcs[id] || null)}
-          selectedResourceId={`${selectedPVC?.metadata.namespace}/${selectedPVC?.metadata.name}`}
+          selectedResourceId={selectedPVC ? getNN(selectedPVC) : undefined}
           resourceTypeName="persistent volume claim"
           urlResourceParam="pvc"
           urlFilterParam="pvcFilter"
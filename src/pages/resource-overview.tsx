@@ -24,8 +24,11 @@ import type { ApexOptions } from "apexcharts";
 import { getStatus } from "@/lib/status";
 import { useLocation } from "wouter";
 import { PREFIX } from "@/lib/const";
+import { getNN } from "@/lib/kuviewAtom";
 
 interface PodResourceData {
+  // key of the pod in its atom, as in getNN
+  id: string;
   name: string;
   namespace: string;
   cpu: number; // millicores
@@ -34,6 +37,8 @@ interface PodResourceData {
 }
 
 interface NodeResourceData {
+  // key of the node in its atom, as in getNN
+  id: string;
   name: string;
   cpu: number; // millicores
   memory: number; // bytes
@@ -47,8 +52,7 @@ function calculatePodResources(
   const result: PodResourceData[] = [];
 
   Object.values(pods).forEach((pod) => {
-    const podKey = `${pod.metadata.namespace}/${pod.metadata.name}`;
-    const metrics = podMetrics[podKey];
+    const metrics = podMetrics[getNN(pod)];
 
     if (!metrics) {
       return; // Skip pods without metrics
@@ -65,6 +69,7 @@ function calculatePodResources(
     const status = getStatus(pod).status;
 
     result.push({
+      id: getNN(pod),
       name: pod.metadata.name,
       namespace: pod.metadata.namespace || "default",
       cpu: cpuUsage,
@@ -83,7 +88,7 @@ function calculateNodeResources(
   const result: NodeResourceData[] = [];
 
   Object.values(nodes).forEach((node) => {
-    const metrics = nodeMetrics[node.metadata.name];
+    const metrics = nodeMetrics[getNN(node)];
 
     if (!metrics) {
       return; // Skip nodes without metrics
@@ -104,6 +109,7 @@ function calculateNodeResources(
     }
 
     result.push({
+      id: getNN(node),
       name: node.metadata.name,
       cpu: cpuUsage,
       memory: memoryUsage,
@@ -709,8 +715,7 @@ export default function ResourceOverviewPage() {
   // Handle pod row click
   const handlePodClick = useCallback(
     (pod: PodResourceData) => {
-      const podId = `${pod.namespace}/${pod.name}`;
-      setLocation(`${PREFIX}/pods?pod=${encodeURIComponent(podId)}`);
+      setLocation(`${PREFIX}/pods?pod=${encodeURIComponent(pod.id)}`);
     },
     [setLocation],
   );
@@ -718,7 +723,7 @@ export default function ResourceOverviewPage() {
   // Handle node row click
   const handleNodeClick = useCallback(
     (node: NodeResourceData) => {
-      setLocation(`${PREFIX}/nodes?node=${encodeURIComponent(node.name)}`);
+      setLocation(`${PREFIX}/nodes?node=${encodeURIComponent(node.id)}`);
     },
     [setLocation],
   );
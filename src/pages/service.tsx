@@ -4,6 +4,7 @@ import SearchComponent from "@/components/block/search";
 import ServiceDetail from "@/components/block/service-detail";
 import type { ServiceObject } from "@/lib/kuview";
 import { getStatus } from "@/lib/status";
+import { getNN } from "@/lib/kuviewAtom";
 
 export default function ServicePage() {
   const servicesData = useKuview("v1/Service");
@@ -22,14 +23,12 @@ export default function ServicePage() {
         {/* Search */}
         <SearchComponent<ServiceObject>
           resources={Object.values(servicesData)}
-          getResourceId={(service) =>
-            `${service.metadata.namespace}/${service.metadata.name}`
-          }
+          getResourceId={getNN}
           getResourceStatus={(service) => getStatus(service)}
           onResourceSelect={(id) =>
             setSelectedService(servicesData[id] || null)
           }
-          selectedResourceId={`${selectedService?.metadata.namespace}/${selectedService?.metadata.name}`}
+          selectedResourceId={selectedService ? getNN(selectedService) : undefined}
           resourceTypeName="service"
           urlResourceParam="service"
           urlFilterParam="serviceFilter"
@@ -4,6 +4,7 @@ import SearchComponent from "@/components/block/search";
 import UserGroupDetail from "@/components/block/usergroup-detail";
 import type { UserGroupObject } from "@/lib/kuview";
 import { getStatus } from "@/lib/status";
+import { getNN } from "@/lib/kuviewAtom";
 
 export default function UserGroupsPage() {
   const userGroups = useKuview("kuview.iwanhae.kr/v1/UserGroup");
@@ -24,12 +25,12 @@ export default function UserGroupsPage() {
         {/* Search */}
         <SearchComponent<UserGroupObject>
           resources={Object.values(userGroups)}
-          getResourceId={(userGroup) => userGroup.metadata.name}
+          getResourceId={getNN}
           getResourceStatus={getStatus}
           onResourceSelect={(id) =>
             setSelectedUserGroup(userGroups[id] || null)
           }
-          selectedResourceId={selectedUserGroup?.metadata.name}
+          selectedResourceId={selectedUserGroup ? getNN(selectedUserGroup) : undefined}
           resourceTypeName="user group"
           urlResourceParam="usergroup"
           urlFilterParam="usergroupFilter"